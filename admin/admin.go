@@ -0,0 +1,209 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/cloudfoundry-incubator/route-emitter/journal"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/watcher"
+	"github.com/pivotal-golang/lager"
+)
+
+const (
+	DiffPath           = "/v1/diff"
+	ConflictsPath      = "/v1/conflicts"
+	RejectedRoutesPath = "/v1/rejected_routes"
+	ChangesPath        = "/v1/changes"
+	SyncPath           = "/v1/sync"
+	EmitPath           = "/v1/emit"
+)
+
+// TriggerSource is the source reported to the Trigger for syncs and emits
+// requested through the admin API.
+const TriggerSource = "admin-api"
+
+//go:generate counterfeiter -o fake_admin/fake_differ.go . Differ
+type Differ interface {
+	Diff(logger lager.Logger) (watcher.DiffReport, error)
+}
+
+//go:generate counterfeiter -o fake_admin/fake_trigger.go . Trigger
+type Trigger interface {
+	TriggerSync(source string) bool
+	TriggerEmit(source string) bool
+}
+
+// NewHandler serves the admin API, requiring the token as a bearer token on
+// every endpoint. Every request is rejected if the token is empty. The changes
+// endpoint is only served if a change journal is given, and the sync and emit
+// endpoints only if a trigger is given.
+func NewHandler(
+	differ Differ,
+	table routing_table.RoutingTable,
+	changeJournal *journal.Journal,
+	trigger Trigger,
+	token string,
+	logger lager.Logger,
+) http.Handler {
+	logger = logger.Session("admin")
+
+	mux := http.NewServeMux()
+	mux.Handle(DiffPath, &diffHandler{differ: differ, logger: logger})
+	mux.Handle(ConflictsPath, &conflictsHandler{table: table})
+	mux.Handle(RejectedRoutesPath, &rejectedRoutesHandler{table: table})
+	if changeJournal != nil {
+		mux.Handle(ChangesPath, &changesHandler{journal: changeJournal})
+	}
+	if trigger != nil {
+		mux.Handle(SyncPath, &triggerHandler{trigger: trigger.TriggerSync, logger: logger.Session("sync")})
+		mux.Handle(EmitPath, &triggerHandler{trigger: trigger.TriggerEmit, logger: logger.Session("emit")})
+	}
+
+	return &authHandler{handler: mux, token: token, logger: logger}
+}
+
+type authHandler struct {
+	handler http.Handler
+	token   string
+	logger  lager.Logger
+}
+
+func (h *authHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		h.logger.Info("unauthorized", lager.Data{"remote-addr": r.RemoteAddr, "path": r.URL.Path})
+		w.Header().Set("WWW-Authenticate", `Bearer realm="route-emitter"`)
+		writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "unauthorized"})
+		return
+	}
+
+	h.handler.ServeHTTP(w, r)
+}
+
+func (h *authHandler) authorized(r *http.Request) bool {
+	if h.token == "" {
+		return false
+	}
+
+	expected := "Bearer " + h.token
+	actual := r.Header.Get("Authorization")
+	return subtle.ConstantTimeCompare([]byte(actual), []byte(expected)) == 1
+}
+
+type diffHandler struct {
+	differ Differ
+	logger lager.Logger
+}
+
+func (h *diffHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := h.logger.Session("diff")
+
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	report, err := h.differ.Diff(logger)
+	if err != nil {
+		logger.Error("failed-to-diff", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+type conflictsHandler struct {
+	table routing_table.RoutingTable
+}
+
+func (h *conflictsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.table.Conflicts())
+}
+
+type rejectedRoutesHandler struct {
+	table routing_table.RoutingTable
+}
+
+func (h *rejectedRoutesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.table.RejectedRoutes())
+}
+
+type changesHandler struct {
+	journal *journal.Journal
+}
+
+// ServeHTTP responds with the changes after the sequence given by the "since"
+// query parameter, or all retained changes if it is omitted. It responds with
+// 410 Gone if some of the changes have already been evicted from the journal.
+func (h *changesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	param := r.URL.Query().Get("since")
+	if param == "" {
+		writeJSON(w, http.StatusOK, h.journal.Retained())
+		return
+	}
+
+	since, err := strconv.ParseUint(param, 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid since parameter: " + param})
+		return
+	}
+
+	changes, err := h.journal.Since(since)
+	if err != nil {
+		writeJSON(w, http.StatusGone, errorResponse{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, changes)
+}
+
+type triggerHandler struct {
+	trigger func(source string) bool
+	logger  lager.Logger
+}
+
+// triggerResponse reports whether the sync or emit was queued. A queued sync
+// may still be dropped if a sync is running when the watcher receives it.
+type triggerResponse struct {
+	Requested bool `json:"requested"`
+}
+
+// ServeHTTP requests a sync or an emit, and responds with 202 Accepted and
+// whether it was queued, or dropped because one is already pending.
+func (h *triggerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.logger.Info("triggered", lager.Data{"remote-addr": r.RemoteAddr})
+	writeJSON(w, http.StatusAccepted, triggerResponse{Requested: h.trigger(TriggerSource)})
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
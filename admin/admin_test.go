@@ -0,0 +1,333 @@
+package admin_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/admin"
+	"github.com/cloudfoundry-incubator/route-emitter/admin/fake_admin"
+	"github.com/cloudfoundry-incubator/route-emitter/journal"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table/fake_routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/watcher"
+	"github.com/pivotal-golang/clock/fakeclock"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Admin", func() {
+	var (
+		differ        *fake_admin.FakeDiffer
+		table         *fake_routing_table.FakeRoutingTable
+		changeJournal *journal.Journal
+		trigger       *fake_admin.FakeTrigger
+		handler       http.Handler
+		recorder      *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		differ = &fake_admin.FakeDiffer{}
+		table = &fake_routing_table.FakeRoutingTable{}
+		changeJournal = journal.New(2, fakeclock.NewFakeClock(time.Unix(0, 100)))
+		trigger = &fake_admin.FakeTrigger{}
+		handler = admin.NewHandler(differ, table, changeJournal, trigger, "secret", lagertest.NewTestLogger("test"))
+		recorder = httptest.NewRecorder()
+	})
+
+	newRequest := func(method, path string) *http.Request {
+		request, err := http.NewRequest(method, path, nil)
+		Expect(err).NotTo(HaveOccurred())
+		request.Header.Set("Authorization", "Bearer secret")
+		return request
+	}
+
+	Describe("GET /v1/diff", func() {
+		var report watcher.DiffReport
+
+		BeforeEach(func() {
+			report = watcher.DiffReport{
+				Registrations: []routing_table.RegistryMessage{
+					{Host: "1.1.1.1", Port: 11, URIs: []string{"foo.example.com"}, App: "log-guid"},
+				},
+				Unregistrations:    []routing_table.RegistryMessage{},
+				TCPRegistrations:   []routing_table.TCPRouteMapping{},
+				TCPUnregistrations: []routing_table.TCPRouteMapping{},
+			}
+			differ.DiffReturns(report, nil)
+		})
+
+		JustBeforeEach(func() {
+			handler.ServeHTTP(recorder, newRequest("GET", admin.DiffPath))
+		})
+
+		It("responds with the diff report", func() {
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+
+			var actual watcher.DiffReport
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &actual)).To(Succeed())
+			Expect(actual).To(Equal(report))
+			Expect(differ.DiffCallCount()).To(Equal(1))
+		})
+
+		Context("when the diff fails", func() {
+			BeforeEach(func() {
+				differ.DiffReturns(watcher.DiffReport{}, errors.New("bbs is down"))
+			})
+
+			It("responds with the error", func() {
+				Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+				Expect(recorder.Body.String()).To(MatchJSON(`{"error":"bbs is down"}`))
+			})
+		})
+	})
+
+	Context("when the diff is requested with another method", func() {
+		It("responds with 405", func() {
+			handler.ServeHTTP(recorder, newRequest("POST", admin.DiffPath))
+
+			Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(differ.DiffCallCount()).To(Equal(0))
+		})
+	})
+
+	Describe("GET /v1/conflicts", func() {
+		BeforeEach(func() {
+			table.ConflictsReturns([]routing_table.HostnameConflict{
+				{Hostname: "foo.example.com", Owner: "process-guid-1", Claimants: []string{"process-guid-2"}},
+			})
+
+			handler.ServeHTTP(recorder, newRequest("GET", admin.ConflictsPath))
+		})
+
+		It("responds with the hostname conflicts of the routing table", func() {
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`[
+				{"hostname": "foo.example.com", "owner": "process-guid-1", "claimants": ["process-guid-2"]}
+			]`))
+		})
+	})
+
+	Describe("GET /v1/rejected_routes", func() {
+		BeforeEach(func() {
+			table.RejectedRoutesReturns([]routing_table.RejectedRoutes{
+				{ProcessGuid: "process-guid-1", Hostnames: []routing_table.RejectedHostname{{Hostname: "foo_bar.example.com", Reason: "some reason"}}},
+			})
+
+			handler.ServeHTTP(recorder, newRequest("GET", admin.RejectedRoutesPath))
+		})
+
+		It("responds with the rejected routes of the routing table", func() {
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`[
+				{"process_guid": "process-guid-1", "hostnames": [{"hostname": "foo_bar.example.com", "reason": "some reason"}]}
+			]`))
+		})
+	})
+
+	Describe("GET /v1/changes", func() {
+		var path string
+
+		BeforeEach(func() {
+			path = admin.ChangesPath
+
+			for _, processGuid := range []string{"process-guid-1", "process-guid-2", "process-guid-3"} {
+				changeJournal.Append(journal.NewRecord(
+					routing_table.RoutingKey{ProcessGuid: processGuid, ContainerPort: 8080},
+					journal.AddEndpoint,
+					"actual_lrp_created",
+					routing_table.MessagesToEmit{},
+				))
+			}
+		})
+
+		JustBeforeEach(func() {
+			handler.ServeHTTP(recorder, newRequest("GET", path))
+		})
+
+		It("responds with all retained changes", func() {
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			var changes []journal.Record
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &changes)).To(Succeed())
+			Expect(changes).To(HaveLen(2))
+			Expect(changes[0].Sequence).To(BeEquivalentTo(2))
+			Expect(changes[1].Sequence).To(BeEquivalentTo(3))
+		})
+
+		Context("when a sequence is given", func() {
+			BeforeEach(func() {
+				path = admin.ChangesPath + "?since=2"
+			})
+
+			It("responds with the changes after it", func() {
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(recorder.Body.String()).To(MatchJSON(`[{
+					"sequence": 3,
+					"timestamp": 100,
+					"process_guid": "process-guid-3",
+					"container_port": 8080,
+					"operation": "add-endpoint",
+					"source": "actual_lrp_created",
+					"registrations": [],
+					"unregistrations": []
+				}]`))
+			})
+		})
+
+		Context("when the changes have been evicted", func() {
+			BeforeEach(func() {
+				path = admin.ChangesPath + "?since=0"
+			})
+
+			It("responds with 410", func() {
+				Expect(recorder.Code).To(Equal(http.StatusGone))
+			})
+		})
+
+		Context("when the sequence is invalid", func() {
+			BeforeEach(func() {
+				path = admin.ChangesPath + "?since=latest"
+			})
+
+			It("responds with 400", func() {
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			})
+		})
+	})
+
+	Describe("POST /v1/sync", func() {
+		var (
+			method        string
+			authorization string
+		)
+
+		BeforeEach(func() {
+			method = "POST"
+			authorization = "Bearer secret"
+			trigger.TriggerSyncReturns(true)
+		})
+
+		JustBeforeEach(func() {
+			request, err := http.NewRequest(method, admin.SyncPath, nil)
+			Expect(err).NotTo(HaveOccurred())
+			if authorization != "" {
+				request.Header.Set("Authorization", authorization)
+			}
+			handler.ServeHTTP(recorder, request)
+		})
+
+		It("triggers a sync on behalf of the admin API", func() {
+			Expect(recorder.Code).To(Equal(http.StatusAccepted))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"requested":true}`))
+
+			Expect(trigger.TriggerSyncCallCount()).To(Equal(1))
+			Expect(trigger.TriggerSyncArgsForCall(0)).To(Equal(admin.TriggerSource))
+			Expect(trigger.TriggerEmitCallCount()).To(Equal(0))
+		})
+
+		Context("when a sync is already pending", func() {
+			BeforeEach(func() {
+				trigger.TriggerSyncReturns(false)
+			})
+
+			It("responds that the sync was not requested", func() {
+				Expect(recorder.Code).To(Equal(http.StatusAccepted))
+				Expect(recorder.Body.String()).To(MatchJSON(`{"requested":false}`))
+			})
+		})
+
+		Context("without a token", func() {
+			BeforeEach(func() {
+				authorization = ""
+			})
+
+			It("responds with 401 and does not trigger a sync", func() {
+				Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+				Expect(recorder.Header().Get("WWW-Authenticate")).To(HavePrefix("Bearer"))
+				Expect(trigger.TriggerSyncCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("with the wrong token", func() {
+			BeforeEach(func() {
+				authorization = "Bearer guess"
+			})
+
+			It("responds with 401 and does not trigger a sync", func() {
+				Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+				Expect(trigger.TriggerSyncCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("with another method", func() {
+			BeforeEach(func() {
+				method = "GET"
+			})
+
+			It("responds with 405", func() {
+				Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+				Expect(trigger.TriggerSyncCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Describe("POST /v1/emit", func() {
+		JustBeforeEach(func() {
+			handler.ServeHTTP(recorder, newRequest("POST", admin.EmitPath))
+		})
+
+		BeforeEach(func() {
+			trigger.TriggerEmitReturns(true)
+		})
+
+		It("triggers an emit on behalf of the admin API", func() {
+			Expect(recorder.Code).To(Equal(http.StatusAccepted))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"requested":true}`))
+
+			Expect(trigger.TriggerEmitCallCount()).To(Equal(1))
+			Expect(trigger.TriggerEmitArgsForCall(0)).To(Equal(admin.TriggerSource))
+			Expect(trigger.TriggerSyncCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("without a token", func() {
+		It("responds with 401 on every endpoint", func() {
+			for _, path := range []string{admin.DiffPath, admin.ConflictsPath, admin.RejectedRoutesPath, admin.ChangesPath} {
+				recorder := httptest.NewRecorder()
+				request, err := http.NewRequest("GET", path, nil)
+				Expect(err).NotTo(HaveOccurred())
+				handler.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+			}
+			Expect(differ.DiffCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when no token is given", func() {
+		BeforeEach(func() {
+			handler = admin.NewHandler(differ, table, changeJournal, trigger, "", lagertest.NewTestLogger("test"))
+		})
+
+		It("rejects every request", func() {
+			for _, path := range []string{admin.DiffPath, admin.SyncPath, admin.EmitPath} {
+				recorder := httptest.NewRecorder()
+				request, err := http.NewRequest("POST", path, nil)
+				Expect(err).NotTo(HaveOccurred())
+				request.Header.Set("Authorization", "Bearer ")
+				handler.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+			}
+			Expect(differ.DiffCallCount()).To(Equal(0))
+			Expect(trigger.TriggerSyncCallCount()).To(Equal(0))
+			Expect(trigger.TriggerEmitCallCount()).To(Equal(0))
+		})
+	})
+})
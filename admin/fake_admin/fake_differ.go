@@ -0,0 +1,57 @@
+// This file was generated by counterfeiter
+package fake_admin
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/route-emitter/admin"
+	"github.com/cloudfoundry-incubator/route-emitter/watcher"
+	"github.com/pivotal-golang/lager"
+)
+
+type FakeDiffer struct {
+	DiffStub        func(logger lager.Logger) (watcher.DiffReport, error)
+	diffMutex       sync.RWMutex
+	diffArgsForCall []struct {
+		logger lager.Logger
+	}
+	diffReturns struct {
+		result1 watcher.DiffReport
+		result2 error
+	}
+}
+
+func (fake *FakeDiffer) Diff(logger lager.Logger) (watcher.DiffReport, error) {
+	fake.diffMutex.Lock()
+	fake.diffArgsForCall = append(fake.diffArgsForCall, struct {
+		logger lager.Logger
+	}{logger})
+	fake.diffMutex.Unlock()
+	if fake.DiffStub != nil {
+		return fake.DiffStub(logger)
+	} else {
+		return fake.diffReturns.result1, fake.diffReturns.result2
+	}
+}
+
+func (fake *FakeDiffer) DiffCallCount() int {
+	fake.diffMutex.RLock()
+	defer fake.diffMutex.RUnlock()
+	return len(fake.diffArgsForCall)
+}
+
+func (fake *FakeDiffer) DiffArgsForCall(i int) lager.Logger {
+	fake.diffMutex.RLock()
+	defer fake.diffMutex.RUnlock()
+	return fake.diffArgsForCall[i].logger
+}
+
+func (fake *FakeDiffer) DiffReturns(result1 watcher.DiffReport, result2 error) {
+	fake.DiffStub = nil
+	fake.diffReturns = struct {
+		result1 watcher.DiffReport
+		result2 error
+	}{result1, result2}
+}
+
+var _ admin.Differ = new(FakeDiffer)
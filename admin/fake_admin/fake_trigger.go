@@ -0,0 +1,93 @@
+// This file was generated by counterfeiter
+package fake_admin
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/route-emitter/admin"
+)
+
+type FakeTrigger struct {
+	TriggerSyncStub        func(source string) bool
+	triggerSyncMutex       sync.RWMutex
+	triggerSyncArgsForCall []struct {
+		source string
+	}
+	triggerSyncReturns struct {
+		result1 bool
+	}
+	TriggerEmitStub        func(source string) bool
+	triggerEmitMutex       sync.RWMutex
+	triggerEmitArgsForCall []struct {
+		source string
+	}
+	triggerEmitReturns struct {
+		result1 bool
+	}
+}
+
+func (fake *FakeTrigger) TriggerSync(source string) bool {
+	fake.triggerSyncMutex.Lock()
+	fake.triggerSyncArgsForCall = append(fake.triggerSyncArgsForCall, struct {
+		source string
+	}{source})
+	fake.triggerSyncMutex.Unlock()
+	if fake.TriggerSyncStub != nil {
+		return fake.TriggerSyncStub(source)
+	} else {
+		return fake.triggerSyncReturns.result1
+	}
+}
+
+func (fake *FakeTrigger) TriggerSyncCallCount() int {
+	fake.triggerSyncMutex.RLock()
+	defer fake.triggerSyncMutex.RUnlock()
+	return len(fake.triggerSyncArgsForCall)
+}
+
+func (fake *FakeTrigger) TriggerSyncArgsForCall(i int) string {
+	fake.triggerSyncMutex.RLock()
+	defer fake.triggerSyncMutex.RUnlock()
+	return fake.triggerSyncArgsForCall[i].source
+}
+
+func (fake *FakeTrigger) TriggerSyncReturns(result1 bool) {
+	fake.TriggerSyncStub = nil
+	fake.triggerSyncReturns = struct {
+		result1 bool
+	}{result1}
+}
+
+func (fake *FakeTrigger) TriggerEmit(source string) bool {
+	fake.triggerEmitMutex.Lock()
+	fake.triggerEmitArgsForCall = append(fake.triggerEmitArgsForCall, struct {
+		source string
+	}{source})
+	fake.triggerEmitMutex.Unlock()
+	if fake.TriggerEmitStub != nil {
+		return fake.TriggerEmitStub(source)
+	} else {
+		return fake.triggerEmitReturns.result1
+	}
+}
+
+func (fake *FakeTrigger) TriggerEmitCallCount() int {
+	fake.triggerEmitMutex.RLock()
+	defer fake.triggerEmitMutex.RUnlock()
+	return len(fake.triggerEmitArgsForCall)
+}
+
+func (fake *FakeTrigger) TriggerEmitArgsForCall(i int) string {
+	fake.triggerEmitMutex.RLock()
+	defer fake.triggerEmitMutex.RUnlock()
+	return fake.triggerEmitArgsForCall[i].source
+}
+
+func (fake *FakeTrigger) TriggerEmitReturns(result1 bool) {
+	fake.TriggerEmitStub = nil
+	fake.triggerEmitReturns = struct {
+		result1 bool
+	}{result1}
+}
+
+var _ admin.Trigger = new(FakeTrigger)
@@ -10,6 +10,9 @@ const CF_ROUTER = "cf-router"
 
 type CFRoutes []CFRoute
 
+// CFRoute maps hostnames to a container port. A hostname may be followed by
+// a context path, e.g. "example.com/api", to route only requests under that
+// path prefix.
 type CFRoute struct {
 	Hostnames       []string `json:"hostnames"`
 	Port            uint32   `json:"port"`
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/admin"
+	"github.com/cloudfoundry-incubator/route-emitter/watcher"
+)
+
+const (
+	diffInSync = 0
+	diffDrift  = 1
+	diffFailed = 2
+)
+
+// adminTokenEnv is read for the admin token when the -adminToken flag of
+// `route-emitter diff` is not given, to keep the token out of the process list.
+const adminTokenEnv = "ROUTE_EMITTER_ADMIN_TOKEN"
+
+// runDiff implements `route-emitter diff`. It asks a running route-emitter
+// for the difference between its routing table and the BBS, prints the report
+// and, like diff(1), exits 0 if they agree, 1 if they differ and 2 on error.
+func runDiff(args []string) int {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	adminAddress := flags.String(
+		"adminAddress",
+		"127.0.0.1:17011",
+		"host:port of the admin API of the running route-emitter",
+	)
+	adminToken := flags.String(
+		"adminToken",
+		"",
+		"bearer token of the admin API of the running route-emitter. Defaults to $"+adminTokenEnv,
+	)
+	timeout := flags.Duration(
+		"timeout",
+		time.Minute,
+		"Timeout for computing the diff",
+	)
+
+	err := flags.Parse(args)
+	if err != nil {
+		return diffFailed
+	}
+
+	token := *adminToken
+	if token == "" {
+		token = os.Getenv(adminTokenEnv)
+	}
+
+	request, err := http.NewRequest("GET", "http://"+*adminAddress+admin.DiffPath, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid admin address: %s\n", err)
+		return diffFailed
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+
+	client := &http.Client{Timeout: *timeout}
+	resp, err := client.Do(request)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to request diff: %s\n", err)
+		return diffFailed
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "failed to compute diff: admin API responded with status %d\n", resp.StatusCode)
+		return diffFailed
+	}
+
+	var report watcher.DiffReport
+	err = json.NewDecoder(resp.Body).Decode(&report)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to decode diff: %s\n", err)
+		return diffFailed
+	}
+
+	output, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encode diff: %s\n", err)
+		return diffFailed
+	}
+	fmt.Println(string(output))
+
+	if report.InSync() {
+		return diffInSync
+	}
+	return diffDrift
+}
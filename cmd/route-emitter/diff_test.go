@@ -0,0 +1,90 @@
+package main_test
+
+import (
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/route-emitter/admin"
+	"github.com/cloudfoundry-incubator/route-emitter/admin/fake_admin"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table/fake_routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/watcher"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("route-emitter diff", func() {
+	var (
+		differ      *fake_admin.FakeDiffer
+		adminServer *httptest.Server
+		args        []string
+		env         []string
+		session     *gexec.Session
+	)
+
+	BeforeEach(func() {
+		differ = &fake_admin.FakeDiffer{}
+		differ.DiffReturns(watcher.DiffReport{
+			Registrations:      []routing_table.RegistryMessage{},
+			Unregistrations:    []routing_table.RegistryMessage{},
+			TCPRegistrations:   []routing_table.TCPRouteMapping{},
+			TCPUnregistrations: []routing_table.TCPRouteMapping{},
+		}, nil)
+
+		handler := admin.NewHandler(differ, &fake_routing_table.FakeRoutingTable{}, nil, nil, "secret", lagertest.NewTestLogger("test"))
+		adminServer = httptest.NewServer(handler)
+
+		args = []string{"diff", "-adminAddress", strings.TrimPrefix(adminServer.URL, "http://")}
+		env = os.Environ()
+	})
+
+	AfterEach(func() {
+		adminServer.Close()
+	})
+
+	JustBeforeEach(func() {
+		command := exec.Command(emitterPath, args...)
+		command.Env = env
+
+		var err error
+		session, err = gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Context("with the admin token", func() {
+		BeforeEach(func() {
+			args = append(args, "-adminToken", "secret")
+		})
+
+		It("prints the diff and exits 0 when the routing table is in sync", func() {
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say(`"registrations"`))
+			Expect(differ.DiffCallCount()).To(Equal(1))
+		})
+	})
+
+	Context("with the admin token in the environment", func() {
+		BeforeEach(func() {
+			env = append(env, "ROUTE_EMITTER_ADMIN_TOKEN=secret")
+		})
+
+		It("authenticates with it", func() {
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(differ.DiffCallCount()).To(Equal(1))
+		})
+	})
+
+	Context("without the admin token", func() {
+		It("exits 2", func() {
+			Eventually(session).Should(gexec.Exit(2))
+			Expect(session.Err).To(gbytes.Say("status 401"))
+			Expect(differ.DiffCallCount()).To(Equal(0))
+		})
+	})
+})
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"net/http"
 	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/cloudfoundry-incubator/bbs"
@@ -13,18 +17,26 @@ import (
 	"github.com/cloudfoundry-incubator/consuladapter"
 	"github.com/cloudfoundry-incubator/locket"
 	route_emitter "github.com/cloudfoundry-incubator/route-emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/admin"
+	"github.com/cloudfoundry-incubator/route-emitter/journal"
 	"github.com/cloudfoundry-incubator/route-emitter/nats_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/persister"
+	"github.com/cloudfoundry-incubator/route-emitter/route_sink"
+	"github.com/cloudfoundry-incubator/route-emitter/route_snapshot"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_api_emitter"
 	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
 	"github.com/cloudfoundry-incubator/route-emitter/syncer"
+	"github.com/cloudfoundry-incubator/route-emitter/tcp_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/template_emitter"
 	"github.com/cloudfoundry-incubator/route-emitter/watcher"
 	"github.com/cloudfoundry/dropsonde"
 	"github.com/cloudfoundry/gunk/diegonats"
-	"github.com/cloudfoundry/gunk/workpool"
 	"github.com/nu7hatch/gouuid"
 	"github.com/pivotal-golang/clock"
 	"github.com/pivotal-golang/lager"
 	"github.com/tedsuo/ifrit"
 	"github.com/tedsuo/ifrit/grouper"
+	"github.com/tedsuo/ifrit/http_server"
 	"github.com/tedsuo/ifrit/sigmon"
 )
 
@@ -82,6 +94,48 @@ var syncInterval = flag.Duration(
 	"the interval between syncs of the routing table from etcd",
 )
 
+var minEmitInterval = flag.Duration(
+	"minEmitInterval",
+	time.Second,
+	"the shortest interval between emits of the routing table, however short the routers' prune thresholds",
+)
+
+var maxEmitInterval = flag.Duration(
+	"maxEmitInterval",
+	0,
+	"the longest interval between emits of the routing table, however long the routers' prune thresholds and minimum register intervals. If 0, the interval is not bounded",
+)
+
+var emitJitter = flag.Float64(
+	"emitJitter",
+	0,
+	"the largest fraction (between 0 and 1) by which each interval between emits of the routing table is randomly shortened",
+)
+
+var greetingTimeout = flag.Duration(
+	"greetingTimeout",
+	0,
+	"how long to wait for a router to answer the greetings before syncing and emitting at the defaultEmitInterval. If 0, nothing is emitted until a router answers, unless emitToRoutingAPI is set",
+)
+
+var defaultEmitInterval = flag.Duration(
+	"defaultEmitInterval",
+	20*time.Second,
+	"the interval between emits of the routing table after the greetingTimeout, until a router answers the greetings",
+)
+
+var greetingRetryInterval = flag.Duration(
+	"greetingRetryInterval",
+	time.Second,
+	"the interval between greetings of the routers until one answers, and the delay before greeting them again after a failure",
+)
+
+var maxGreetingRetryInterval = flag.Duration(
+	"maxGreetingRetryInterval",
+	30*time.Second,
+	"the longest delay before greeting the routers again after repeated failures",
+)
+
 var communicationTimeout = flag.Duration(
 	"communicationTimeout",
 	30*time.Second,
@@ -121,7 +175,217 @@ var bbsMaxIdleConnsPerHost = flag.Int(
 var routeEmittingWorkers = flag.Int(
 	"routeEmittingWorkers",
 	20,
-	"Max concurrency for sending route messages",
+	"Deprecated and ignored: route messages are published to NATS in order, one at a time",
+)
+
+var natsPublishRate = flag.Int(
+	"natsPublishRate",
+	0,
+	"maximum number of route messages published to NATS per second. If 0, publishes are not limited",
+)
+
+var natsPublishBurst = flag.Int(
+	"natsPublishBurst",
+	0,
+	"number of route messages that may be published to NATS at once before natsPublishRate applies. If 0, it is the same as natsPublishRate",
+)
+
+var natsPublishRetries = flag.Int(
+	"natsPublishRetries",
+	2,
+	"number of times a failed NATS publish is retried before the message is dead-lettered",
+)
+
+var natsPublishRetryInterval = flag.Duration(
+	"natsPublishRetryInterval",
+	100*time.Millisecond,
+	"delay before the first retry of a failed NATS publish, doubling with every further retry",
+)
+
+var natsPublishMaxRetryInterval = flag.Duration(
+	"natsPublishMaxRetryInterval",
+	time.Second,
+	"maximum delay between retries of a failed NATS publish",
+)
+
+var natsDeadLetterCapacity = flag.Int(
+	"natsDeadLetterCapacity",
+	1000,
+	"number of route messages that failed to publish to keep for another attempt once NATS is reachable. If 0, such messages are dropped",
+)
+
+var natsDeadLetterRetryInterval = flag.Duration(
+	"natsDeadLetterRetryInterval",
+	5*time.Second,
+	"how often to check whether NATS is reachable again to publish the route messages that failed to publish",
+)
+
+var natsDrainTimeout = flag.Duration(
+	"natsDrainTimeout",
+	5*time.Second,
+	"how long to keep publishing the queued route messages to NATS on shutdown",
+)
+
+var emitToNATS = flag.Bool(
+	"emitToNATS",
+	true,
+	"emit routes to the gorouter over NATS",
+)
+
+var routeSinkURL = flag.String(
+	"routeSinkURL",
+	"",
+	"URL to which batches of route registrations and unregistrations are POSTed as JSON. If empty, routes are not emitted over HTTP",
+)
+
+var routeSinkFile = flag.String(
+	"routeSinkFile",
+	"",
+	"path to a file to which route registrations and unregistrations are appended as lines of JSON. If empty, routes are not written to a file",
+)
+
+var routeConfigTemplate = flag.String(
+	"routeConfigTemplate",
+	"",
+	"path to a text/template that routes are rendered through into routeConfigFile, e.g. for nginx or HAProxy. If empty, no config file is rendered",
+)
+
+var routeConfigFile = flag.String(
+	"routeConfigFile",
+	"",
+	"path of the config file rendered from routeConfigTemplate",
+)
+
+var routeConfigReloadCommand = flag.String(
+	"routeConfigReloadCommand",
+	"",
+	"shell command run after routeConfigFile changes, e.g. to reload nginx",
+)
+
+var routeConfigDebounce = flag.Duration(
+	"routeConfigDebounce",
+	time.Second,
+	"how long route changes are coalesced before routeConfigFile is rendered",
+)
+
+var optionalRouteSinks = flag.String(
+	"optionalRouteSinks",
+	"",
+	"comma-separated list of route sinks (nats, routing-api, http, file, template) whose failures are logged but do not fail the emit. At least one enabled route sink must not be optional, unless TCP routes are emitted to a routingAPIURL",
+)
+
+var routingAPIURL = flag.String(
+	"routingAPIURL",
+	"",
+	"URL of the routing API used to register TCP routes, and HTTP routes if emitToRoutingAPI is set. If empty, TCP routes are not emitted",
+)
+
+var emitToRoutingAPI = flag.Bool(
+	"emitToRoutingAPI",
+	false,
+	"emit HTTP routes to the routing API at routingAPIURL",
+)
+
+var routingAPIPruneInterval = flag.Duration(
+	"routingAPIPruneInterval",
+	40*time.Second,
+	"the longest interval between registrations of the routes with the routing API, which are registered with a TTL of three times this interval. With emitToRoutingAPI or a routingAPIURL for TCP routes, it bounds maxEmitInterval, and is the greetingTimeout unless one is given",
+)
+
+var routingAPIBatchSize = flag.Int(
+	"routingAPIBatchSize",
+	100,
+	"maximum number of routes sent to the routing API in a single request. If 0, routes are not batched",
+)
+
+var routingAPITokenURL = flag.String(
+	"routingAPITokenURL",
+	"",
+	"URL of the OAuth token endpoint used to authenticate with the routing API. If empty, requests are not authenticated",
+)
+
+var routingAPIClientID = flag.String(
+	"routingAPIClientID",
+	"",
+	"OAuth client id used to fetch routing API tokens",
+)
+
+var routingAPIClientSecret = flag.String(
+	"routingAPIClientSecret",
+	"",
+	"OAuth client secret used to fetch routing API tokens",
+)
+
+var routingTableSnapshotPath = flag.String(
+	"routingTableSnapshotPath",
+	"",
+	"path to a file where the routing table is periodically snapshotted and from which it is restored, and emitted, on startup. TCP routes are not persisted; they are emitted after the first sync. If empty, the routing table is not persisted",
+)
+
+var routingTableSnapshotInterval = flag.Duration(
+	"routingTableSnapshotInterval",
+	30*time.Second,
+	"the interval between snapshots of the routing table",
+)
+
+var registryMessageTags = flag.String(
+	"registryMessageTags",
+	"",
+	"comma-separated list of endpoint tags to include in route registrations (instance_index, cell_id, domain, process_guid, evacuating, availability_zone)",
+)
+
+var registryMessageVersion = flag.Int(
+	"registryMessageVersion",
+	int(routing_table.RegistryMessageV1),
+	"version of the route registration messages: 1, or 2 to include the modification tag, stale threshold and update time of each endpoint",
+)
+
+var registryMessageStaleThreshold = flag.Duration(
+	"registryMessageStaleThreshold",
+	120*time.Second,
+	"how long routers should keep a route without hearing from the emitter again, advertised in version 2 registration messages. Every router receives the same value, so it should be at least the longest prune threshold of the routers",
+)
+
+var hostnameConflictPolicy = flag.String(
+	"hostnameConflictPolicy",
+	string(routing_table.ConflictPolicyAllow),
+	"what to do when different process guids claim the same hostname (allow, warn, first-owner-wins)",
+)
+
+var changeJournalSize = flag.Int(
+	"changeJournalSize",
+	1000,
+	"number of recent routing table changes to keep in the change journal. If 0, changes are not journaled",
+)
+
+var emitWindow = flag.Duration(
+	"emitWindow",
+	0,
+	"how long route changes are coalesced before being emitted, cancelling out registrations and unregistrations of the same route. If 0, changes are emitted immediately",
+)
+
+var adminListenAddr = flag.String(
+	"adminListenAddr",
+	"",
+	"host:port to serve the admin API on (e.g. the routing table diff, hostname conflicts, rejected routes and recent changes). If empty, the admin API is disabled",
+)
+
+var adminToken = flag.String(
+	"adminToken",
+	"",
+	"bearer token required by every endpoint of the admin API, including requesting an immediate sync or emit. Required if adminListenAddr is set",
+)
+
+var routeSnapshotListenAddr = flag.String(
+	"routeSnapshotListenAddr",
+	"",
+	"host:port to serve snapshots of the routes on, for proxies that pull their routes. If empty, snapshots are not served",
+)
+
+var routeSnapshotLongPollTimeout = flag.Duration(
+	"routeSnapshotLongPollTimeout",
+	30*time.Second,
+	"how long a request for a route snapshot with a current If-None-Match header waits for the routes to change",
 )
 
 const (
@@ -130,6 +394,10 @@ const (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		os.Exit(runDiff(os.Args[2:]))
+	}
+
 	cf_debug_server.AddFlags(flag.CommandLine)
 	cf_lager.AddFlags(flag.CommandLine)
 	flag.Parse()
@@ -139,20 +407,30 @@ func main() {
 	logger, reconfigurableSink := cf_lager.New(*sessionName)
 	natsClient := diegonats.NewClient()
 	clock := clock.NewClock()
-	syncer := syncer.NewSyncer(clock, *syncInterval, natsClient, logger)
+	routeSyncer := initializeSyncer(clock, natsClient, logger)
 
 	initializeDropsonde(logger)
 
 	natsClientRunner := diegonats.NewClientRunner(*natsAddresses, *natsUsername, *natsPassword, logger, natsClient)
 
-	table := initializeRoutingTable()
-	emitter := initializeNatsEmitter(natsClient, logger)
-	watcher := ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
-		return watcher.NewWatcher(initializeBBSClient(logger), clock, table, emitter, syncer.Events(), logger).Run(signals, ready)
-	})
+	table := initializeRoutingTable(logger)
+	if table.RouteCount() > 0 {
+		// emit the restored routes as soon as the watcher runs, rather than
+		// only once a router has greeted us and the emit interval has passed
+		routeSyncer.TriggerEmit("restored-routing-table")
+	}
+	tcpTable := initializeTCPRoutingTable()
+	natsEmitter := initializeNatsEmitter(natsClient, clock, logger)
+	templateEmitter := initializeTemplateEmitter(table, clock, logger)
+	snapshotServer := initializeRouteSnapshotServer(table, clock, logger)
+	tokenFetcher := initializeRoutingAPITokenFetcher(clock, logger)
+	tcpEmitter := initializeTCPEmitter(tokenFetcher, logger)
+	emitter := initializeRouteSink(natsEmitter, templateEmitter, snapshotServer, tcpEmitter, tokenFetcher, clock, logger)
+	changeJournal := initializeChangeJournal(clock)
+	routeWatcher := watcher.NewWatcher(initializeBBSClient(logger), clock, table, tcpTable, emitter, tcpEmitter, routeSyncer.Events(), changeJournal, *emitWindow, logger)
 
 	syncRunner := ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
-		return syncer.Run(signals, ready)
+		return routeSyncer.Run(signals, ready)
 	})
 
 	lockMaintainer := initializeLockMaintainer(logger, *consulCluster, *sessionName, *lockTTL, *lockRetryInterval, clock)
@@ -160,8 +438,45 @@ func main() {
 	members := grouper.Members{
 		{"lock-maintainer", lockMaintainer},
 		{"nats-client", natsClientRunner},
-		{"watcher", watcher},
+	}
+
+	if *routingTableSnapshotPath != "" {
+		members = append(members, grouper.Member{
+			"persister", persister.New(*routingTableSnapshotPath, *routingTableSnapshotInterval, table, clock, logger),
+		})
+	}
+
+	if natsEmitter != nil {
+		members = append(members, grouper.Member{"nats-emitter", natsEmitter})
+	}
+
+	if templateEmitter != nil {
+		members = append(members, grouper.Member{"template-emitter", templateEmitter})
+	}
+
+	members = append(members, grouper.Members{
+		{"watcher", routeWatcher},
 		{"syncer", syncRunner},
+		{"signal-trigger", syncer.NewSignalTrigger(routeSyncer, logger)},
+	}...)
+
+	if *adminListenAddr != "" {
+		if *adminToken == "" {
+			logger.Fatal("missing-admin-token", errors.New("adminToken is required to serve the admin API"))
+		}
+
+		adminHandler := admin.NewHandler(routeWatcher, table, changeJournal, routeSyncer, *adminToken, logger)
+		members = append(members, grouper.Member{
+			"admin-server", http_server.New(*adminListenAddr, adminHandler),
+		})
+	}
+
+	if snapshotServer != nil {
+		mux := http.NewServeMux()
+		mux.Handle(route_snapshot.RoutesPath, snapshotServer)
+		members = append(members, grouper.Member{
+			"route-snapshot-server", http_server.New(*routeSnapshotListenAddr, mux),
+		})
 	}
 
 	if dbgAddr := cf_debug_server.DebugAddress(flag.CommandLine); dbgAddr != "" {
@@ -185,6 +500,55 @@ func main() {
 	logger.Info("exited")
 }
 
+func initializeSyncer(clock clock.Clock, natsClient diegonats.NATSClient, logger lager.Logger) *syncer.Syncer {
+	if *emitJitter < 0 || *emitJitter >= 1 {
+		logger.Fatal("invalid-emit-jitter", fmt.Errorf("emitJitter must be at least 0 and less than 1, got %v", *emitJitter))
+	}
+
+	if *minEmitInterval < 0 {
+		logger.Fatal("invalid-min-emit-interval", fmt.Errorf("minEmitInterval must not be negative, got %s", *minEmitInterval))
+	}
+
+	if *maxEmitInterval < 0 || (*maxEmitInterval > 0 && *maxEmitInterval < *minEmitInterval) {
+		logger.Fatal("invalid-max-emit-interval", fmt.Errorf("maxEmitInterval must be 0 or at least minEmitInterval (%s), got %s", *minEmitInterval, *maxEmitInterval))
+	}
+
+	options := syncer.Options{
+		MinEmitInterval: *minEmitInterval,
+		MaxEmitInterval: *maxEmitInterval,
+		EmitJitter:      *emitJitter,
+
+		GreetingTimeout:     *greetingTimeout,
+		DefaultEmitInterval: *defaultEmitInterval,
+
+		GreetingRetryInterval:    *greetingRetryInterval,
+		MaxGreetingRetryInterval: *maxGreetingRetryInterval,
+	}
+
+	if *emitToRoutingAPI || *routingAPIURL != "" {
+		// the routing API expires routes, HTTP and TCP alike, that are not
+		// registered again within their TTL, however rarely the routers ask
+		// for registrations, and whether or not any router greets us
+		if *routingAPIPruneInterval < *minEmitInterval {
+			logger.Fatal("invalid-routing-api-prune-interval", fmt.Errorf("routingAPIPruneInterval must be at least minEmitInterval (%s), got %s", *minEmitInterval, *routingAPIPruneInterval))
+		}
+
+		if options.MaxEmitInterval == 0 || options.MaxEmitInterval > *routingAPIPruneInterval {
+			options.MaxEmitInterval = *routingAPIPruneInterval
+		}
+		if options.GreetingTimeout == 0 {
+			options.GreetingTimeout = *routingAPIPruneInterval
+		}
+
+		logger.Info("bounded-emit-interval-for-routing-api", lager.Data{
+			"max-emit-interval": options.MaxEmitInterval.String(),
+			"greeting-timeout":  options.GreetingTimeout.String(),
+		})
+	}
+
+	return syncer.NewSyncerWithOptions(clock, *syncInterval, natsClient, options, logger)
+}
+
 func initializeDropsonde(logger lager.Logger) {
 	err := dropsonde.Initialize(dropsondeDestination, dropsondeOrigin)
 	if err != nil {
@@ -192,17 +556,252 @@ func initializeDropsonde(logger lager.Logger) {
 	}
 }
 
-func initializeNatsEmitter(natsClient diegonats.NATSClient, logger lager.Logger) nats_emitter.NATSEmitter {
-	workPool, err := workpool.NewWorkPool(*routeEmittingWorkers)
+func initializeNatsEmitter(natsClient diegonats.NATSClient, clock clock.Clock, logger lager.Logger) nats_emitter.NATSEmitter {
+	if !*emitToNATS {
+		return nil
+	}
+
+	return nats_emitter.NewWithOptions(natsClient, nats_emitter.Options{
+		PublishRate:             *natsPublishRate,
+		PublishBurst:            *natsPublishBurst,
+		MaxRetries:              *natsPublishRetries,
+		RetryInterval:           *natsPublishRetryInterval,
+		MaxRetryInterval:        *natsPublishMaxRetryInterval,
+		DeadLetterCapacity:      *natsDeadLetterCapacity,
+		DeadLetterRetryInterval: *natsDeadLetterRetryInterval,
+		DrainTimeout:            *natsDrainTimeout,
+		Clock:                   clock,
+	}, logger)
+}
+
+func initializeRouteSink(
+	natsEmitter nats_emitter.NATSEmitter,
+	templateEmitter *template_emitter.TemplateEmitter,
+	snapshotServer *route_snapshot.Server,
+	tcpEmitter tcp_emitter.TCPEmitter,
+	tokenFetcher routing_api_emitter.TokenFetcher,
+	clock clock.Clock,
+	logger lager.Logger,
+) route_sink.RouteSink {
+	optional := map[string]bool{}
+	for _, name := range strings.Split(*optionalRouteSinks, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "":
+		case "nats", "routing-api", "http", "file", "template":
+			optional[name] = true
+		default:
+			logger.Fatal("invalid-optional-route-sinks", fmt.Errorf("unknown route sink: %s", name))
+		}
+	}
+
+	backends := []route_sink.Backend{}
+
+	if natsEmitter != nil {
+		backends = append(backends, route_sink.Backend{
+			Name:     "NATS",
+			Sink:     natsEmitter,
+			Optional: optional["nats"],
+		})
+	}
+
+	if *emitToRoutingAPI {
+		backends = append(backends, route_sink.Backend{
+			Name:     "RoutingAPI",
+			Sink:     initializeRoutingAPIEmitter(tokenFetcher, logger),
+			Optional: optional["routing-api"],
+		})
+	}
+
+	if *routeSinkURL != "" {
+		_, err := url.Parse(*routeSinkURL)
+		if err != nil {
+			logger.Fatal("invalid-route-sink-url", err)
+		}
+
+		backends = append(backends, route_sink.Backend{
+			Name:     "HTTP",
+			Sink:     route_sink.NewHTTPSink(*routeSinkURL, cf_http.NewClient(), logger),
+			Optional: optional["http"],
+		})
+	}
+
+	if *routeSinkFile != "" {
+		backends = append(backends, route_sink.Backend{
+			Name:     "File",
+			Sink:     route_sink.NewFileSink(*routeSinkFile, clock, logger),
+			Optional: optional["file"],
+		})
+	}
+
+	if templateEmitter != nil {
+		backends = append(backends, route_sink.Backend{
+			Name:     "Template",
+			Sink:     templateEmitter,
+			Optional: optional["template"],
+		})
+	}
+
+	if snapshotServer != nil {
+		// only wakes up long-polling clients, and never fails
+		backends = append(backends, route_sink.Backend{
+			Name:     "RouteSnapshot",
+			Sink:     snapshotServer,
+			Optional: true,
+		})
+	}
+
+	// a TCP-only deployment emits its routes through the TCP emitter alone
+	if tcpEmitter == nil && !hasRequiredBackend(backends) {
+		logger.Fatal("no-required-route-sink", errors.New("at least one route sink must be enabled and not listed in optionalRouteSinks, or TCP routes emitted to a routingAPIURL"))
+	}
+
+	return route_sink.NewFanOut(backends, clock, logger)
+}
+
+func hasRequiredBackend(backends []route_sink.Backend) bool {
+	for _, backend := range backends {
+		if !backend.Optional {
+			return true
+		}
+	}
+	return false
+}
+
+func initializeRouteSnapshotServer(table routing_table.RoutingTable, clock clock.Clock, logger lager.Logger) *route_snapshot.Server {
+	if *routeSnapshotListenAddr == "" {
+		return nil
+	}
+
+	return route_snapshot.New(table, *routeSnapshotLongPollTimeout, clock, logger)
+}
+
+func initializeTemplateEmitter(table routing_table.RoutingTable, clock clock.Clock, logger lager.Logger) *template_emitter.TemplateEmitter {
+	if *routeConfigTemplate == "" {
+		return nil
+	}
+
+	if *routeConfigFile == "" {
+		logger.Fatal("missing-route-config-file", errors.New("routeConfigTemplate requires a routeConfigFile"))
+	}
+
+	templateEmitter, err := template_emitter.New(table, *routeConfigTemplate, *routeConfigFile, *routeConfigReloadCommand, *routeConfigDebounce, clock, logger)
+	if err != nil {
+		logger.Fatal("invalid-route-config-template", err)
+	}
+
+	return templateEmitter
+}
+
+func initializeRoutingAPIEmitter(tokenFetcher routing_api_emitter.TokenFetcher, logger lager.Logger) route_sink.RouteSink {
+	if *routingAPIURL == "" {
+		logger.Fatal("missing-routing-api-url", errors.New("emitToRoutingAPI requires a routingAPIURL"))
+	}
+
+	_, err := url.Parse(*routingAPIURL)
+	if err != nil {
+		logger.Fatal("invalid-routing-api-url", err)
+	}
+
+	return routing_api_emitter.New(
+		*routingAPIURL,
+		cf_http.NewClient(),
+		tokenFetcher,
+		*routingAPIPruneInterval,
+		*routingAPIBatchSize,
+		logger,
+	)
+}
+
+func initializeRoutingAPITokenFetcher(clock clock.Clock, logger lager.Logger) routing_api_emitter.TokenFetcher {
+	if *routingAPITokenURL == "" {
+		return nil
+	}
+
+	return routing_api_emitter.NewTokenFetcher(
+		*routingAPITokenURL,
+		*routingAPIClientID,
+		*routingAPIClientSecret,
+		cf_http.NewClient(),
+		clock,
+		logger,
+	)
+}
+
+func initializeTCPEmitter(tokenFetcher routing_api_emitter.TokenFetcher, logger lager.Logger) tcp_emitter.TCPEmitter {
+	if *routingAPIURL == "" {
+		return nil
+	}
+
+	_, err := url.Parse(*routingAPIURL)
+	if err != nil {
+		logger.Fatal("invalid-routing-api-url", err)
+	}
+
+	return tcp_emitter.New(*routingAPIURL, cf_http.NewClient(), tokenFetcher, *routingAPIPruneInterval, logger)
+}
+
+func initializeRoutingTable(logger lager.Logger) routing_table.RoutingTable {
+	endpointTags, err := routing_table.ParseEndpointTags(*registryMessageTags)
+	if err != nil {
+		logger.Fatal("invalid-registry-message-tags", err)
+	}
+
+	conflictPolicy, err := routing_table.ParseConflictPolicy(*hostnameConflictPolicy)
 	if err != nil {
-		logger.Fatal("failed-to-construct-nats-emitter-workpool", err, lager.Data{"num-workers": *routeEmittingWorkers}) // should never happen
+		logger.Fatal("invalid-hostname-conflict-policy", err)
+	}
+
+	messageVersion, err := routing_table.ParseRegistryMessageVersion(*registryMessageVersion)
+	if err != nil {
+		logger.Fatal("invalid-registry-message-version", err)
+	}
+
+	options := routing_table.TableOptions{
+		EndpointTags:   endpointTags,
+		ConflictPolicy: conflictPolicy,
+		MessageVersion: messageVersion,
+		StaleThreshold: *registryMessageStaleThreshold,
+		Logger:         logger,
+	}
+
+	if *routingTableSnapshotPath == "" {
+		return routing_table.NewTableWithOptions(options)
+	}
+
+	logger = logger.Session("restore-routing-table", lager.Data{"path": *routingTableSnapshotPath})
+
+	snapshot, err := persister.Load(*routingTableSnapshotPath)
+	if os.IsNotExist(err) {
+		logger.Info("no-snapshot-found")
+		return routing_table.NewTableWithOptions(options)
+	}
+
+	if err != nil {
+		logger.Error("discarding-snapshot", err)
+		err = os.Remove(*routingTableSnapshotPath)
+		if err != nil {
+			logger.Error("failed-to-remove-snapshot", err)
+		}
+		return routing_table.NewTableWithOptions(options)
+	}
+
+	table := routing_table.NewTableFromSnapshot(snapshot, options)
+	logger.Info("restored", lager.Data{"route-count": table.RouteCount()})
+
+	return table
+}
+
+func initializeChangeJournal(clock clock.Clock) *journal.Journal {
+	if *changeJournalSize <= 0 {
+		return nil
 	}
 
-	return nats_emitter.New(natsClient, workPool, logger)
+	return journal.New(*changeJournalSize, clock)
 }
 
-func initializeRoutingTable() routing_table.RoutingTable {
-	return routing_table.NewTable()
+func initializeTCPRoutingTable() routing_table.TCPRoutingTable {
+	return routing_table.NewTCPTable()
 }
 
 func initializeLockMaintainer(
@@ -59,18 +59,19 @@ func TestRouteEmitter(t *testing.T) {
 	RunSpecs(t, "Route Emitter Suite")
 }
 
-func createEmitterRunner(sessionName string) *ginkgomon.Runner {
+func createEmitterRunner(sessionName string, extraArgs ...string) *ginkgomon.Runner {
+	args := []string{
+		"-sessionName", sessionName,
+		"-natsAddresses", fmt.Sprintf("127.0.0.1:%d", natsPort),
+		"-bbsAddress", bbsURL.String(),
+		"-communicationTimeout", "100ms",
+		"-syncInterval", syncInterval.String(),
+		"-lockRetryInterval", "1s",
+		"-consulCluster", consulRunner.ConsulCluster(),
+	}
+
 	return ginkgomon.New(ginkgomon.Config{
-		Command: exec.Command(
-			string(emitterPath),
-			"-sessionName", sessionName,
-			"-natsAddresses", fmt.Sprintf("127.0.0.1:%d", natsPort),
-			"-bbsAddress", bbsURL.String(),
-			"-communicationTimeout", "100ms",
-			"-syncInterval", syncInterval.String(),
-			"-lockRetryInterval", "1s",
-			"-consulCluster", consulRunner.ConsulCluster(),
-		),
+		Command: exec.Command(string(emitterPath), append(args, extraArgs...)...),
 
 		StartCheck: "route-emitter.started",
 
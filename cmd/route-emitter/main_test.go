@@ -2,16 +2,21 @@ package main_test
 
 import (
 	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/apcera/nats"
 	"github.com/cloudfoundry-incubator/bbs/models"
 	"github.com/cloudfoundry-incubator/routing-info/cfroutes"
+	"github.com/cloudfoundry-incubator/route-emitter/persister"
 	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
 	. "github.com/cloudfoundry-incubator/route-emitter/routing_table/matchers"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/ghttp"
 	"github.com/tedsuo/ifrit"
 	"github.com/tedsuo/ifrit/ginkgomon"
 )
@@ -260,6 +265,87 @@ var _ = Describe("Route Emitter", func() {
 		})
 	})
 
+	Context("when the emitter starts from a snapshot of the routing table", func() {
+		var (
+			emitter ifrit.Process
+			tmpDir  string
+		)
+
+		BeforeEach(func() {
+			var err error
+			tmpDir, err = ioutil.TempDir("", "route-emitter")
+			Expect(err).NotTo(HaveOccurred())
+
+			snapshotPath := filepath.Join(tmpDir, "routing-table")
+			err = persister.Save(snapshotPath, routing_table.Snapshot{
+				Entries: []routing_table.SnapshotEntry{{
+					ProcessGuid:   "restored-guid",
+					ContainerPort: 8080,
+					Hostnames:     []string{"restored-route"},
+					LogGuid:       "restored-log-guid",
+					Endpoints: []routing_table.Endpoint{
+						{InstanceGuid: "restored-iguid", Host: "5.6.7.8", Port: 61000, ContainerPort: 8080},
+					},
+				}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			runner := createEmitterRunner("emitter1", "-routingTableSnapshotPath", snapshotPath)
+			runner.StartCheck = "emitter1.started"
+			emitter = ginkgomon.Invoke(runner)
+		})
+
+		AfterEach(func() {
+			ginkgomon.Interrupt(emitter, emitterInterruptTimeout)
+			os.RemoveAll(tmpDir)
+		})
+
+		It("emits the restored routes without waiting for the emit interval", func() {
+			Eventually(registeredRoutes).Should(Receive(MatchRegistryMessage(routing_table.RegistryMessage{
+				URIs:              []string{"restored-route"},
+				Host:              "5.6.7.8",
+				Port:              61000,
+				App:               "restored-log-guid",
+				PrivateInstanceId: "restored-iguid",
+			})))
+		})
+	})
+
+	Context("when routes are not emitted to NATS", func() {
+		It("refuses to start without another required route sink", func() {
+			runner := createEmitterRunner("emitter1", "-emitToNATS=false")
+			emitter := ifrit.Background(runner)
+
+			Eventually(emitter.Wait()).Should(Receive(HaveOccurred()))
+			Expect(runner.Buffer()).To(gbytes.Say("no-required-route-sink"))
+		})
+
+		Context("and TCP routes are emitted to the routing API", func() {
+			var (
+				emitter          ifrit.Process
+				routingAPIServer *ghttp.Server
+			)
+
+			BeforeEach(func() {
+				routingAPIServer = ghttp.NewServer()
+				routingAPIServer.AllowUnhandledRequests = true
+
+				runner := createEmitterRunner("emitter1", "-emitToNATS=false", "-routingAPIURL", routingAPIServer.URL())
+				runner.StartCheck = "emitter1.started"
+				emitter = ginkgomon.Invoke(runner)
+			})
+
+			AfterEach(func() {
+				ginkgomon.Interrupt(emitter, emitterInterruptTimeout)
+				routingAPIServer.Close()
+			})
+
+			It("starts", func() {
+				Consistently(emitter.Wait()).ShouldNot(Receive())
+			})
+		})
+	})
+
 	Context("when the legacyBBS has routes to emit in /desired and /actual", func() {
 		var emitter ifrit.Process
 
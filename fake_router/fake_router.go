@@ -0,0 +1,242 @@
+// Package fake_router provides an in-memory router that speaks the route
+// registration protocol over a NATS client, so that the syncer, watcher and
+// emitters can be tested together against it.
+package fake_router
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apcera/nats"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry/gunk/diegonats"
+	"github.com/pivotal-golang/clock"
+)
+
+type Config struct {
+	// Id identifies the router in its greetings, so that several fake
+	// routers can be told apart.
+	Id string
+
+	// MinimumRegisterInterval and PruneThreshold are advertised in the
+	// router's greetings. Routes that have not been registered again within
+	// the PruneThreshold are pruned.
+	MinimumRegisterInterval time.Duration
+	PruneThreshold          time.Duration
+
+	// PruneInterval is how often stale routes are pruned. It defaults to the
+	// PruneThreshold.
+	PruneInterval time.Duration
+}
+
+// Endpoint is a backend that a route resolves to.
+type Endpoint struct {
+	Host string
+	Port uint32
+}
+
+// FakeRouter answers router.greet requests, announces itself with
+// router.start when it starts, and applies router.register and
+// router.unregister messages to its route table. It is an ifrit.Runner.
+type FakeRouter struct {
+	natsClient diegonats.NATSClient
+	clock      clock.Clock
+	config     Config
+
+	lock            sync.Mutex
+	routes          map[string]map[Endpoint]time.Time
+	greetings       int
+	registrations   int
+	unregistrations int
+}
+
+func New(natsClient diegonats.NATSClient, clock clock.Clock, config Config) *FakeRouter {
+	if config.PruneInterval == 0 {
+		config.PruneInterval = config.PruneThreshold
+	}
+
+	return &FakeRouter{
+		natsClient: natsClient,
+		clock:      clock,
+		config:     config,
+		routes:     map[string]map[Endpoint]time.Time{},
+	}
+}
+
+func (router *FakeRouter) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	handlers := []struct {
+		subject string
+		handler nats.MsgHandler
+	}{
+		{"router.greet", router.handleGreet},
+		{"router.register", router.handleRegister},
+		{"router.unregister", router.handleUnregister},
+	}
+
+	for _, h := range handlers {
+		sub, err := router.natsClient.Subscribe(h.subject, h.handler)
+		if err != nil {
+			return err
+		}
+		defer router.natsClient.Unsubscribe(sub)
+	}
+
+	close(ready)
+
+	router.Announce()
+
+	pruneTicker := router.clock.NewTicker(router.config.PruneInterval)
+	defer pruneTicker.Stop()
+
+	for {
+		select {
+		case <-pruneTicker.C():
+			router.Prune()
+		case <-signals:
+			return nil
+		}
+	}
+}
+
+// Announce publishes router.start, as a router does when it starts.
+func (router *FakeRouter) Announce() {
+	router.publish("router.start")
+}
+
+// Prune removes the routes that have not been registered within the prune
+// threshold.
+func (router *FakeRouter) Prune() {
+	router.lock.Lock()
+	defer router.lock.Unlock()
+
+	now := router.clock.Now()
+	for uri, endpoints := range router.routes {
+		for endpoint, registeredAt := range endpoints {
+			if now.Sub(registeredAt) > router.config.PruneThreshold {
+				delete(endpoints, endpoint)
+			}
+		}
+		if len(endpoints) == 0 {
+			delete(router.routes, uri)
+		}
+	}
+}
+
+// Lookup returns the endpoints the route resolves to, sorted by host and port.
+func (router *FakeRouter) Lookup(uri string) []Endpoint {
+	router.lock.Lock()
+	defer router.lock.Unlock()
+
+	endpoints := []Endpoint{}
+	for endpoint := range router.routes[uri] {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Sort(byAddress(endpoints))
+
+	return endpoints
+}
+
+// URIs returns the routes in the route table, sorted.
+func (router *FakeRouter) URIs() []string {
+	router.lock.Lock()
+	defer router.lock.Unlock()
+
+	uris := make([]string, 0, len(router.routes))
+	for uri := range router.routes {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+
+	return uris
+}
+
+func (router *FakeRouter) GreetingCount() int {
+	router.lock.Lock()
+	defer router.lock.Unlock()
+	return router.greetings
+}
+
+func (router *FakeRouter) RegistrationCount() int {
+	router.lock.Lock()
+	defer router.lock.Unlock()
+	return router.registrations
+}
+
+func (router *FakeRouter) UnregistrationCount() int {
+	router.lock.Lock()
+	defer router.lock.Unlock()
+	return router.unregistrations
+}
+
+func (router *FakeRouter) handleGreet(msg *nats.Msg) {
+	router.lock.Lock()
+	router.greetings++
+	router.lock.Unlock()
+
+	router.publish(msg.Reply)
+}
+
+func (router *FakeRouter) handleRegister(msg *nats.Msg) {
+	var message routing_table.RegistryMessage
+	if json.Unmarshal(msg.Data, &message) != nil {
+		return
+	}
+
+	router.lock.Lock()
+	defer router.lock.Unlock()
+
+	router.registrations++
+	endpoint := Endpoint{Host: message.Host, Port: message.Port}
+	for _, uri := range message.URIs {
+		if router.routes[uri] == nil {
+			router.routes[uri] = map[Endpoint]time.Time{}
+		}
+		router.routes[uri][endpoint] = router.clock.Now()
+	}
+}
+
+func (router *FakeRouter) handleUnregister(msg *nats.Msg) {
+	var message routing_table.RegistryMessage
+	if json.Unmarshal(msg.Data, &message) != nil {
+		return
+	}
+
+	router.lock.Lock()
+	defer router.lock.Unlock()
+
+	router.unregistrations++
+	endpoint := Endpoint{Host: message.Host, Port: message.Port}
+	for _, uri := range message.URIs {
+		delete(router.routes[uri], endpoint)
+		if len(router.routes[uri]) == 0 {
+			delete(router.routes, uri)
+		}
+	}
+}
+
+// publish sends the router's greeting to the subject. Like a real router, it
+// does not block the sender of the message it answers: handlers of the fake
+// NATS client run synchronously.
+func (router *FakeRouter) publish(subject string) {
+	payload, _ := json.Marshal(routing_table.RouterGreetingMessage{
+		Id:                      router.config.Id,
+		MinimumRegisterInterval: int(router.config.MinimumRegisterInterval / time.Second),
+		PruneThresholdInSeconds: int(router.config.PruneThreshold / time.Second),
+	})
+
+	go router.natsClient.Publish(subject, payload)
+}
+
+type byAddress []Endpoint
+
+func (endpoints byAddress) Len() int      { return len(endpoints) }
+func (endpoints byAddress) Swap(i, j int) { endpoints[i], endpoints[j] = endpoints[j], endpoints[i] }
+func (endpoints byAddress) Less(i, j int) bool {
+	if endpoints[i].Host != endpoints[j].Host {
+		return endpoints[i].Host < endpoints[j].Host
+	}
+	return endpoints[i].Port < endpoints[j].Port
+}
@@ -0,0 +1,13 @@
+package fake_router_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestFakeRouter(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "FakeRouter Suite")
+}
@@ -0,0 +1,171 @@
+package fake_router_test
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/apcera/nats"
+	"github.com/cloudfoundry-incubator/route-emitter/fake_router"
+	"github.com/cloudfoundry-incubator/route-emitter/nats_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/syncer"
+	fake_metrics_sender "github.com/cloudfoundry/dropsonde/metric_sender/fake"
+	"github.com/cloudfoundry/dropsonde/metrics"
+	"github.com/cloudfoundry/gunk/diegonats"
+	"github.com/pivotal-golang/clock/fakeclock"
+	"github.com/pivotal-golang/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FakeRouter", func() {
+	var (
+		natsClient *diegonats.FakeNATSClient
+		clock      *fakeclock.FakeClock
+		router     *fake_router.FakeRouter
+		process    ifrit.Process
+
+		endpoint1 fake_router.Endpoint
+		endpoint2 fake_router.Endpoint
+	)
+
+	publish := func(subject string, message routing_table.RegistryMessage) {
+		payload, err := json.Marshal(message)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(natsClient.Publish(subject, payload)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		natsClient = diegonats.NewFakeClient()
+		clock = fakeclock.NewFakeClock(time.Now())
+
+		router = fake_router.New(natsClient, clock, fake_router.Config{
+			MinimumRegisterInterval: 20 * time.Second,
+			PruneThreshold:          30 * time.Second,
+			PruneInterval:           10 * time.Second,
+		})
+
+		endpoint1 = fake_router.Endpoint{Host: "1.1.1.1", Port: 11}
+		endpoint2 = fake_router.Endpoint{Host: "2.2.2.2", Port: 22}
+	})
+
+	JustBeforeEach(func() {
+		process = ifrit.Invoke(router)
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive(BeNil()))
+	})
+
+	greeting := func(msg *nats.Msg) routing_table.RouterGreetingMessage {
+		var greeting routing_table.RouterGreetingMessage
+		Expect(json.Unmarshal(msg.Data, &greeting)).To(Succeed())
+		return greeting
+	}
+
+	It("announces itself with router.start", func() {
+		Eventually(func() []*nats.Msg {
+			return natsClient.PublishedMessages("router.start")
+		}).Should(HaveLen(1))
+
+		Expect(greeting(natsClient.PublishedMessages("router.start")[0])).To(Equal(routing_table.RouterGreetingMessage{
+			MinimumRegisterInterval: 20,
+			PruneThresholdInSeconds: 30,
+		}))
+	})
+
+	It("answers greetings", func() {
+		Expect(natsClient.PublishRequest("router.greet", "reply-subject", []byte{})).To(Succeed())
+
+		Eventually(func() []*nats.Msg {
+			return natsClient.PublishedMessages("reply-subject")
+		}).Should(HaveLen(1))
+		Expect(greeting(natsClient.PublishedMessages("reply-subject")[0]).PruneThresholdInSeconds).To(Equal(30))
+		Expect(router.GreetingCount()).To(Equal(1))
+	})
+
+	It("applies registrations and unregistrations", func() {
+		publish("router.register", routing_table.RegistryMessage{Host: "1.1.1.1", Port: 11, URIs: []string{"foo.com", "bar.com"}})
+		publish("router.register", routing_table.RegistryMessage{Host: "2.2.2.2", Port: 22, URIs: []string{"foo.com"}})
+
+		Expect(router).To(fake_router.ResolveTo("foo.com", endpoint2, endpoint1))
+		Expect(router).To(fake_router.ResolveTo("bar.com", endpoint1))
+		Expect(router.URIs()).To(Equal([]string{"bar.com", "foo.com"}))
+
+		publish("router.unregister", routing_table.RegistryMessage{Host: "1.1.1.1", Port: 11, URIs: []string{"foo.com", "bar.com"}})
+
+		Expect(router).To(fake_router.ResolveTo("foo.com", endpoint2))
+		Expect(router).To(fake_router.ResolveTo("bar.com"))
+		Expect(router.RegistrationCount()).To(Equal(2))
+		Expect(router.UnregistrationCount()).To(Equal(1))
+	})
+
+	It("prunes routes that are not registered again within the threshold", func() {
+		publish("router.register", routing_table.RegistryMessage{Host: "1.1.1.1", Port: 11, URIs: []string{"foo.com"}})
+		publish("router.register", routing_table.RegistryMessage{Host: "2.2.2.2", Port: 22, URIs: []string{"foo.com"}})
+
+		Eventually(clock.WatcherCount).Should(Equal(1))
+		clock.Increment(20 * time.Second)
+		publish("router.register", routing_table.RegistryMessage{Host: "2.2.2.2", Port: 22, URIs: []string{"foo.com"}})
+
+		Eventually(clock.WatcherCount).Should(Equal(1))
+		clock.Increment(20 * time.Second)
+		Eventually(router).Should(fake_router.ResolveTo("foo.com", endpoint2))
+
+		Eventually(clock.WatcherCount).Should(Equal(1))
+		clock.Increment(20 * time.Second)
+		Eventually(router).Should(fake_router.ResolveTo("foo.com"))
+	})
+
+	Describe("with the syncer and the NATS emitter", func() {
+		var (
+			syncerRunner   *syncer.Syncer
+			syncerProcess  ifrit.Process
+			emitter        nats_emitter.NATSEmitter
+			emitterProcess ifrit.Process
+		)
+
+		BeforeEach(func() {
+			metrics.Initialize(fake_metrics_sender.NewFakeMetricSender(), nil)
+
+			logger := lagertest.NewTestLogger("test")
+			emitter = nats_emitter.New(natsClient, logger)
+			emitterProcess = ifrit.Invoke(emitter)
+			syncerRunner = syncer.NewSyncer(clock, time.Minute, natsClient, logger)
+			syncerProcess = ifrit.Invoke(syncerRunner)
+		})
+
+		AfterEach(func() {
+			syncerProcess.Signal(os.Interrupt)
+			Eventually(syncerProcess.Wait()).Should(Receive(BeNil()))
+
+			emitterProcess.Signal(os.Interrupt)
+			Eventually(emitterProcess.Wait()).Should(Receive(BeNil()))
+		})
+
+		It("resolves the emitted routes until they go stale", func() {
+			Eventually(syncerRunner.Events().Sync).Should(Receive())
+
+			_, err := emitter.Emit(routing_table.MessagesToEmit{
+				RegistrationMessages: []routing_table.RegistryMessage{
+					{Host: "1.1.1.1", Port: 11, URIs: []string{"foo.com"}},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(router).Should(fake_router.ResolveTo("foo.com", endpoint1))
+
+			// the router's prune ticker, and the syncer's sync and emit tickers
+			Eventually(clock.WatcherCount).Should(Equal(3))
+			clock.Increment(10 * time.Second)
+			Eventually(syncerRunner.Events().Emit).Should(Receive())
+
+			Eventually(clock.WatcherCount).Should(Equal(3))
+			clock.Increment(30 * time.Second)
+			Eventually(router).Should(fake_router.ResolveTo("foo.com"))
+		})
+	})
+})
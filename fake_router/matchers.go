@@ -0,0 +1,54 @@
+package fake_router
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/onsi/gomega/format"
+)
+
+// ResolveTo succeeds when the route resolves to exactly the given endpoints
+// on a *FakeRouter. With no endpoints, it succeeds when the route is not
+// registered at all. It can be polled with Eventually:
+//
+//	Eventually(router).Should(fake_router.ResolveTo("foo.com", endpoint))
+func ResolveTo(uri string, endpoints ...Endpoint) *resolveToMatcher {
+	expected := append([]Endpoint{}, endpoints...)
+	sort.Sort(byAddress(expected))
+
+	return &resolveToMatcher{uri: uri, expected: expected}
+}
+
+type resolveToMatcher struct {
+	uri      string
+	expected []Endpoint
+	actual   []Endpoint
+}
+
+func (m *resolveToMatcher) Match(a interface{}) (success bool, err error) {
+	router, ok := a.(*FakeRouter)
+	if !ok {
+		return false, fmt.Errorf("%s is not a *fake_router.FakeRouter", format.Object(a, 1))
+	}
+
+	m.actual = router.Lookup(m.uri)
+	if len(m.actual) != len(m.expected) {
+		return false, nil
+	}
+
+	for i := range m.actual {
+		if m.actual[i] != m.expected[i] {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func (m *resolveToMatcher) FailureMessage(actual interface{}) (message string) {
+	return format.Message(m.actual, fmt.Sprintf("for %s to resolve to", m.uri), m.expected)
+}
+
+func (m *resolveToMatcher) NegatedFailureMessage(actual interface{}) (message string) {
+	return format.Message(m.actual, fmt.Sprintf("for %s not to resolve to", m.uri), m.expected)
+}
@@ -0,0 +1,154 @@
+package journal
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/pivotal-golang/clock"
+)
+
+// ErrTruncated is returned when some of the requested changes have already
+// been evicted from the journal. The consumer has to start over from the
+// current state of the routing table.
+var ErrTruncated = errors.New("requested changes have been evicted from the journal")
+
+type Operation string
+
+const (
+	SetRoutes      Operation = "set-routes"
+	RemoveRoutes   Operation = "remove-routes"
+	AddEndpoint    Operation = "add-endpoint"
+	RemoveEndpoint Operation = "remove-endpoint"
+	Swap           Operation = "swap"
+)
+
+// SyncSource is the source of the changes made by a sync. Changes made while
+// handling a BBS event have the event type as their source.
+const SyncSource = "sync"
+
+// Record describes a single mutation of the routing table or the TCP routing
+// table and the messages it emitted. The routing key is empty for a swap, which
+// records only the routes the sync changed rather than every message of the
+// re-registered table, so that the journal stays small on large tables.
+// Mutations of the TCP routing table record TCP route mappings instead of
+// registry messages.
+type Record struct {
+	Sequence           uint64                          `json:"sequence"`
+	Timestamp          int64                           `json:"timestamp"`
+	ProcessGuid        string                          `json:"process_guid,omitempty"`
+	ContainerPort      uint32                          `json:"container_port,omitempty"`
+	Operation          Operation                       `json:"operation"`
+	Source             string                          `json:"source"`
+	Registrations      []routing_table.RegistryMessage `json:"registrations"`
+	Unregistrations    []routing_table.RegistryMessage `json:"unregistrations"`
+	TCPRegistrations   []routing_table.TCPRouteMapping `json:"tcp_registrations,omitempty"`
+	TCPUnregistrations []routing_table.TCPRouteMapping `json:"tcp_unregistrations,omitempty"`
+}
+
+func NewRecord(key routing_table.RoutingKey, operation Operation, source string, messages routing_table.MessagesToEmit) Record {
+	record := Record{
+		ProcessGuid:     key.ProcessGuid,
+		ContainerPort:   key.ContainerPort,
+		Operation:       operation,
+		Source:          source,
+		Registrations:   messages.RegistrationMessages,
+		Unregistrations: messages.UnregistrationMessages,
+	}
+
+	if record.Registrations == nil {
+		record.Registrations = []routing_table.RegistryMessage{}
+	}
+	if record.Unregistrations == nil {
+		record.Unregistrations = []routing_table.RegistryMessage{}
+	}
+
+	return record
+}
+
+func NewTCPRecord(key routing_table.RoutingKey, operation Operation, source string, messages routing_table.TCPMessagesToEmit) Record {
+	record := NewRecord(key, operation, source, routing_table.MessagesToEmit{})
+	record.TCPRegistrations = messages.RegistrationMessages
+	record.TCPUnregistrations = messages.UnregistrationMessages
+
+	return record
+}
+
+// Journal keeps the most recent records in a ring buffer, numbering them with
+// a sequence that starts at 1 and increases by one with every record.
+type Journal struct {
+	clock clock.Clock
+
+	mutex        sync.Mutex
+	records      []Record
+	oldest       int
+	lastSequence uint64
+}
+
+func New(capacity int, clock clock.Clock) *Journal {
+	return &Journal{
+		clock:   clock,
+		records: make([]Record, 0, capacity),
+	}
+}
+
+// Append assigns the next sequence number and a timestamp to the record,
+// evicting the oldest record if the journal is full.
+func (j *Journal) Append(record Record) Record {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	j.lastSequence++
+	record.Sequence = j.lastSequence
+	record.Timestamp = j.clock.Now().UnixNano()
+
+	if len(j.records) < cap(j.records) {
+		j.records = append(j.records, record)
+	} else if cap(j.records) > 0 {
+		j.records[j.oldest] = record
+		j.oldest = (j.oldest + 1) % len(j.records)
+	}
+
+	return record
+}
+
+// Since returns the records with a sequence greater than the given one, oldest
+// first. It returns ErrTruncated if any of them have been evicted.
+func (j *Journal) Since(sequence uint64) ([]Record, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if sequence >= j.lastSequence {
+		return []Record{}, nil
+	}
+
+	missing := j.lastSequence - sequence
+	if missing > uint64(len(j.records)) {
+		return nil, ErrTruncated
+	}
+
+	return j.newest(int(missing)), nil
+}
+
+// Retained returns all records still in the journal, oldest first.
+func (j *Journal) Retained() []Record {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	return j.newest(len(j.records))
+}
+
+func (j *Journal) newest(count int) []Record {
+	records := make([]Record, 0, count)
+	for i := len(j.records) - count; i < len(j.records); i++ {
+		records = append(records, j.records[(j.oldest+i)%len(j.records)])
+	}
+	return records
+}
+
+func (j *Journal) LastSequence() uint64 {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	return j.lastSequence
+}
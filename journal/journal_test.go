@@ -0,0 +1,137 @@
+package journal_test
+
+import (
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/journal"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Journal", func() {
+	var (
+		clock         *fakeclock.FakeClock
+		changeJournal *journal.Journal
+		messages      routing_table.MessagesToEmit
+	)
+
+	key := routing_table.RoutingKey{ProcessGuid: "process-guid", ContainerPort: 8080}
+
+	BeforeEach(func() {
+		clock = fakeclock.NewFakeClock(time.Unix(0, 1000))
+		changeJournal = journal.New(3, clock)
+
+		messages = routing_table.MessagesToEmit{
+			RegistrationMessages: []routing_table.RegistryMessage{
+				{Host: "1.1.1.1", Port: 11, URIs: []string{"foo.example.com"}, App: "log-guid"},
+			},
+		}
+	})
+
+	appendRecords := func(count int) {
+		for i := 0; i < count; i++ {
+			changeJournal.Append(journal.NewRecord(key, journal.AddEndpoint, "actual_lrp_created", messages))
+			clock.Increment(time.Second)
+		}
+	}
+
+	Describe("Append", func() {
+		It("assigns increasing sequence numbers and timestamps", func() {
+			first := changeJournal.Append(journal.NewRecord(key, journal.SetRoutes, "desired_lrp_created", messages))
+			clock.Increment(time.Second)
+			second := changeJournal.Append(journal.NewRecord(key, journal.AddEndpoint, "actual_lrp_created", messages))
+
+			Expect(first.Sequence).To(BeEquivalentTo(1))
+			Expect(first.Timestamp).To(BeEquivalentTo(1000))
+			Expect(second.Sequence).To(BeEquivalentTo(2))
+			Expect(second.Timestamp).To(Equal(int64(1000) + time.Second.Nanoseconds()))
+			Expect(changeJournal.LastSequence()).To(BeEquivalentTo(2))
+		})
+
+		It("records the routing key, operation, source and messages", func() {
+			record := changeJournal.Append(journal.NewRecord(key, journal.SetRoutes, "desired_lrp_created", messages))
+
+			Expect(record.ProcessGuid).To(Equal(key.ProcessGuid))
+			Expect(record.ContainerPort).To(Equal(key.ContainerPort))
+			Expect(record.Operation).To(Equal(journal.SetRoutes))
+			Expect(record.Source).To(Equal("desired_lrp_created"))
+			Expect(record.Registrations).To(Equal(messages.RegistrationMessages))
+			Expect(record.Unregistrations).To(BeEmpty())
+		})
+
+		It("records the TCP route mappings of a TCP routing table change", func() {
+			tcpMessages := routing_table.TCPMessagesToEmit{
+				UnregistrationMessages: []routing_table.TCPRouteMapping{
+					{RouterGroupGuid: "router-group-guid", ExternalPort: 61000, HostIP: "1.1.1.1", HostPort: 11},
+				},
+			}
+
+			record := changeJournal.Append(journal.NewTCPRecord(key, journal.RemoveEndpoint, "actual_lrp_removed", tcpMessages))
+
+			Expect(record.Operation).To(Equal(journal.RemoveEndpoint))
+			Expect(record.TCPUnregistrations).To(Equal(tcpMessages.UnregistrationMessages))
+			Expect(record.TCPRegistrations).To(BeEmpty())
+			Expect(record.Registrations).To(BeEmpty())
+			Expect(record.Unregistrations).To(BeEmpty())
+		})
+	})
+
+	Describe("Since", func() {
+		It("returns nothing when the journal is empty", func() {
+			records, err := changeJournal.Since(0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records).To(BeEmpty())
+		})
+
+		It("returns the records after the sequence, oldest first", func() {
+			appendRecords(3)
+
+			records, err := changeJournal.Since(1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records).To(HaveLen(2))
+			Expect(records[0].Sequence).To(BeEquivalentTo(2))
+			Expect(records[1].Sequence).To(BeEquivalentTo(3))
+		})
+
+		It("returns nothing when the consumer is up to date", func() {
+			appendRecords(3)
+
+			records, err := changeJournal.Since(3)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records).To(BeEmpty())
+		})
+
+		Context("when the journal has wrapped around", func() {
+			BeforeEach(func() {
+				appendRecords(5)
+			})
+
+			It("returns the retained records", func() {
+				records, err := changeJournal.Since(2)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(records).To(HaveLen(3))
+				Expect(records[0].Sequence).To(BeEquivalentTo(3))
+				Expect(records[2].Sequence).To(BeEquivalentTo(5))
+			})
+
+			It("returns ErrTruncated when records have been evicted", func() {
+				_, err := changeJournal.Since(1)
+				Expect(err).To(Equal(journal.ErrTruncated))
+			})
+		})
+	})
+
+	Describe("Retained", func() {
+		It("returns every record still in the journal, oldest first", func() {
+			appendRecords(4)
+
+			records := changeJournal.Retained()
+			Expect(records).To(HaveLen(3))
+			Expect(records[0].Sequence).To(BeEquivalentTo(2))
+			Expect(records[2].Sequence).To(BeEquivalentTo(4))
+		})
+	})
+})
@@ -0,0 +1,157 @@
+package nats_emitter
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/cloudfoundry-incubator/route-emitter/route_sink"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+)
+
+// DroppedMessagesError is returned by Emit when messages were dropped for
+// good: they could not be published, and there was no room left to keep them
+// for a later attempt.
+type DroppedMessagesError struct {
+	Dropped []route_sink.FailedMessage
+}
+
+func (e *DroppedMessagesError) Error() string {
+	return fmt.Sprintf("dropped %d route messages: %s", len(e.Dropped), e.Dropped[len(e.Dropped)-1].Err.Error())
+}
+
+// droppedMessages collects the messages dropped for good until Emit reports
+// them.
+type droppedMessages struct {
+	lock    sync.Mutex
+	dropped []route_sink.FailedMessage
+}
+
+func (d *droppedMessages) add(dropped []route_sink.FailedMessage) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, failure := range dropped {
+		failure.Dropped = true
+		d.dropped = append(d.dropped, failure)
+	}
+}
+
+func (d *droppedMessages) take() []route_sink.FailedMessage {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	dropped := d.dropped
+	d.dropped = nil
+	return dropped
+}
+
+// messageKey identifies the endpoint and set of URIs a message is about. Only
+// the latest message for a key is queued or kept as a dead letter.
+type messageKey struct {
+	host       string
+	port       uint32
+	instanceId string
+	uris       string
+}
+
+func messageKeyFor(message routing_table.RegistryMessage) messageKey {
+	return messageKey{
+		host:       message.Host,
+		port:       message.Port,
+		instanceId: message.PrivateInstanceId,
+		uris:       strings.Join(message.URIs, ","),
+	}
+}
+
+// deadLetters holds up to capacity messages that could not be published, to
+// be published again once NATS is reachable. Only the latest message for an
+// endpoint and set of URIs is kept, so that a stale registration is never
+// published after the unregistration that replaced it. When the buffer is
+// full the oldest message is evicted.
+type deadLetters struct {
+	capacity int
+
+	lock    sync.Mutex
+	letters map[messageKey]route_sink.FailedMessage
+	order   []messageKey
+}
+
+func newDeadLetters(capacity int) *deadLetters {
+	return &deadLetters{
+		capacity: capacity,
+		letters:  map[messageKey]route_sink.FailedMessage{},
+	}
+}
+
+// add keeps the message, and returns the messages evicted to make room for
+// it. With no capacity, the message is not kept.
+func (d *deadLetters) add(letter route_sink.FailedMessage) ([]route_sink.FailedMessage, bool) {
+	if d.capacity <= 0 {
+		return nil, false
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	key := messageKeyFor(letter.Message)
+	if _, found := d.letters[key]; found {
+		d.removeLocked(key)
+	}
+
+	d.letters[key] = letter
+	d.order = append(d.order, key)
+
+	evicted := []route_sink.FailedMessage{}
+	for len(d.order) > d.capacity {
+		evicted = append(evicted, d.letters[d.order[0]])
+		d.removeLocked(d.order[0])
+	}
+
+	return evicted, true
+}
+
+// supersede discards any message kept for the same endpoint and URIs as
+// message, which is newer.
+func (d *deadLetters) supersede(message routing_table.RegistryMessage) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	key := messageKeyFor(message)
+	if _, found := d.letters[key]; found {
+		d.removeLocked(key)
+	}
+}
+
+// take removes and returns all the messages, oldest first.
+func (d *deadLetters) take() []route_sink.FailedMessage {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	letters := make([]route_sink.FailedMessage, 0, len(d.order))
+	for _, key := range d.order {
+		letters = append(letters, d.letters[key])
+	}
+
+	d.letters = map[messageKey]route_sink.FailedMessage{}
+	d.order = nil
+
+	return letters
+}
+
+func (d *deadLetters) len() int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return len(d.order)
+}
+
+func (d *deadLetters) removeLocked(key messageKey) {
+	delete(d.letters, key)
+	for i := range d.order {
+		if d.order[i] == key {
+			d.order = append(d.order[:i], d.order[i+1:]...)
+			break
+		}
+	}
+}
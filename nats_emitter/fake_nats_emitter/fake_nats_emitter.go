@@ -2,24 +2,36 @@
 package fake_nats_emitter
 
 import (
+	"os"
 	"sync"
 
 	"github.com/cloudfoundry-incubator/route-emitter/nats_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/route_sink"
 	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
 )
 
 type FakeNATSEmitter struct {
-	EmitStub        func(messagesToEmit routing_table.MessagesToEmit) error
+	EmitStub        func(messagesToEmit routing_table.MessagesToEmit) (route_sink.EmitResult, error)
 	emitMutex       sync.RWMutex
 	emitArgsForCall []struct {
 		messagesToEmit routing_table.MessagesToEmit
 	}
 	emitReturns struct {
+		result1 route_sink.EmitResult
+		result2 error
+	}
+	RunStub        func(signals <-chan os.Signal, ready chan<- struct{}) error
+	runMutex       sync.RWMutex
+	runArgsForCall []struct {
+		signals <-chan os.Signal
+		ready   chan<- struct{}
+	}
+	runReturns struct {
 		result1 error
 	}
 }
 
-func (fake *FakeNATSEmitter) Emit(messagesToEmit routing_table.MessagesToEmit) error {
+func (fake *FakeNATSEmitter) Emit(messagesToEmit routing_table.MessagesToEmit) (route_sink.EmitResult, error) {
 	fake.emitMutex.Lock()
 	fake.emitArgsForCall = append(fake.emitArgsForCall, struct {
 		messagesToEmit routing_table.MessagesToEmit
@@ -28,7 +40,7 @@ func (fake *FakeNATSEmitter) Emit(messagesToEmit routing_table.MessagesToEmit) e
 	if fake.EmitStub != nil {
 		return fake.EmitStub(messagesToEmit)
 	} else {
-		return fake.emitReturns.result1
+		return fake.emitReturns.result1, fake.emitReturns.result2
 	}
 }
 
@@ -44,9 +56,43 @@ func (fake *FakeNATSEmitter) EmitArgsForCall(i int) routing_table.MessagesToEmit
 	return fake.emitArgsForCall[i].messagesToEmit
 }
 
-func (fake *FakeNATSEmitter) EmitReturns(result1 error) {
+func (fake *FakeNATSEmitter) EmitReturns(result1 route_sink.EmitResult, result2 error) {
 	fake.EmitStub = nil
 	fake.emitReturns = struct {
+		result1 route_sink.EmitResult
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeNATSEmitter) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	fake.runMutex.Lock()
+	fake.runArgsForCall = append(fake.runArgsForCall, struct {
+		signals <-chan os.Signal
+		ready   chan<- struct{}
+	}{signals, ready})
+	fake.runMutex.Unlock()
+	if fake.RunStub != nil {
+		return fake.RunStub(signals, ready)
+	} else {
+		return fake.runReturns.result1
+	}
+}
+
+func (fake *FakeNATSEmitter) RunCallCount() int {
+	fake.runMutex.RLock()
+	defer fake.runMutex.RUnlock()
+	return len(fake.runArgsForCall)
+}
+
+func (fake *FakeNATSEmitter) RunArgsForCall(i int) (<-chan os.Signal, chan<- struct{}) {
+	fake.runMutex.RLock()
+	defer fake.runMutex.RUnlock()
+	return fake.runArgsForCall[i].signals, fake.runArgsForCall[i].ready
+}
+
+func (fake *FakeNATSEmitter) RunReturns(result1 error) {
+	fake.RunStub = nil
+	fake.runReturns = struct {
 		result1 error
 	}{result1}
 }
@@ -2,89 +2,419 @@ package nats_emitter
 
 import (
 	"encoding/json"
-	"sync"
+	"os"
+	"time"
 
+	"github.com/cloudfoundry-incubator/route-emitter/route_sink"
 	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/runtime-schema/metric"
 	"github.com/cloudfoundry/gunk/diegonats"
-	"github.com/cloudfoundry/gunk/workpool"
+	"github.com/pivotal-golang/clock"
 	"github.com/pivotal-golang/lager"
+	"github.com/tedsuo/ifrit"
+)
+
+var (
+	queueDepth   = metric.Metric("NATSEmitterQueueDepth")
+	throttleWait = metric.Duration("NATSEmitterThrottleWait")
+
+	publishRetries  = metric.Counter("NATSEmitterPublishRetries")
+	deadLetterCount = metric.Metric("NATSEmitterDeadLetters")
+	messagesDropped = metric.Counter("NATSEmitterMessagesDropped")
+)
+
+const (
+	defaultDeadLetterRetryInterval = 5 * time.Second
+	defaultDrainTimeout            = 5 * time.Second
 )
 
 //go:generate counterfeiter -o fake_nats_emitter/fake_nats_emitter.go . NATSEmitter
+
+// NATSEmitter queues route messages on Emit, and publishes them to NATS while
+// it runs.
 type NATSEmitter interface {
-	Emit(messagesToEmit routing_table.MessagesToEmit) error
+	Emit(messagesToEmit routing_table.MessagesToEmit) (route_sink.EmitResult, error)
+	ifrit.Runner
+}
+
+type Options struct {
+	// PublishRate is the maximum number of messages published per second.
+	// Publishes are not limited if it is zero.
+	PublishRate int
+
+	// PublishBurst is the number of messages that may be published at once
+	// before PublishRate applies. It defaults to PublishRate.
+	PublishBurst int
+
+	// MaxRetries is the number of times a failed publish is retried before
+	// the message is dead-lettered.
+	MaxRetries int
+
+	// RetryInterval is the delay before the first retry of a publish. It
+	// doubles with every further retry, up to MaxRetryInterval.
+	RetryInterval    time.Duration
+	MaxRetryInterval time.Duration
+
+	// DeadLetterCapacity is the number of messages that could not be
+	// published to keep for another attempt once NATS is reachable again.
+	// If it is zero, such messages are dropped.
+	DeadLetterCapacity int
+
+	// DeadLetterRetryInterval is how often Run checks whether NATS is
+	// reachable again to publish the dead letters. It defaults to 5 seconds.
+	DeadLetterRetryInterval time.Duration
+
+	// DrainTimeout bounds how long Run keeps publishing the queued messages
+	// once it is signalled to stop. It defaults to 5 seconds.
+	DrainTimeout time.Duration
+
+	Clock clock.Clock
 }
 
 type natsEmitter struct {
-	natsClient diegonats.NATSClient
-	workPool   *workpool.WorkPool
-	logger     lager.Logger
+	natsClient  diegonats.NATSClient
+	queue       *publishQueue
+	limiter     *tokenBucket
+	deadLetters *deadLetters
+	dropped     *droppedMessages
+	options     Options
+	logger      lager.Logger
+
+	// stopped is closed when Run returns, cancelling the pending retries
+	stopped chan struct{}
+}
+
+func New(natsClient diegonats.NATSClient, logger lager.Logger) NATSEmitter {
+	return NewWithOptions(natsClient, Options{}, logger)
 }
 
-func New(natsClient diegonats.NATSClient, workPool *workpool.WorkPool, logger lager.Logger) NATSEmitter {
-	return &natsEmitter{
-		natsClient: natsClient,
-		workPool:   workPool,
-		logger:     logger.Session("nats-emitter"),
+// NewWithOptions returns a NATSEmitter that publishes at most
+// options.PublishRate messages per second, and retries and dead-letters
+// messages that fail to publish. It sends the NATSEmitterQueueDepth metric,
+// the number of messages waiting to be published, the
+// NATSEmitterThrottleWait metric, how long the next publish waits for the
+// rate limit, and the NATSEmitterPublishRetries, NATSEmitterDeadLetters and
+// NATSEmitterMessagesDropped metrics.
+func NewWithOptions(natsClient diegonats.NATSClient, options Options, logger lager.Logger) NATSEmitter {
+	if options.Clock == nil {
+		options.Clock = clock.NewClock()
+	}
+
+	emitter := &natsEmitter{
+		natsClient:  natsClient,
+		queue:       newPublishQueue(),
+		deadLetters: newDeadLetters(options.DeadLetterCapacity),
+		dropped:     &droppedMessages{},
+		options:     options,
+		logger:      logger.Session("nats-emitter"),
+		stopped:     make(chan struct{}),
+	}
+
+	if options.PublishRate > 0 {
+		emitter.limiter = newTokenBucket(options.PublishRate, options.PublishBurst, options.Clock)
 	}
+
+	return emitter
 }
 
-func (n *natsEmitter) Emit(messagesToEmit routing_table.MessagesToEmit) error {
-	errors := make(chan error, 1)
-	var wg sync.WaitGroup
-	wg.Add(len(messagesToEmit.RegistrationMessages))
+// Emit queues the messages to be published by Run, replacing any message
+// still queued for the same route, and returns without waiting for them to
+// be published. It reports the queued messages as pending, as whether they
+// are published is only known later.
+//
+// The messages dropped for good since the previous Emit are reported as
+// dropped, and returned in a *DroppedMessagesError.
+func (n *natsEmitter) Emit(messagesToEmit routing_table.MessagesToEmit) (route_sink.EmitResult, error) {
+	for _, message := range messagesToEmit.UnregistrationMessages {
+		n.deadLetters.supersede(message)
+		n.queue.push(route_sink.UnregisterSubject, message)
+	}
 	for _, message := range messagesToEmit.RegistrationMessages {
-		n.emit("router.register", message, &wg, errors)
+		n.deadLetters.supersede(message)
+		n.queue.push(route_sink.RegisterSubject, message)
 	}
 
-	wg.Add(len(messagesToEmit.UnregistrationMessages))
-	for _, message := range messagesToEmit.UnregistrationMessages {
-		n.emit("router.unregister", message, &wg, errors)
+	queueDepth.Send(n.queue.len())
+	deadLetterCount.Send(n.deadLetters.len())
+
+	result := route_sink.EmitResult{}
+	result.Queue(route_sink.RegisterSubject, len(messagesToEmit.RegistrationMessages))
+	result.Queue(route_sink.UnregisterSubject, len(messagesToEmit.UnregistrationMessages))
+
+	dropped := n.dropped.take()
+	if len(dropped) == 0 {
+		return result, nil
 	}
 
-	wg.Wait()
+	for _, failure := range dropped {
+		result.Drop(failure)
+	}
+	return result, &DroppedMessagesError{Dropped: dropped}
+}
+
+// Run publishes the queued messages one at a time, unregistrations first,
+// waiting for the rate limit between them. Every DeadLetterRetryInterval, it
+// queues the dead letters again if NATS is reachable. When it is signalled,
+// it drains the queue before returning.
+func (n *natsEmitter) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	defer close(n.stopped)
+
+	var retryDeadLetters <-chan time.Time
+	if n.options.DeadLetterCapacity > 0 {
+		ticker := n.options.Clock.NewTicker(n.options.deadLetterRetryInterval())
+		defer ticker.Stop()
+		retryDeadLetters = ticker.C()
+	}
+
+	close(ready)
+
+	for {
+		select {
+		case <-retryDeadLetters:
+			n.retryDeadLetters()
+		default:
+		}
+
+		message, ok := n.queue.pop()
+		if !ok {
+			queueDepth.Send(0)
+
+			select {
+			case <-n.queue.ready:
+				continue
+			case <-retryDeadLetters:
+				n.retryDeadLetters()
+				continue
+			case <-signals:
+				n.drain(nil)
+				return nil
+			}
+		}
+
+		if !n.throttle(signals) {
+			n.drain(&message)
+			return nil
+		}
 
+		n.emit(message)
+		n.queue.done()
+	}
+}
+
+// throttle waits for the rate limit, if any, before a message is published.
+// It returns false if it was signalled to stop while waiting.
+func (n *natsEmitter) throttle(signals <-chan os.Signal) bool {
+	if n.limiter == nil {
+		return true
+	}
+
+	delay := n.limiter.reserve()
+	if delay <= 0 {
+		return true
+	}
+
+	queueDepth.Send(n.queue.len())
+	throttleWait.Send(delay)
+
+	timer := n.options.Clock.NewTimer(delay)
 	select {
-	case finalError := <-errors:
-		return finalError
-	default:
+	case <-timer.C():
+		return true
+	case <-signals:
+		timer.Stop()
+		return false
+	}
+}
+
+// retryDeadLetters queues the dead letters again if NATS is reachable.
+func (n *natsEmitter) retryDeadLetters() {
+	if n.deadLetters.len() == 0 || !n.natsClient.Ping() {
+		return
 	}
 
-	return nil
+	letters := n.deadLetters.take()
+	n.logger.Info("retrying-dead-letters", lager.Data{"count": len(letters)})
+	for _, letter := range letters {
+		n.queue.requeue(queuedMessage{subject: letter.Subject, message: letter.Message})
+	}
+
+	deadLetterCount.Send(n.deadLetters.len())
 }
 
-func (n *natsEmitter) emit(subject string, message routing_table.RegistryMessage, wg *sync.WaitGroup, errors chan error) {
-	n.workPool.Submit(func() {
-		var err error
-		defer func() {
-			if err != nil {
-				select {
-				case errors <- err:
-				default:
-				}
+// drain publishes the queued messages, starting with the one waiting for the
+// rate limit if any, so that the messages emitted on shutdown reach NATS. It
+// gives up once the DrainTimeout has passed. Messages that fail to publish
+// while draining are neither retried nor dead-lettered.
+func (n *natsEmitter) drain(throttled *queuedMessage) {
+	timeout := n.options.Clock.NewTimer(n.options.drainTimeout())
+	defer timeout.Stop()
+
+	n.logger.Info("draining", lager.Data{"count": n.queue.len()})
+
+	for {
+		var message queuedMessage
+		if throttled != nil {
+			message = *throttled
+			throttled = nil
+		} else {
+			var ok bool
+			message, ok = n.queue.pop()
+			if !ok {
+				n.logger.Info("drained")
+				return
 			}
-			wg.Done()
-		}()
+		}
 
-		n.logger.Debug("emit", lager.Data{
-			"subject": subject,
-			"message": message,
-		})
+		if !n.waitForRateLimit(timeout.C()) {
+			n.logger.Info("drain-timed-out", lager.Data{"abandoned": n.queue.len()})
+			return
+		}
 
-		payload, err := json.Marshal(message)
+		err := n.publish(message.subject, message.message)
 		if err != nil {
-			n.logger.Error("failed-to-marshal", err, lager.Data{
-				"message": message,
-				"subject": subject,
+			n.logger.Error("failed-to-publish-while-draining", err, lager.Data{
+				"message": message.message,
+				"subject": message.subject,
 			})
 		}
+		n.queue.done()
+	}
+}
 
-		err = n.natsClient.Publish(subject, payload)
-		if err != nil {
-			n.logger.Error("failed-to-publish", err, lager.Data{
-				"message": message,
-				"subject": subject,
-			})
+// waitForRateLimit waits for the rate limit, if any, and returns false if
+// the timeout fires first.
+func (n *natsEmitter) waitForRateLimit(timeout <-chan time.Time) bool {
+	var delay time.Duration
+	if n.limiter != nil {
+		delay = n.limiter.reserve()
+	}
+
+	if delay <= 0 {
+		select {
+		case <-timeout:
+			return false
+		default:
+			return true
+		}
+	}
+
+	timer := n.options.Clock.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return true
+	case <-timeout:
+		return false
+	}
+}
+
+func (n *natsEmitter) emit(queued queuedMessage) {
+	subject, message := queued.subject, queued.message
+
+	n.logger.Debug("emit", lager.Data{
+		"subject": subject,
+		"message": message,
+	})
+
+	err := n.publish(subject, message)
+	if err == nil {
+		return
+	}
+
+	n.logger.Error("failed-to-publish", err, lager.Data{
+		"message": message,
+		"subject": subject,
+	})
+
+	if n.queue.pending(message) {
+		// a newer message for the route supersedes this one
+		return
+	}
+
+	if queued.attempt < n.options.MaxRetries {
+		n.retryLater(queued)
+		return
+	}
+
+	failure := route_sink.FailedMessage{Subject: subject, Message: message, Err: err}
+	evicted, kept := n.deadLetters.add(failure)
+	if !kept {
+		failure.Dropped = true
+		evicted = append(evicted, failure)
+	}
+
+	if len(evicted) > 0 {
+		messagesDropped.Add(uint64(len(evicted)))
+		n.logger.Error("dropped-messages", err, lager.Data{"count": len(evicted), "dropped": evicted})
+		n.dropped.add(evicted)
+	}
+
+	deadLetterCount.Send(n.deadLetters.len())
+}
+
+// retryLater queues the message again once its retry backoff has passed,
+// unless a newer message for the route is emitted in the meantime. The
+// backoff doubles with every attempt, from the RetryInterval up to the
+// MaxRetryInterval.
+func (n *natsEmitter) retryLater(failed queuedMessage) {
+	retry := n.queue.retryLater(queuedMessage{
+		subject: failed.subject,
+		message: failed.message,
+		attempt: failed.attempt + 1,
+	})
+
+	backoff := n.options.RetryInterval
+	for i := 1; i < retry.attempt; i++ {
+		backoff *= 2
+		if n.options.MaxRetryInterval > 0 && backoff > n.options.MaxRetryInterval {
+			backoff = n.options.MaxRetryInterval
+			break
 		}
+	}
+
+	publishRetries.Increment()
+	n.logger.Debug("retrying-publish", lager.Data{
+		"subject": retry.subject,
+		"attempt": retry.attempt,
+		"backoff": backoff.String(),
 	})
+
+	timer := n.options.Clock.NewTimer(backoff)
+	go func() {
+		select {
+		case <-timer.C():
+			n.queue.retry(retry)
+		case <-n.stopped:
+			timer.Stop()
+		}
+	}()
+}
+
+// publish publishes the message once.
+func (n *natsEmitter) publish(subject string, message routing_table.RegistryMessage) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		n.logger.Error("failed-to-marshal", err, lager.Data{
+			"message": message,
+			"subject": subject,
+		})
+		return err
+	}
+
+	return n.natsClient.Publish(subject, payload)
+}
+
+func (options Options) deadLetterRetryInterval() time.Duration {
+	if options.DeadLetterRetryInterval == 0 {
+		return defaultDeadLetterRetryInterval
+	}
+	return options.DeadLetterRetryInterval
+}
+
+func (options Options) drainTimeout() time.Duration {
+	if options.DrainTimeout == 0 {
+		return defaultDrainTimeout
+	}
+	return options.DrainTimeout
 }
@@ -2,15 +2,20 @@ package nats_emitter_test
 
 import (
 	"errors"
+	"os"
+	"sync"
+	"time"
 
 	"github.com/apcera/nats"
 	"github.com/cloudfoundry-incubator/route-emitter/nats_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/route_sink"
 	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
 	fake_metrics_sender "github.com/cloudfoundry/dropsonde/metric_sender/fake"
 	"github.com/cloudfoundry/dropsonde/metrics"
 	"github.com/cloudfoundry/gunk/diegonats"
-	"github.com/cloudfoundry/gunk/workpool"
+	"github.com/pivotal-golang/clock/fakeclock"
 	"github.com/pivotal-golang/lager/lagertest"
+	"github.com/tedsuo/ifrit"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -18,6 +23,8 @@ import (
 
 var _ = Describe("NatsEmitter", func() {
 	var emitter nats_emitter.NATSEmitter
+	var newEmitter func() nats_emitter.NATSEmitter
+	var process ifrit.Process
 	var natsClient *diegonats.FakeNATSClient
 	var fakeMetricSender *fake_metrics_sender.FakeMetricSender
 
@@ -32,23 +39,60 @@ var _ = Describe("NatsEmitter", func() {
 		},
 	}
 
+	publishedCount := func() int {
+		return len(natsClient.PublishedMessages("router.register")) + len(natsClient.PublishedMessages("router.unregister"))
+	}
+
+	// droppedMessages collects the messages reported as dropped by the emits
+	// since it was created
+	droppedMessages := func() func() []route_sink.FailedMessage {
+		dropped := []route_sink.FailedMessage{}
+		return func() []route_sink.FailedMessage {
+			result, err := emitter.Emit(routing_table.MessagesToEmit{})
+			if err != nil {
+				Expect(err).To(BeAssignableToTypeOf(&nats_emitter.DroppedMessagesError{}))
+				Expect(result.DroppedCount()).To(Equal(len(err.(*nats_emitter.DroppedMessagesError).Dropped)))
+				dropped = append(dropped, err.(*nats_emitter.DroppedMessagesError).Dropped...)
+			}
+			return dropped
+		}
+	}
+
 	BeforeEach(func() {
 		natsClient = diegonats.NewFakeClient()
-		logger := lagertest.NewTestLogger("test")
-		workPool, err := workpool.NewWorkPool(1)
-		Expect(err).NotTo(HaveOccurred())
-		emitter = nats_emitter.New(natsClient, workPool, logger)
+		newEmitter = func() nats_emitter.NATSEmitter {
+			return nats_emitter.New(natsClient, lagertest.NewTestLogger("test"))
+		}
 		fakeMetricSender = fake_metrics_sender.NewFakeMetricSender()
 		metrics.Initialize(fakeMetricSender, nil)
 	})
 
+	JustBeforeEach(func() {
+		emitter = newEmitter()
+		process = ifrit.Invoke(emitter)
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive(BeNil()))
+	})
+
 	Describe("Emitting", func() {
+		It("reports the queued messages as pending", func() {
+			result, err := emitter.Emit(messagesToEmit)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Pending).To(Equal(map[string]int{"router.register": 2, "router.unregister": 2}))
+			Expect(result.AttemptedCount()).To(Equal(0))
+			Expect(result.SucceededCount()).To(Equal(0))
+			Expect(result.Failures).To(BeEmpty())
+		})
+
 		It("should emit register and unregister messages", func() {
-			err := emitter.Emit(messagesToEmit)
+			_, err := emitter.Emit(messagesToEmit)
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(natsClient.PublishedMessages("router.register")).To(HaveLen(2))
-			Expect(natsClient.PublishedMessages("router.unregister")).To(HaveLen(2))
+			Eventually(func() []*nats.Msg { return natsClient.PublishedMessages("router.register") }).Should(HaveLen(2))
+			Eventually(func() []*nats.Msg { return natsClient.PublishedMessages("router.unregister") }).Should(HaveLen(2))
 
 			registeredPayloads := [][]byte{
 				natsClient.PublishedMessages("router.register")[0].Data,
@@ -101,8 +145,323 @@ var _ = Describe("NatsEmitter", func() {
 				})
 			})
 
-			It("should error", func() {
-				Expect(emitter.Emit(messagesToEmit)).To(MatchError(errors.New("bam")))
+			It("reports the messages it dropped on a later emit", func() {
+				_, err := emitter.Emit(messagesToEmit)
+				Expect(err).NotTo(HaveOccurred())
+
+				Eventually(droppedMessages()).Should(ConsistOf(
+					route_sink.FailedMessage{Subject: "router.register", Message: messagesToEmit.RegistrationMessages[0], Err: errors.New("bam"), Dropped: true},
+					route_sink.FailedMessage{Subject: "router.register", Message: messagesToEmit.RegistrationMessages[1], Err: errors.New("bam"), Dropped: true},
+				))
+			})
+
+			It("counts the dropped messages", func() {
+				emitter.Emit(messagesToEmit)
+				Eventually(func() uint64 {
+					return fakeMetricSender.GetCounter("NATSEmitterMessagesDropped")
+				}).Should(BeEquivalentTo(2))
+			})
+		})
+
+		It("reports an empty queue once everything is published", func() {
+			_, err := emitter.Emit(messagesToEmit)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(publishedCount).Should(Equal(4))
+			Eventually(func() float64 {
+				return fakeMetricSender.GetValue("NATSEmitterQueueDepth").Value
+			}).Should(BeEquivalentTo(0))
+		})
+	})
+
+	Describe("Retrying", func() {
+		var (
+			clock    *fakeclock.FakeClock
+			failures int
+			options  nats_emitter.Options
+		)
+
+		registration := routing_table.RegistryMessage{URIs: []string{"foo.com"}, Host: "1.1.1.1", Port: 11}
+		unregistration := routing_table.RegistryMessage{URIs: []string{"foo.com"}, Host: "1.1.1.1", Port: 11}
+
+		BeforeEach(func() {
+			clock = fakeclock.NewFakeClock(time.Now())
+			failures = 0
+
+			var lock sync.Mutex
+			natsClient.WhenPublishing("router.register", func(*nats.Msg) error {
+				lock.Lock()
+				defer lock.Unlock()
+				if failures > 0 {
+					failures--
+					return errors.New("bam")
+				}
+				return nil
+			})
+
+			options = nats_emitter.Options{
+				MaxRetries:              2,
+				RetryInterval:           time.Second,
+				MaxRetryInterval:        time.Second,
+				DeadLetterCapacity:      1,
+				DeadLetterRetryInterval: 10 * time.Second,
+				Clock:                   clock,
+			}
+
+			newEmitter = func() nats_emitter.NATSEmitter {
+				return nats_emitter.NewWithOptions(natsClient, options, lagertest.NewTestLogger("test"))
+			}
+		})
+
+		Context("when a publish fails and then succeeds", func() {
+			BeforeEach(func() {
+				failures = 2
+			})
+
+			It("retries with backoff until it succeeds, without blocking the emit", func() {
+				_, err := emitter.Emit(routing_table.MessagesToEmit{RegistrationMessages: []routing_table.RegistryMessage{registration}})
+				Expect(err).NotTo(HaveOccurred())
+
+				// the dead letter retrier's ticker, and the retry's timer
+				Eventually(clock.WatcherCount).Should(Equal(2))
+				clock.Increment(time.Second)
+				Eventually(clock.WatcherCount).Should(Equal(2))
+				clock.Increment(time.Second)
+
+				Eventually(func() []*nats.Msg { return natsClient.PublishedMessages("router.register") }).Should(HaveLen(1))
+				Expect(fakeMetricSender.GetCounter("NATSEmitterPublishRetries")).To(BeEquivalentTo(2))
+			})
+
+			It("cancels the retry when a newer message for the same route is emitted", func() {
+				_, err := emitter.Emit(routing_table.MessagesToEmit{RegistrationMessages: []routing_table.RegistryMessage{registration}})
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(clock.WatcherCount).Should(Equal(2))
+
+				_, err = emitter.Emit(routing_table.MessagesToEmit{UnregistrationMessages: []routing_table.RegistryMessage{unregistration}})
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(func() []*nats.Msg { return natsClient.PublishedMessages("router.unregister") }).Should(HaveLen(1))
+
+				clock.Increment(time.Second)
+				Consistently(func() []*nats.Msg { return natsClient.PublishedMessages("router.register") }).Should(BeEmpty())
+			})
+		})
+
+		Context("when a publish fails on every attempt", func() {
+			BeforeEach(func() {
+				failures = 3
+			})
+
+			It("keeps the message as a dead letter", func() {
+				_, err := emitter.Emit(routing_table.MessagesToEmit{RegistrationMessages: []routing_table.RegistryMessage{registration}})
+				Expect(err).NotTo(HaveOccurred())
+
+				// the dead letter retrier's ticker, and the retry's timer
+				Eventually(clock.WatcherCount).Should(Equal(2))
+				clock.Increment(time.Second)
+				Eventually(clock.WatcherCount).Should(Equal(2))
+				clock.Increment(time.Second)
+
+				Eventually(func() float64 {
+					return fakeMetricSender.GetValue("NATSEmitterDeadLetters").Value
+				}).Should(BeEquivalentTo(1))
+			})
+		})
+
+		Context("with dead letters", func() {
+			BeforeEach(func() {
+				options.MaxRetries = 0
+				failures = 1
+			})
+
+			JustBeforeEach(func() {
+				_, err := emitter.Emit(routing_table.MessagesToEmit{RegistrationMessages: []routing_table.RegistryMessage{registration}})
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(func() float64 {
+					return fakeMetricSender.GetValue("NATSEmitterDeadLetters").Value
+				}).Should(BeEquivalentTo(1))
+				Expect(natsClient.PublishedMessages("router.register")).To(BeEmpty())
+			})
+
+			It("publishes them again once NATS is reachable, without waiting for an emit", func() {
+				clock.Increment(10 * time.Second)
+				Eventually(func() []*nats.Msg { return natsClient.PublishedMessages("router.register") }).Should(HaveLen(1))
+				Expect(fakeMetricSender.GetValue("NATSEmitterDeadLetters").Value).To(BeEquivalentTo(0))
+			})
+
+			It("does not publish them on the next emit", func() {
+				_, err := emitter.Emit(routing_table.MessagesToEmit{})
+				Expect(err).NotTo(HaveOccurred())
+				Consistently(func() []*nats.Msg { return natsClient.PublishedMessages("router.register") }).Should(BeEmpty())
+			})
+
+			It("discards them when a newer message for the same route is emitted", func() {
+				_, err := emitter.Emit(routing_table.MessagesToEmit{
+					UnregistrationMessages: []routing_table.RegistryMessage{unregistration},
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Eventually(func() []*nats.Msg { return natsClient.PublishedMessages("router.unregister") }).Should(HaveLen(1))
+				Consistently(func() []*nats.Msg { return natsClient.PublishedMessages("router.register") }).Should(BeEmpty())
+			})
+
+			Context("when the dead letter buffer overflows", func() {
+				It("drops the oldest message and reports it", func() {
+					other := routing_table.RegistryMessage{URIs: []string{"bar.com"}, Host: "2.2.2.2", Port: 22}
+					natsClient.WhenPublishing("router.register", func(msg *nats.Msg) error {
+						return errors.New("still down")
+					})
+
+					_, err := emitter.Emit(routing_table.MessagesToEmit{RegistrationMessages: []routing_table.RegistryMessage{other}})
+					Expect(err).NotTo(HaveOccurred())
+
+					Eventually(func() uint64 {
+						return fakeMetricSender.GetCounter("NATSEmitterMessagesDropped")
+					}).Should(BeEquivalentTo(1))
+
+					_, err = emitter.Emit(routing_table.MessagesToEmit{})
+					Expect(err).To(BeAssignableToTypeOf(&nats_emitter.DroppedMessagesError{}))
+
+					dropped := err.(*nats_emitter.DroppedMessagesError).Dropped
+					Expect(dropped).To(HaveLen(1))
+					Expect(dropped[0].Message).To(Equal(registration))
+				})
+			})
+		})
+	})
+
+	Describe("Rate limiting", func() {
+		var clock *fakeclock.FakeClock
+
+		BeforeEach(func() {
+			clock = fakeclock.NewFakeClock(time.Now())
+
+			newEmitter = func() nats_emitter.NATSEmitter {
+				return nats_emitter.NewWithOptions(natsClient, nats_emitter.Options{
+					PublishRate:  2,
+					PublishBurst: 2,
+					DrainTimeout: 2 * time.Second,
+					Clock:        clock,
+				}, lagertest.NewTestLogger("test"))
+			}
+		})
+
+		It("publishes no more than the configured rate, without blocking the emit", func() {
+			_, err := emitter.Emit(messagesToEmit)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(publishedCount).Should(Equal(2))
+			Eventually(clock.WatcherCount).Should(Equal(1))
+			Consistently(publishedCount).Should(Equal(2))
+			Expect(fakeMetricSender.GetValue("NATSEmitterQueueDepth").Value).To(BeEquivalentTo(2))
+
+			clock.Increment(500 * time.Millisecond)
+			Eventually(publishedCount).Should(Equal(3))
+			Eventually(clock.WatcherCount).Should(Equal(1))
+
+			clock.Increment(500 * time.Millisecond)
+			Eventually(publishedCount).Should(Equal(4))
+		})
+
+		It("reports how long the next publish waits for the rate limit", func() {
+			_, err := emitter.Emit(messagesToEmit)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(clock.WatcherCount).Should(Equal(1))
+			Expect(fakeMetricSender.GetValue("NATSEmitterThrottleWait").Value).To(BeNumerically("==", 500*time.Millisecond))
+		})
+
+		It("replaces a queued message with a newer one for the same route", func() {
+			_, err := emitter.Emit(messagesToEmit)
+			Expect(err).NotTo(HaveOccurred())
+
+			// the first registration is waiting for the rate limit
+			Eventually(publishedCount).Should(Equal(2))
+			Eventually(clock.WatcherCount).Should(Equal(1))
+
+			_, err = emitter.Emit(routing_table.MessagesToEmit{
+				UnregistrationMessages: messagesToEmit.RegistrationMessages[1:],
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			for i := 0; i < 2; i++ {
+				Eventually(clock.WatcherCount).Should(Equal(1))
+				clock.Increment(500 * time.Millisecond)
+			}
+
+			Eventually(publishedCount).Should(Equal(4))
+			Expect(natsClient.PublishedMessages("router.register")).To(HaveLen(1))
+			Expect(natsClient.PublishedMessages("router.unregister")).To(HaveLen(3))
+		})
+
+		It("publishes the unregistrations ahead of the queued registrations", func() {
+			var lock sync.Mutex
+			subjects := []string{}
+			record := func(msg *nats.Msg) error {
+				lock.Lock()
+				subjects = append(subjects, msg.Subject)
+				lock.Unlock()
+				return nil
+			}
+			natsClient.WhenPublishing("router.register", record)
+			natsClient.WhenPublishing("router.unregister", record)
+
+			_, err := emitter.Emit(routing_table.MessagesToEmit{RegistrationMessages: []routing_table.RegistryMessage{
+				{URIs: []string{"a.com"}, Host: "1.1.1.1", Port: 11},
+				{URIs: []string{"b.com"}, Host: "1.1.1.1", Port: 11},
+				{URIs: []string{"c.com"}, Host: "1.1.1.1", Port: 11},
+				{URIs: []string{"d.com"}, Host: "1.1.1.1", Port: 11},
+			}})
+			Expect(err).NotTo(HaveOccurred())
+
+			// the third registration is waiting for the rate limit
+			Eventually(publishedCount).Should(Equal(2))
+			Eventually(clock.WatcherCount).Should(Equal(1))
+
+			_, err = emitter.Emit(messagesToEmit)
+			Expect(err).NotTo(HaveOccurred())
+
+			// all but the first two messages wait for the rate limit
+			for i := 0; i < 6; i++ {
+				Eventually(clock.WatcherCount).Should(Equal(1))
+				clock.Increment(500 * time.Millisecond)
+			}
+			Eventually(publishedCount).Should(Equal(8))
+
+			lock.Lock()
+			defer lock.Unlock()
+			Expect(subjects[:5]).To(Equal([]string{
+				"router.register", "router.register", "router.register", "router.unregister", "router.unregister",
+			}))
+		})
+
+		Context("when it is signalled to stop", func() {
+			var exited <-chan error
+
+			JustBeforeEach(func() {
+				_, err := emitter.Emit(messagesToEmit)
+				Expect(err).NotTo(HaveOccurred())
+
+				// the first registration is waiting for the rate limit
+				Eventually(publishedCount).Should(Equal(2))
+				Eventually(clock.WatcherCount).Should(Equal(1))
+
+				exited = process.Wait()
+				process.Signal(os.Interrupt)
+			})
+
+			It("publishes the queued messages before exiting", func() {
+				Eventually(func() int {
+					clock.Increment(100 * time.Millisecond)
+					return publishedCount()
+				}).Should(Equal(4))
+				Eventually(exited).Should(Receive(BeNil()))
+			})
+
+			It("gives up on the queued messages after the drain timeout", func() {
+				Consistently(exited).ShouldNot(Receive())
+
+				clock.Increment(2 * time.Second)
+				Eventually(exited).Should(Receive(BeNil()))
+				Expect(publishedCount()).To(BeNumerically("<", 4))
 			})
 		})
 	})
@@ -0,0 +1,176 @@
+package nats_emitter
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/cloudfoundry-incubator/route-emitter/route_sink"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+)
+
+// publishQueue holds the messages waiting to be published, unregistrations
+// ahead of registrations, so that stale backends stop receiving traffic as
+// soon as possible. Only the latest message for an endpoint and set of URIs
+// is queued: queueing a message replaces any older one for the same route.
+type queuedMessage struct {
+	subject string
+	message routing_table.RegistryMessage
+
+	// attempt is the number of times the message failed to publish
+	attempt int
+}
+
+type publishQueue struct {
+	lock            sync.Mutex
+	unregistrations *list.List
+	registrations   *list.List
+	elements        map[messageKey]*list.Element
+
+	// retrying holds the messages that failed to publish and wait for their
+	// retry backoff before they are queued again
+	retrying map[messageKey]*queuedMessage
+
+	// publishing is set from pop until done, while the popped message waits
+	// for the rate limit
+	publishing bool
+
+	ready chan struct{}
+}
+
+func newPublishQueue() *publishQueue {
+	return &publishQueue{
+		unregistrations: list.New(),
+		registrations:   list.New(),
+		elements:        map[messageKey]*list.Element{},
+		retrying:        map[messageKey]*queuedMessage{},
+		ready:           make(chan struct{}, 1),
+	}
+}
+
+// push queues the message, replacing any older message for the same route,
+// and cancelling its retry.
+func (q *publishQueue) push(subject string, message routing_table.RegistryMessage) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	delete(q.retrying, messageKeyFor(message))
+	q.pushLocked(queuedMessage{subject: subject, message: message})
+}
+
+func (q *publishQueue) pushLocked(message queuedMessage) {
+	key := messageKeyFor(message.message)
+	if element, found := q.elements[key]; found {
+		q.listFor(element.Value.(queuedMessage).subject).Remove(element)
+	}
+
+	q.elements[key] = q.listFor(message.subject).PushBack(message)
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// requeue queues a message that failed to publish earlier, unless a newer
+// message for the same route is queued or waiting to be retried.
+func (q *publishQueue) requeue(message queuedMessage) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.pendingLocked(message.message) {
+		return
+	}
+	q.pushLocked(message)
+}
+
+// retryLater records that the message waits to be retried, and returns it to
+// be passed to retry once its backoff has passed.
+func (q *publishQueue) retryLater(message queuedMessage) *queuedMessage {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	retry := &message
+	q.retrying[messageKeyFor(message.message)] = retry
+	return retry
+}
+
+// retry queues a message passed to retryLater, unless a newer message for the
+// same route was pushed in the meantime.
+func (q *publishQueue) retry(retry *queuedMessage) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	key := messageKeyFor(retry.message)
+	if q.retrying[key] != retry {
+		return
+	}
+
+	delete(q.retrying, key)
+	q.pushLocked(*retry)
+}
+
+// pending reports whether a message for the same route is queued or waiting
+// to be retried.
+func (q *publishQueue) pending(message routing_table.RegistryMessage) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.pendingLocked(message)
+}
+
+func (q *publishQueue) pendingLocked(message routing_table.RegistryMessage) bool {
+	key := messageKeyFor(message)
+	if _, found := q.elements[key]; found {
+		return true
+	}
+	_, found := q.retrying[key]
+	return found
+}
+
+// pop removes and returns the next message to publish.
+func (q *publishQueue) pop() (queuedMessage, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	element := q.unregistrations.Front()
+	if element == nil {
+		element = q.registrations.Front()
+	}
+	if element == nil {
+		return queuedMessage{}, false
+	}
+
+	message := element.Value.(queuedMessage)
+	q.listFor(message.subject).Remove(element)
+	delete(q.elements, messageKeyFor(message.message))
+	q.publishing = true
+
+	return message, true
+}
+
+// done marks the message returned by pop as published.
+func (q *publishQueue) done() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.publishing = false
+}
+
+// len is the number of messages waiting to be published, including the one
+// being throttled.
+func (q *publishQueue) len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.publishing {
+		return len(q.elements) + 1
+	}
+	return len(q.elements)
+}
+
+func (q *publishQueue) listFor(subject string) *list.List {
+	if subject == route_sink.UnregisterSubject {
+		return q.unregistrations
+	}
+	return q.registrations
+}
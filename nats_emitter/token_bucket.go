@@ -0,0 +1,57 @@
+package nats_emitter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+)
+
+// tokenBucket limits the rate at which messages are published. It holds up
+// to burst tokens and is refilled at rate tokens per second; every publish
+// takes a token, waiting for one to become available if the bucket is empty.
+type tokenBucket struct {
+	rate  float64
+	burst float64
+	clock clock.Clock
+
+	lock       sync.Mutex
+	tokens     float64
+	lastRefill time.Time
+}
+
+func newTokenBucket(rate, burst int, clock clock.Clock) *tokenBucket {
+	if burst <= 0 {
+		burst = rate
+	}
+
+	return &tokenBucket{
+		rate:       float64(rate),
+		burst:      float64(burst),
+		clock:      clock,
+		tokens:     float64(burst),
+		lastRefill: clock.Now(),
+	}
+}
+
+// reserve takes a token and returns how long the caller must wait before
+// using it. The bucket goes into debt when it is empty, so tokens are handed
+// out in the order reserve is called.
+func (bucket *tokenBucket) reserve() time.Duration {
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	now := bucket.clock.Now()
+	bucket.tokens += now.Sub(bucket.lastRefill).Seconds() * bucket.rate
+	if bucket.tokens > bucket.burst {
+		bucket.tokens = bucket.burst
+	}
+	bucket.lastRefill = now
+
+	bucket.tokens--
+	if bucket.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-bucket.tokens / bucket.rate * float64(time.Second))
+}
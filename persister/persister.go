@@ -0,0 +1,167 @@
+package persister
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/pivotal-golang/clock"
+	"github.com/pivotal-golang/lager"
+)
+
+var (
+	ErrCorruptSnapshot      = errors.New("routing table snapshot is corrupt")
+	ErrIncompatibleSnapshot = errors.New("routing table snapshot has an incompatible version")
+)
+
+type snapshotFile struct {
+	Version  int             `json:"version"`
+	Checksum string          `json:"checksum"`
+	Table    json.RawMessage `json:"table"`
+}
+
+// Save writes the snapshot to path. The snapshot is written to a temporary
+// file in the same directory and renamed into place, so a crash never leaves
+// a partially written snapshot behind.
+func Save(path string, snapshot routing_table.Snapshot) error {
+	table, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(snapshotFile{
+		Version:  routing_table.SnapshotVersion,
+		Checksum: checksum(table),
+		Table:    table,
+	})
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmpFile.Write(payload)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	closeErr := tmpFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return err
+	}
+
+	err = os.Rename(tmpFile.Name(), path)
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return err
+	}
+
+	return nil
+}
+
+// Load reads a snapshot previously written by Save. It returns
+// ErrCorruptSnapshot if the file cannot be decoded or fails its checksum, and
+// ErrIncompatibleSnapshot if it was written in a different format version.
+func Load(path string) (routing_table.Snapshot, error) {
+	var snapshot routing_table.Snapshot
+
+	payload, err := ioutil.ReadFile(path)
+	if err != nil {
+		return snapshot, err
+	}
+
+	var file snapshotFile
+	err = json.Unmarshal(payload, &file)
+	if err != nil {
+		return snapshot, ErrCorruptSnapshot
+	}
+
+	if file.Version != routing_table.SnapshotVersion {
+		return snapshot, ErrIncompatibleSnapshot
+	}
+
+	if file.Checksum != checksum(file.Table) {
+		return snapshot, ErrCorruptSnapshot
+	}
+
+	err = json.Unmarshal(file.Table, &snapshot)
+	if err != nil {
+		return routing_table.Snapshot{}, ErrCorruptSnapshot
+	}
+
+	return snapshot, nil
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+type Persister struct {
+	path     string
+	interval time.Duration
+	table    routing_table.RoutingTable
+	clock    clock.Clock
+	logger   lager.Logger
+}
+
+func New(
+	path string,
+	interval time.Duration,
+	table routing_table.RoutingTable,
+	clock clock.Clock,
+	logger lager.Logger,
+) *Persister {
+	return &Persister{
+		path:     path,
+		interval: interval,
+		table:    table,
+		clock:    clock,
+		logger:   logger.Session("persister"),
+	}
+}
+
+func (p *Persister) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	p.logger.Info("starting", lager.Data{"path": p.path, "interval": p.interval.String()})
+
+	ticker := p.clock.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	close(ready)
+	p.logger.Info("started")
+
+	for {
+		select {
+		case <-ticker.C():
+			p.save()
+		case <-signals:
+			p.logger.Info("stopping")
+			p.save()
+			return nil
+		}
+	}
+}
+
+func (p *Persister) save() {
+	snapshot := p.table.Snapshot()
+
+	err := Save(p.path, snapshot)
+	if err != nil {
+		p.logger.Error("failed-to-save-snapshot", err)
+		return
+	}
+
+	p.logger.Debug("saved-snapshot", lager.Data{"entries": len(snapshot.Entries)})
+}
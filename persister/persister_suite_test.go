@@ -0,0 +1,13 @@
+package persister_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestPersister(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Persister Suite")
+}
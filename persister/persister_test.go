@@ -0,0 +1,209 @@
+package persister_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudfoundry-incubator/bbs/models"
+	"github.com/cloudfoundry-incubator/route-emitter/persister"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table/fake_routing_table"
+	"github.com/pivotal-golang/clock/fakeclock"
+	"github.com/pivotal-golang/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Persister", func() {
+	var (
+		tmpDir       string
+		snapshotPath string
+		snapshot     routing_table.Snapshot
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "persister")
+		Expect(err).NotTo(HaveOccurred())
+
+		snapshotPath = filepath.Join(tmpDir, "routing_table.json")
+
+		snapshot = routing_table.Snapshot{
+			Entries: []routing_table.SnapshotEntry{
+				{
+					ProcessGuid:     "process-guid",
+					ContainerPort:   8080,
+					Hostnames:       []string{"foo.example.com"},
+					LogGuid:         "log-guid",
+					ModificationTag: &models.ModificationTag{Epoch: "abc", Index: 1},
+					Endpoints: []routing_table.Endpoint{
+						{InstanceGuid: "ig-1", Host: "1.1.1.1", Port: 11, ContainerPort: 8080},
+					},
+				},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	Describe("Save and Load", func() {
+		It("round-trips the snapshot", func() {
+			Expect(persister.Save(snapshotPath, snapshot)).To(Succeed())
+
+			loaded, err := persister.Load(snapshotPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loaded).To(Equal(snapshot))
+		})
+
+		It("does not leave temporary files behind", func() {
+			Expect(persister.Save(snapshotPath, snapshot)).To(Succeed())
+
+			files, err := ioutil.ReadDir(tmpDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(files).To(HaveLen(1))
+			Expect(files[0].Name()).To(Equal("routing_table.json"))
+		})
+
+		It("writes the table with snake_case keys", func() {
+			Expect(persister.Save(snapshotPath, snapshot)).To(Succeed())
+
+			payload, err := ioutil.ReadFile(snapshotPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			var file struct {
+				Table struct {
+					Entries []struct {
+						Endpoints []map[string]interface{} `json:"endpoints"`
+					} `json:"entries"`
+				} `json:"table"`
+			}
+			Expect(json.Unmarshal(payload, &file)).To(Succeed())
+
+			endpoint := file.Table.Entries[0].Endpoints[0]
+			Expect(endpoint).To(HaveKeyWithValue("instance_guid", "ig-1"))
+			Expect(endpoint).To(HaveKeyWithValue("host", "1.1.1.1"))
+			Expect(endpoint).To(HaveKeyWithValue("container_port", BeNumerically("==", 8080)))
+			Expect(endpoint).NotTo(HaveKey("InstanceGuid"))
+		})
+
+		It("persists only the HTTP routing table, leaving TCP routes to the first sync", func() {
+			Expect(persister.Save(snapshotPath, snapshot)).To(Succeed())
+
+			payload, err := ioutil.ReadFile(snapshotPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			var file struct {
+				Table map[string]json.RawMessage `json:"table"`
+			}
+			Expect(json.Unmarshal(payload, &file)).To(Succeed())
+			Expect(file.Table).To(HaveLen(1))
+			Expect(file.Table).To(HaveKey("entries"))
+		})
+
+		Context("when the snapshot does not exist", func() {
+			It("returns a not-exist error", func() {
+				_, err := persister.Load(snapshotPath)
+				Expect(os.IsNotExist(err)).To(BeTrue())
+			})
+		})
+
+		Context("when the snapshot is not valid JSON", func() {
+			BeforeEach(func() {
+				Expect(ioutil.WriteFile(snapshotPath, []byte(`{"version":1,"checksum":`), 0644)).To(Succeed())
+			})
+
+			It("returns ErrCorruptSnapshot", func() {
+				_, err := persister.Load(snapshotPath)
+				Expect(err).To(Equal(persister.ErrCorruptSnapshot))
+			})
+		})
+
+		Context("when the table does not match its checksum", func() {
+			BeforeEach(func() {
+				Expect(persister.Save(snapshotPath, snapshot)).To(Succeed())
+
+				payload, err := ioutil.ReadFile(snapshotPath)
+				Expect(err).NotTo(HaveOccurred())
+
+				var file map[string]interface{}
+				Expect(json.Unmarshal(payload, &file)).To(Succeed())
+				file["table"] = map[string]interface{}{"entries": []interface{}{}}
+
+				payload, err = json.Marshal(file)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ioutil.WriteFile(snapshotPath, payload, 0644)).To(Succeed())
+			})
+
+			It("returns ErrCorruptSnapshot", func() {
+				_, err := persister.Load(snapshotPath)
+				Expect(err).To(Equal(persister.ErrCorruptSnapshot))
+			})
+		})
+
+		Context("when the snapshot was written with a different version", func() {
+			BeforeEach(func() {
+				payload := `{"version":999,"checksum":"","table":{}}`
+				Expect(ioutil.WriteFile(snapshotPath, []byte(payload), 0644)).To(Succeed())
+			})
+
+			It("returns ErrIncompatibleSnapshot", func() {
+				_, err := persister.Load(snapshotPath)
+				Expect(err).To(Equal(persister.ErrIncompatibleSnapshot))
+			})
+		})
+	})
+
+	Describe("Run", func() {
+		var (
+			table   *fake_routing_table.FakeRoutingTable
+			clock   *fakeclock.FakeClock
+			process ifrit.Process
+		)
+
+		BeforeEach(func() {
+			table = &fake_routing_table.FakeRoutingTable{}
+			table.SnapshotReturns(snapshot)
+			clock = fakeclock.NewFakeClock(time.Now())
+
+			runner := persister.New(snapshotPath, 10*time.Second, table, clock, lagertest.NewTestLogger("test"))
+			process = ifrit.Invoke(runner)
+		})
+
+		AfterEach(func() {
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive(BeNil()))
+		})
+
+		It("snapshots the table every interval", func() {
+			Consistently(table.SnapshotCallCount).Should(Equal(0))
+
+			Eventually(clock.WatcherCount).Should(Equal(1))
+			clock.Increment(10 * time.Second)
+			Eventually(table.SnapshotCallCount).Should(Equal(1))
+
+			loaded, err := persister.Load(snapshotPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loaded).To(Equal(snapshot))
+		})
+
+		Context("when signalled", func() {
+			It("snapshots the table before exiting", func() {
+				process.Signal(os.Interrupt)
+				Eventually(process.Wait()).Should(Receive(BeNil()))
+
+				Expect(table.SnapshotCallCount()).To(Equal(1))
+
+				loaded, err := persister.Load(snapshotPath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(loaded).To(Equal(snapshot))
+			})
+		})
+	})
+})
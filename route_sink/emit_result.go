@@ -0,0 +1,182 @@
+package route_sink
+
+import (
+	"encoding/json"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+)
+
+// The subjects by which emit results are broken down. They match the NATS
+// subjects the gorouter listens on.
+const (
+	RegisterSubject   = "router.register"
+	UnregisterSubject = "router.unregister"
+)
+
+// EmitResult is the outcome of emitting a batch of messages. The counts of
+// messages are broken down by subject. Pending messages have been queued by a
+// sink that emits them later, and are not counted as attempted.
+type EmitResult struct {
+	Attempted map[string]int  `json:"attempted"`
+	Succeeded map[string]int  `json:"succeeded"`
+	Failed    map[string]int  `json:"failed"`
+	Pending   map[string]int  `json:"pending,omitempty"`
+	Failures  []FailedMessage `json:"failures,omitempty"`
+}
+
+// FailedMessage is a message that a sink could not emit.
+type FailedMessage struct {
+	Sink    string
+	Subject string
+	Message routing_table.RegistryMessage
+	Err     error
+
+	// Dropped is set if the sink will not attempt to emit the message again.
+	Dropped bool
+}
+
+func (failure FailedMessage) MarshalJSON() ([]byte, error) {
+	var errorMessage string
+	if failure.Err != nil {
+		errorMessage = failure.Err.Error()
+	}
+
+	return json.Marshal(struct {
+		Sink    string                        `json:"sink,omitempty"`
+		Subject string                        `json:"subject"`
+		Message routing_table.RegistryMessage `json:"message"`
+		Error   string                        `json:"error"`
+		Dropped bool                          `json:"dropped"`
+	}{failure.Sink, failure.Subject, failure.Message, errorMessage, failure.Dropped})
+}
+
+// BatchResult is the result of emitting the messages as a batch that
+// succeeded or failed with err as a whole. Failed messages are dropped.
+func BatchResult(messagesToEmit routing_table.MessagesToEmit, err error) EmitResult {
+	result := EmitResult{}
+	result.init()
+
+	if err == nil {
+		result.Succeed(RegisterSubject, len(messagesToEmit.RegistrationMessages))
+		result.Succeed(UnregisterSubject, len(messagesToEmit.UnregistrationMessages))
+		return result
+	}
+
+	for _, message := range messagesToEmit.RegistrationMessages {
+		result.Fail(FailedMessage{Subject: RegisterSubject, Message: message, Err: err, Dropped: true})
+	}
+	for _, message := range messagesToEmit.UnregistrationMessages {
+		result.Fail(FailedMessage{Subject: UnregisterSubject, Message: message, Err: err, Dropped: true})
+	}
+
+	return result
+}
+
+// Succeed records that count messages were emitted on the subject.
+func (result *EmitResult) Succeed(subject string, count int) {
+	if count == 0 {
+		return
+	}
+
+	result.init()
+	result.Attempted[subject] += count
+	result.Succeeded[subject] += count
+}
+
+// Queue records that count messages on the subject were queued to be emitted
+// later.
+func (result *EmitResult) Queue(subject string, count int) {
+	if count == 0 {
+		return
+	}
+
+	result.init()
+	result.Pending[subject] += count
+}
+
+// Fail records that a message could not be emitted.
+func (result *EmitResult) Fail(failure FailedMessage) {
+	result.init()
+	result.Attempted[failure.Subject]++
+	result.Failed[failure.Subject]++
+	result.Failures = append(result.Failures, failure)
+}
+
+// Drop records that a message which failed in an earlier emit has been
+// dropped. It does not count as an attempt.
+func (result *EmitResult) Drop(failure FailedMessage) {
+	failure.Dropped = true
+	result.Failures = append(result.Failures, failure)
+}
+
+// Merge adds the counts and failures of other to the result.
+func (result *EmitResult) Merge(other EmitResult) {
+	result.init()
+	for subject, count := range other.Attempted {
+		result.Attempted[subject] += count
+	}
+	for subject, count := range other.Succeeded {
+		result.Succeeded[subject] += count
+	}
+	for subject, count := range other.Failed {
+		result.Failed[subject] += count
+	}
+	for subject, count := range other.Pending {
+		result.Pending[subject] += count
+	}
+	result.Failures = append(result.Failures, other.Failures...)
+}
+
+func (result EmitResult) AttemptedCount() int {
+	return sum(result.Attempted)
+}
+
+func (result EmitResult) SucceededCount() int {
+	return sum(result.Succeeded)
+}
+
+func (result EmitResult) FailedCount() int {
+	return sum(result.Failed)
+}
+
+func (result EmitResult) PendingCount() int {
+	return sum(result.Pending)
+}
+
+func (result EmitResult) DroppedCount() int {
+	count := 0
+	for _, failure := range result.Failures {
+		if failure.Dropped {
+			count++
+		}
+	}
+	return count
+}
+
+// PartialFailure reports whether some, but not all, of the messages failed.
+func (result EmitResult) PartialFailure() bool {
+	return result.FailedCount() > 0 && result.SucceededCount() > 0
+}
+
+func (result *EmitResult) init() {
+	if result.Attempted == nil {
+		result.Attempted = map[string]int{}
+	}
+	if result.Succeeded == nil {
+		result.Succeeded = map[string]int{}
+	}
+	if result.Failed == nil {
+		result.Failed = map[string]int{}
+	}
+	if result.Pending == nil {
+		result.Pending = map[string]int{}
+	}
+}
+
+func sum(counts map[string]int) int {
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	return total
+}
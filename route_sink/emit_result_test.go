@@ -0,0 +1,108 @@
+package route_sink_test
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/cloudfoundry-incubator/route-emitter/route_sink"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("EmitResult", func() {
+	messagesToEmit := routing_table.MessagesToEmit{
+		RegistrationMessages: []routing_table.RegistryMessage{
+			{URIs: []string{"foo.com"}, Host: "1.1.1.1", Port: 11},
+			{URIs: []string{"bar.com"}, Host: "1.1.1.1", Port: 11},
+		},
+		UnregistrationMessages: []routing_table.RegistryMessage{
+			{URIs: []string{"baz.com"}, Host: "2.2.2.2", Port: 22},
+		},
+	}
+
+	Describe("BatchResult", func() {
+		It("counts every message as succeeded when there is no error", func() {
+			result := route_sink.BatchResult(messagesToEmit, nil)
+
+			Expect(result.Attempted).To(Equal(map[string]int{"router.register": 2, "router.unregister": 1}))
+			Expect(result.Succeeded).To(Equal(result.Attempted))
+			Expect(result.Failed).To(BeEmpty())
+			Expect(result.Failures).To(BeEmpty())
+		})
+
+		It("reports every message as dropped when there is an error", func() {
+			result := route_sink.BatchResult(messagesToEmit, errors.New("boom"))
+
+			Expect(result.Failed).To(Equal(map[string]int{"router.register": 2, "router.unregister": 1}))
+			Expect(result.SucceededCount()).To(Equal(0))
+			Expect(result.DroppedCount()).To(Equal(3))
+			Expect(result.Failures[2]).To(Equal(route_sink.FailedMessage{
+				Subject: "router.unregister",
+				Message: messagesToEmit.UnregistrationMessages[0],
+				Err:     errors.New("boom"),
+				Dropped: true,
+			}))
+		})
+	})
+
+	Describe("Merge", func() {
+		It("adds up the counts and failures", func() {
+			result := route_sink.BatchResult(messagesToEmit, nil)
+			result.Merge(route_sink.BatchResult(messagesToEmit, errors.New("boom")))
+
+			Expect(result.Attempted).To(Equal(map[string]int{"router.register": 4, "router.unregister": 2}))
+			Expect(result.SucceededCount()).To(Equal(3))
+			Expect(result.FailedCount()).To(Equal(3))
+			Expect(result.Failures).To(HaveLen(3))
+			Expect(result.PartialFailure()).To(BeTrue())
+		})
+	})
+
+	Describe("Queue", func() {
+		It("records pending messages without counting them as attempted", func() {
+			result := route_sink.EmitResult{}
+			result.Queue("router.register", 2)
+
+			other := route_sink.EmitResult{}
+			other.Queue("router.register", 1)
+			result.Merge(other)
+
+			Expect(result.Pending).To(Equal(map[string]int{"router.register": 3}))
+			Expect(result.PendingCount()).To(Equal(3))
+			Expect(result.AttemptedCount()).To(Equal(0))
+			Expect(result.SucceededCount()).To(Equal(0))
+		})
+	})
+
+	Describe("Drop", func() {
+		It("records a dropped message without counting an attempt", func() {
+			result := route_sink.EmitResult{}
+			result.Drop(route_sink.FailedMessage{Subject: "router.register", Err: errors.New("boom")})
+
+			Expect(result.AttemptedCount()).To(Equal(0))
+			Expect(result.DroppedCount()).To(Equal(1))
+		})
+	})
+
+	Describe("FailedMessage", func() {
+		It("marshals its error as a string", func() {
+			payload, err := json.Marshal(route_sink.FailedMessage{
+				Sink:    "NATS",
+				Subject: "router.register",
+				Message: messagesToEmit.RegistrationMessages[0],
+				Err:     errors.New("boom"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(payload).To(MatchJSON(`{
+				"sink": "NATS",
+				"subject": "router.register",
+				"message": {"host": "1.1.1.1", "port": 11, "uris": ["foo.com"]},
+				"error": "boom",
+				"dropped": false
+			}`))
+		})
+	})
+})
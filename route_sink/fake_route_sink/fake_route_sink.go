@@ -0,0 +1,56 @@
+// This file was generated by counterfeiter
+package fake_route_sink
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/route-emitter/route_sink"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+)
+
+type FakeRouteSink struct {
+	EmitStub        func(messagesToEmit routing_table.MessagesToEmit) (route_sink.EmitResult, error)
+	emitMutex       sync.RWMutex
+	emitArgsForCall []struct {
+		messagesToEmit routing_table.MessagesToEmit
+	}
+	emitReturns struct {
+		result1 route_sink.EmitResult
+		result2 error
+	}
+}
+
+func (fake *FakeRouteSink) Emit(messagesToEmit routing_table.MessagesToEmit) (route_sink.EmitResult, error) {
+	fake.emitMutex.Lock()
+	fake.emitArgsForCall = append(fake.emitArgsForCall, struct {
+		messagesToEmit routing_table.MessagesToEmit
+	}{messagesToEmit})
+	fake.emitMutex.Unlock()
+	if fake.EmitStub != nil {
+		return fake.EmitStub(messagesToEmit)
+	} else {
+		return fake.emitReturns.result1, fake.emitReturns.result2
+	}
+}
+
+func (fake *FakeRouteSink) EmitCallCount() int {
+	fake.emitMutex.RLock()
+	defer fake.emitMutex.RUnlock()
+	return len(fake.emitArgsForCall)
+}
+
+func (fake *FakeRouteSink) EmitArgsForCall(i int) routing_table.MessagesToEmit {
+	fake.emitMutex.RLock()
+	defer fake.emitMutex.RUnlock()
+	return fake.emitArgsForCall[i].messagesToEmit
+}
+
+func (fake *FakeRouteSink) EmitReturns(result1 route_sink.EmitResult, result2 error) {
+	fake.EmitStub = nil
+	fake.emitReturns = struct {
+		result1 route_sink.EmitResult
+		result2 error
+	}{result1, result2}
+}
+
+var _ route_sink.RouteSink = new(FakeRouteSink)
@@ -0,0 +1,95 @@
+package route_sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/pivotal-golang/clock"
+	"github.com/pivotal-golang/lager"
+)
+
+const (
+	RegisterAction   = "register"
+	UnregisterAction = "unregister"
+)
+
+// FileEntry is a line written by the file sink.
+type FileEntry struct {
+	Timestamp int64                         `json:"timestamp"`
+	Action    string                        `json:"action"`
+	Message   routing_table.RegistryMessage `json:"message"`
+}
+
+type fileSink struct {
+	path   string
+	clock  clock.Clock
+	logger lager.Logger
+
+	lock sync.Mutex
+}
+
+// NewFileSink returns a RouteSink that appends every message to the file at
+// path as a line of JSON. The file is opened for every batch, so it can be
+// rotated by moving it aside.
+func NewFileSink(path string, clock clock.Clock, logger lager.Logger) RouteSink {
+	return &fileSink{
+		path:   path,
+		clock:  clock,
+		logger: logger.Session("file-sink", lager.Data{"path": path}),
+	}
+}
+
+func (f *fileSink) Emit(messagesToEmit routing_table.MessagesToEmit) (EmitResult, error) {
+	err := f.write(messagesToEmit)
+	return BatchResult(messagesToEmit, err), err
+}
+
+func (f *fileSink) write(messagesToEmit routing_table.MessagesToEmit) error {
+	if len(messagesToEmit.RegistrationMessages) == 0 && len(messagesToEmit.UnregistrationMessages) == 0 {
+		return nil
+	}
+
+	timestamp := f.clock.Now().UnixNano()
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+
+	for _, message := range messagesToEmit.RegistrationMessages {
+		err := encoder.Encode(FileEntry{Timestamp: timestamp, Action: RegisterAction, Message: message})
+		if err != nil {
+			f.logger.Error("failed-to-marshal", err)
+			return err
+		}
+	}
+
+	for _, message := range messagesToEmit.UnregistrationMessages {
+		err := encoder.Encode(FileEntry{Timestamp: timestamp, Action: UnregisterAction, Message: message})
+		if err != nil {
+			f.logger.Error("failed-to-marshal", err)
+			return err
+		}
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		f.logger.Error("failed-to-open", err)
+		return err
+	}
+
+	_, err = file.Write(buffer.Bytes())
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		f.logger.Error("failed-to-write", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package route_sink_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/route_sink"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/pivotal-golang/clock/fakeclock"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FileSink", func() {
+	var (
+		tmpDir string
+		path   string
+		clock  *fakeclock.FakeClock
+		sink   route_sink.RouteSink
+	)
+
+	messagesToEmit := routing_table.MessagesToEmit{
+		RegistrationMessages: []routing_table.RegistryMessage{
+			{URIs: []string{"foo.com"}, Host: "1.1.1.1", Port: 11},
+		},
+		UnregistrationMessages: []routing_table.RegistryMessage{
+			{URIs: []string{"bar.com"}, Host: "2.2.2.2", Port: 22},
+		},
+	}
+
+	readEntries := func() []route_sink.FileEntry {
+		contents, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		entries := []route_sink.FileEntry{}
+		for _, line := range strings.Split(strings.TrimSpace(string(contents)), "\n") {
+			var entry route_sink.FileEntry
+			Expect(json.Unmarshal([]byte(line), &entry)).To(Succeed())
+			entries = append(entries, entry)
+		}
+		return entries
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "file-sink")
+		Expect(err).NotTo(HaveOccurred())
+
+		path = filepath.Join(tmpDir, "routes.log")
+		clock = fakeclock.NewFakeClock(time.Unix(100, 0))
+		sink = route_sink.NewFileSink(path, clock, lagertest.NewTestLogger("test"))
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	It("writes a line for every message", func() {
+		_, err := sink.Emit(messagesToEmit)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(readEntries()).To(Equal([]route_sink.FileEntry{
+			{Timestamp: time.Unix(100, 0).UnixNano(), Action: route_sink.RegisterAction, Message: messagesToEmit.RegistrationMessages[0]},
+			{Timestamp: time.Unix(100, 0).UnixNano(), Action: route_sink.UnregisterAction, Message: messagesToEmit.UnregistrationMessages[0]},
+		}))
+	})
+
+	It("appends to the file", func() {
+		_, err := sink.Emit(messagesToEmit)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = sink.Emit(messagesToEmit)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(readEntries()).To(HaveLen(4))
+	})
+
+	Context("when there is nothing to emit", func() {
+		It("does not create the file", func() {
+			_, err := sink.Emit(routing_table.MessagesToEmit{})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = os.Stat(path)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
+
+	Context("when the file cannot be opened", func() {
+		BeforeEach(func() {
+			path = filepath.Join(tmpDir, "missing", "routes.log")
+			sink = route_sink.NewFileSink(path, clock, lagertest.NewTestLogger("test"))
+		})
+
+		It("returns an error", func() {
+			_, err := sink.Emit(messagesToEmit)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("reports every message as dropped", func() {
+			result, _ := sink.Emit(messagesToEmit)
+			Expect(result.FailedCount()).To(Equal(2))
+			Expect(result.DroppedCount()).To(Equal(2))
+		})
+	})
+})
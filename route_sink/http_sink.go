@@ -0,0 +1,81 @@
+package route_sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/pivotal-golang/lager"
+)
+
+// HTTPPayload is the body of the requests made by the HTTP sink.
+type HTTPPayload struct {
+	Registrations   []routing_table.RegistryMessage `json:"registrations"`
+	Unregistrations []routing_table.RegistryMessage `json:"unregistrations"`
+}
+
+type httpSink struct {
+	url        string
+	httpClient *http.Client
+	logger     lager.Logger
+}
+
+// NewHTTPSink returns a RouteSink that POSTs each batch of messages to url as
+// an HTTPPayload. Empty batches are not sent.
+func NewHTTPSink(url string, httpClient *http.Client, logger lager.Logger) RouteSink {
+	return &httpSink{
+		url:        url,
+		httpClient: httpClient,
+		logger:     logger.Session("http-sink"),
+	}
+}
+
+func (h *httpSink) Emit(messagesToEmit routing_table.MessagesToEmit) (EmitResult, error) {
+	err := h.post(messagesToEmit)
+	return BatchResult(messagesToEmit, err), err
+}
+
+func (h *httpSink) post(messagesToEmit routing_table.MessagesToEmit) error {
+	if len(messagesToEmit.RegistrationMessages) == 0 && len(messagesToEmit.UnregistrationMessages) == 0 {
+		return nil
+	}
+
+	logger := h.logger.Session("post", lager.Data{
+		"num-registrations":   len(messagesToEmit.RegistrationMessages),
+		"num-unregistrations": len(messagesToEmit.UnregistrationMessages),
+	})
+
+	payload := HTTPPayload{
+		Registrations:   messagesToEmit.RegistrationMessages,
+		Unregistrations: messagesToEmit.UnregistrationMessages,
+	}
+	if payload.Registrations == nil {
+		payload.Registrations = []routing_table.RegistryMessage{}
+	}
+	if payload.Unregistrations == nil {
+		payload.Unregistrations = []routing_table.RegistryMessage{}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		logger.Error("failed-to-marshal", err)
+		return err
+	}
+
+	resp, err := h.httpClient.Post(h.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		logger.Error("failed-to-post", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err = fmt.Errorf("http sink responded with status %d", resp.StatusCode)
+		logger.Error("unexpected-response", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package route_sink_test
+
+import (
+	"net/http"
+
+	"github.com/cloudfoundry-incubator/route-emitter/route_sink"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HTTPSink", func() {
+	var (
+		sink   route_sink.RouteSink
+		server *ghttp.Server
+	)
+
+	messagesToEmit := routing_table.MessagesToEmit{
+		RegistrationMessages: []routing_table.RegistryMessage{
+			{URIs: []string{"foo.com"}, Host: "1.1.1.1", Port: 11},
+		},
+	}
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		logger := lagertest.NewTestLogger("test")
+		sink = route_sink.NewHTTPSink(server.URL()+"/routes", &http.Client{}, logger)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("when the server accepts the messages", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/routes"),
+					ghttp.VerifyContentType("application/json"),
+					ghttp.VerifyJSON(`{
+						"registrations": [{"host": "1.1.1.1", "port": 11, "uris": ["foo.com"]}],
+						"unregistrations": []
+					}`),
+					ghttp.RespondWith(http.StatusNoContent, nil),
+				),
+			)
+		})
+
+		It("posts the messages", func() {
+			_, err := sink.Emit(messagesToEmit)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(server.ReceivedRequests()).To(HaveLen(1))
+		})
+	})
+
+	Context("when there is nothing to emit", func() {
+		It("does not call the server", func() {
+			_, err := sink.Emit(routing_table.MessagesToEmit{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(server.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+
+	Context("when the server responds with an error", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(ghttp.RespondWith(http.StatusInternalServerError, nil))
+		})
+
+		It("returns an error", func() {
+			_, err := sink.Emit(messagesToEmit)
+			Expect(err).To(MatchError("http sink responded with status 500"))
+		})
+	})
+})
@@ -0,0 +1,126 @@
+package route_sink
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/runtime-schema/metric"
+	"github.com/pivotal-golang/clock"
+	"github.com/pivotal-golang/lager"
+)
+
+//go:generate counterfeiter -o fake_route_sink/fake_route_sink.go . RouteSink
+
+// RouteSink is a backend to which route registrations and unregistrations are
+// emitted, such as NATS. Emit returns the outcome for every message, and an
+// error if any of them could not be emitted.
+type RouteSink interface {
+	Emit(messagesToEmit routing_table.MessagesToEmit) (EmitResult, error)
+}
+
+// Backend is a named RouteSink. Failures of an optional backend are logged
+// and counted, but are not reported by the fan-out emitter, so that a new
+// backend can be tried out alongside an established one.
+type Backend struct {
+	Name     string
+	Sink     RouteSink
+	Optional bool
+}
+
+type backendMetrics struct {
+	emits        metric.Counter
+	emitFailures metric.Counter
+	emitDuration metric.Duration
+}
+
+type backend struct {
+	Backend
+	metrics backendMetrics
+}
+
+type fanOut struct {
+	backends []backend
+	clock    clock.Clock
+	logger   lager.Logger
+}
+
+// NewFanOut returns a RouteSink that emits every batch of messages to each of
+// the backends concurrently. A failing backend does not prevent the others
+// from receiving the messages. For every backend it sends the
+// <Name>SinkEmits and <Name>SinkEmitFailures counters and the
+// <Name>SinkEmitDuration metric.
+func NewFanOut(backends []Backend, clock clock.Clock, logger lager.Logger) RouteSink {
+	fanOut := &fanOut{
+		backends: make([]backend, 0, len(backends)),
+		clock:    clock,
+		logger:   logger.Session("route-sink"),
+	}
+
+	for _, b := range backends {
+		fanOut.backends = append(fanOut.backends, backend{
+			Backend: b,
+			metrics: backendMetrics{
+				emits:        metric.Counter(b.Name + "SinkEmits"),
+				emitFailures: metric.Counter(b.Name + "SinkEmitFailures"),
+				emitDuration: metric.Duration(b.Name + "SinkEmitDuration"),
+			},
+		})
+	}
+
+	return fanOut
+}
+
+// Emit returns the combined results of the backends that are not optional,
+// with each failure attributed to its backend.
+func (f *fanOut) Emit(messagesToEmit routing_table.MessagesToEmit) (EmitResult, error) {
+	results := make([]EmitResult, len(f.backends))
+	errs := make([]error, len(f.backends))
+
+	var wg sync.WaitGroup
+	wg.Add(len(f.backends))
+	for i := range f.backends {
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = f.emit(f.backends[i], messagesToEmit)
+		}(i)
+	}
+	wg.Wait()
+
+	result := EmitResult{}
+	var finalError error
+	for i, b := range f.backends {
+		if b.Optional {
+			continue
+		}
+
+		for j := range results[i].Failures {
+			results[i].Failures[j].Sink = b.Name
+		}
+		result.Merge(results[i])
+
+		if errs[i] != nil && finalError == nil {
+			finalError = fmt.Errorf("route sink %s: %s", b.Name, errs[i].Error())
+		}
+	}
+
+	return result, finalError
+}
+
+func (f *fanOut) emit(b backend, messagesToEmit routing_table.MessagesToEmit) (EmitResult, error) {
+	before := f.clock.Now()
+	result, err := b.Sink.Emit(messagesToEmit)
+	b.metrics.emitDuration.Send(f.clock.Now().Sub(before))
+	b.metrics.emits.Increment()
+
+	if err != nil {
+		b.metrics.emitFailures.Increment()
+		f.logger.Error("failed-to-emit", err, lager.Data{
+			"sink":     b.Name,
+			"optional": b.Optional,
+			"failed":   result.FailedCount(),
+		})
+	}
+
+	return result, err
+}
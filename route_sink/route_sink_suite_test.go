@@ -0,0 +1,13 @@
+package route_sink_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestRouteSink(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Route Sink Suite")
+}
@@ -0,0 +1,139 @@
+package route_sink_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/route_sink"
+	"github.com/cloudfoundry-incubator/route-emitter/route_sink/fake_route_sink"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	fake_metrics_sender "github.com/cloudfoundry/dropsonde/metric_sender/fake"
+	"github.com/cloudfoundry/dropsonde/metrics"
+	"github.com/pivotal-golang/clock/fakeclock"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FanOut", func() {
+	var (
+		natsSink         *fake_route_sink.FakeRouteSink
+		httpSink         *fake_route_sink.FakeRouteSink
+		httpOptional     bool
+		logger           *lagertest.TestLogger
+		fakeMetricSender *fake_metrics_sender.FakeMetricSender
+
+		emitter route_sink.RouteSink
+	)
+
+	messagesToEmit := routing_table.MessagesToEmit{
+		RegistrationMessages: []routing_table.RegistryMessage{
+			{URIs: []string{"foo.com"}, Host: "1.1.1.1", Port: 11},
+		},
+		UnregistrationMessages: []routing_table.RegistryMessage{
+			{URIs: []string{"bar.com"}, Host: "2.2.2.2", Port: 22},
+		},
+	}
+
+	BeforeEach(func() {
+		natsSink = new(fake_route_sink.FakeRouteSink)
+		natsSink.EmitReturns(route_sink.BatchResult(messagesToEmit, nil), nil)
+		httpSink = new(fake_route_sink.FakeRouteSink)
+		httpSink.EmitReturns(route_sink.BatchResult(messagesToEmit, nil), nil)
+		httpOptional = false
+		logger = lagertest.NewTestLogger("test")
+
+		fakeMetricSender = fake_metrics_sender.NewFakeMetricSender()
+		metrics.Initialize(fakeMetricSender, nil)
+	})
+
+	JustBeforeEach(func() {
+		emitter = route_sink.NewFanOut([]route_sink.Backend{
+			{Name: "NATS", Sink: natsSink},
+			{Name: "HTTP", Sink: httpSink, Optional: httpOptional},
+		}, fakeclock.NewFakeClock(time.Now()), logger)
+	})
+
+	It("emits the messages to every backend", func() {
+		_, err := emitter.Emit(messagesToEmit)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(natsSink.EmitCallCount()).To(Equal(1))
+		Expect(natsSink.EmitArgsForCall(0)).To(Equal(messagesToEmit))
+		Expect(httpSink.EmitCallCount()).To(Equal(1))
+		Expect(httpSink.EmitArgsForCall(0)).To(Equal(messagesToEmit))
+	})
+
+	It("combines the results of the backends", func() {
+		result, err := emitter.Emit(messagesToEmit)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(result.Attempted).To(Equal(map[string]int{"router.register": 2, "router.unregister": 2}))
+		Expect(result.SucceededCount()).To(Equal(4))
+	})
+
+	It("counts the emits of each backend", func() {
+		_, err := emitter.Emit(messagesToEmit)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(fakeMetricSender.GetCounter("NATSSinkEmits")).To(BeEquivalentTo(1))
+		Expect(fakeMetricSender.GetCounter("HTTPSinkEmits")).To(BeEquivalentTo(1))
+		Expect(fakeMetricSender.GetCounter("NATSSinkEmitFailures")).To(BeEquivalentTo(0))
+	})
+
+	Context("when a backend fails", func() {
+		BeforeEach(func() {
+			err := errors.New("boom")
+			httpSink.EmitReturns(route_sink.BatchResult(messagesToEmit, err), err)
+		})
+
+		It("still emits to the other backends", func() {
+			emitter.Emit(messagesToEmit)
+			Expect(natsSink.EmitCallCount()).To(Equal(1))
+		})
+
+		It("returns the error, naming the backend", func() {
+			_, err := emitter.Emit(messagesToEmit)
+			Expect(err).To(MatchError("route sink HTTP: boom"))
+		})
+
+		It("attributes the failed messages to the backend", func() {
+			result, _ := emitter.Emit(messagesToEmit)
+
+			Expect(result.FailedCount()).To(Equal(2))
+			Expect(result.PartialFailure()).To(BeTrue())
+			for _, failure := range result.Failures {
+				Expect(failure.Sink).To(Equal("HTTP"))
+			}
+		})
+
+		It("counts and logs the failure", func() {
+			emitter.Emit(messagesToEmit)
+
+			Expect(fakeMetricSender.GetCounter("HTTPSinkEmitFailures")).To(BeEquivalentTo(1))
+			Expect(fakeMetricSender.GetCounter("NATSSinkEmitFailures")).To(BeEquivalentTo(0))
+			Expect(logger.LogMessages()).To(ContainElement("test.route-sink.failed-to-emit"))
+			Expect(logger.Logs()[0].Data["sink"]).To(Equal("HTTP"))
+		})
+
+		Context("and the backend is optional", func() {
+			BeforeEach(func() {
+				httpOptional = true
+			})
+
+			It("does not report the failure", func() {
+				result, err := emitter.Emit(messagesToEmit)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.FailedCount()).To(Equal(0))
+			})
+
+			It("still counts and logs the failure", func() {
+				emitter.Emit(messagesToEmit)
+
+				Expect(fakeMetricSender.GetCounter("HTTPSinkEmitFailures")).To(BeEquivalentTo(1))
+				Expect(logger.LogMessages()).To(ContainElement("test.route-sink.failed-to-emit"))
+			})
+		})
+	})
+})
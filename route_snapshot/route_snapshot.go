@@ -0,0 +1,191 @@
+package route_snapshot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/route_sink"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/pivotal-golang/clock"
+	"github.com/pivotal-golang/lager"
+)
+
+const RoutesPath = "/v1/routes"
+
+// Snapshot is the full set of routes. Its Version changes exactly when the
+// routes do, and is also served as the ETag.
+type Snapshot struct {
+	Version string                          `json:"version"`
+	Routes  []routing_table.RegistryMessage `json:"routes"`
+}
+
+// Server serves snapshots of the routing table to proxies that pull their
+// routes rather than subscribe to NATS. It is also a route_sink.RouteSink:
+// the messages emitted to it wake up the clients that are long-polling for a
+// change.
+type Server struct {
+	table           routing_table.RoutingTable
+	longPollTimeout time.Duration
+	clock           clock.Clock
+	logger          lager.Logger
+
+	lock    sync.Mutex
+	changed chan struct{}
+}
+
+func New(table routing_table.RoutingTable, longPollTimeout time.Duration, clock clock.Clock, logger lager.Logger) *Server {
+	return &Server{
+		table:           table,
+		longPollTimeout: longPollTimeout,
+		clock:           clock,
+		logger:          logger.Session("route-snapshot"),
+		changed:         make(chan struct{}),
+	}
+}
+
+// Emit wakes up the long-polling clients, which respond if the routes have
+// changed. It never fails.
+func (s *Server) Emit(messagesToEmit routing_table.MessagesToEmit) (route_sink.EmitResult, error) {
+	if len(messagesToEmit.RegistrationMessages) > 0 || len(messagesToEmit.UnregistrationMessages) > 0 {
+		s.lock.Lock()
+		close(s.changed)
+		s.changed = make(chan struct{})
+		s.lock.Unlock()
+	}
+
+	return route_sink.BatchResult(messagesToEmit, nil), nil
+}
+
+// ServeHTTP responds with the current snapshot. If the If-None-Match header
+// names the current version, it waits for the routes to change, and responds
+// with 304 Not Modified if they do not change within the long-poll timeout.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	changed := s.changes()
+	snapshot, payload := s.snapshot()
+
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if ifNoneMatch == "" || !etagMatches(ifNoneMatch, snapshot.Version) {
+		writeSnapshot(w, snapshot, payload)
+		return
+	}
+
+	var closed <-chan bool
+	if notifier, ok := w.(http.CloseNotifier); ok {
+		closed = notifier.CloseNotify()
+	}
+
+	timer := s.clock.NewTimer(s.longPollTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-changed:
+			changed = s.changes()
+			snapshot, payload = s.snapshot()
+			if !etagMatches(ifNoneMatch, snapshot.Version) {
+				writeSnapshot(w, snapshot, payload)
+				return
+			}
+		case <-timer.C():
+			w.Header().Set("ETag", etag(snapshot.Version))
+			w.WriteHeader(http.StatusNotModified)
+			return
+		case <-closed:
+			return
+		}
+	}
+}
+
+func (s *Server) changes() <-chan struct{} {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.changed
+}
+
+// snapshot builds the snapshot of the routing table, in a canonical order so
+// that its version only depends on the routes.
+func (s *Server) snapshot() (Snapshot, []byte) {
+	messages := s.table.MessagesToEmit().RegistrationMessages
+
+	routes := make([]routing_table.RegistryMessage, 0, len(messages))
+	keys := make([]string, 0, len(messages))
+	for _, message := range messages {
+		uris := append([]string{}, message.URIs...)
+		sort.Strings(uris)
+		message.URIs = uris
+
+		key, err := json.Marshal(message)
+		if err != nil {
+			s.logger.Error("failed-to-marshal-route", err)
+		}
+
+		routes = append(routes, message)
+		keys = append(keys, string(key))
+	}
+	sort.Sort(byKey{routes: routes, keys: keys})
+
+	routesPayload, err := json.Marshal(routes)
+	if err != nil {
+		s.logger.Error("failed-to-marshal-routes", err)
+	}
+	sum := sha256.Sum256(routesPayload)
+
+	snapshot := Snapshot{
+		Version: hex.EncodeToString(sum[:16]),
+		Routes:  routes,
+	}
+
+	payload, err := json.Marshal(snapshot)
+	if err != nil {
+		s.logger.Error("failed-to-marshal-snapshot", err)
+	}
+
+	return snapshot, payload
+}
+
+func writeSnapshot(w http.ResponseWriter, snapshot Snapshot, payload []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(snapshot.Version))
+	w.WriteHeader(http.StatusOK)
+	w.Write(payload)
+}
+
+func etag(version string) string {
+	return `"` + version + `"`
+}
+
+// etagMatches reports whether the If-None-Match header names the version.
+// Weak validators match as well.
+func etagMatches(ifNoneMatch, version string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == etag(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// byKey sorts the routes by their serialized form.
+type byKey struct {
+	routes []routing_table.RegistryMessage
+	keys   []string
+}
+
+func (b byKey) Len() int { return len(b.routes) }
+func (b byKey) Swap(i, j int) {
+	b.routes[i], b.routes[j] = b.routes[j], b.routes[i]
+	b.keys[i], b.keys[j] = b.keys[j], b.keys[i]
+}
+func (b byKey) Less(i, j int) bool { return b.keys[i] < b.keys[j] }
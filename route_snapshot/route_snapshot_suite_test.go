@@ -0,0 +1,13 @@
+package route_snapshot_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestRouteSnapshot(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "RouteSnapshot Suite")
+}
@@ -0,0 +1,162 @@
+package route_snapshot_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/route_snapshot"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table/fake_routing_table"
+	"github.com/pivotal-golang/clock/fakeclock"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Server", func() {
+	var (
+		table  *fake_routing_table.FakeRoutingTable
+		clock  *fakeclock.FakeClock
+		server *route_snapshot.Server
+
+		message1 routing_table.RegistryMessage
+		message2 routing_table.RegistryMessage
+	)
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		request, err := http.NewRequest("GET", route_snapshot.RoutesPath, nil)
+		Expect(err).NotTo(HaveOccurred())
+		if ifNoneMatch != "" {
+			request.Header.Set("If-None-Match", ifNoneMatch)
+		}
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	decode := func(recorder *httptest.ResponseRecorder) route_snapshot.Snapshot {
+		var snapshot route_snapshot.Snapshot
+		err := json.Unmarshal(recorder.Body.Bytes(), &snapshot)
+		Expect(err).NotTo(HaveOccurred())
+		return snapshot
+	}
+
+	BeforeEach(func() {
+		table = &fake_routing_table.FakeRoutingTable{}
+		clock = fakeclock.NewFakeClock(time.Now())
+		server = route_snapshot.New(table, 30*time.Second, clock, lagertest.NewTestLogger("test"))
+
+		message1 = routing_table.RegistryMessage{Host: "1.1.1.1", Port: 11, URIs: []string{"foo.com", "bar.com"}, App: "app-1"}
+		message2 = routing_table.RegistryMessage{Host: "2.2.2.2", Port: 22, URIs: []string{"baz.com"}, App: "app-2"}
+
+		table.MessagesToEmitReturns(routing_table.MessagesToEmit{
+			RegistrationMessages: []routing_table.RegistryMessage{message2, message1},
+		})
+	})
+
+	It("serves the routes of the table with their version as the ETag", func() {
+		recorder := get("")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+
+		snapshot := decode(recorder)
+		Expect(snapshot.Routes).To(Equal([]routing_table.RegistryMessage{
+			{Host: "1.1.1.1", Port: 11, URIs: []string{"bar.com", "foo.com"}, App: "app-1"},
+			message2,
+		}))
+		Expect(snapshot.Version).NotTo(BeEmpty())
+		Expect(recorder.Header().Get("ETag")).To(Equal(`"` + snapshot.Version + `"`))
+	})
+
+	It("serves the same version for the same routes in any order", func() {
+		version := decode(get("")).Version
+
+		table.MessagesToEmitReturns(routing_table.MessagesToEmit{
+			RegistrationMessages: []routing_table.RegistryMessage{
+				{Host: "1.1.1.1", Port: 11, URIs: []string{"bar.com", "foo.com"}, App: "app-1"},
+				message2,
+			},
+		})
+		Expect(decode(get("")).Version).To(Equal(version))
+
+		table.MessagesToEmitReturns(routing_table.MessagesToEmit{
+			RegistrationMessages: []routing_table.RegistryMessage{message1},
+		})
+		Expect(decode(get("")).Version).NotTo(Equal(version))
+	})
+
+	It("serves the routes right away when If-None-Match names another version", func() {
+		recorder := get(`"some-other-version"`)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(decode(recorder).Routes).To(HaveLen(2))
+	})
+
+	It("only allows GET", func() {
+		request, err := http.NewRequest("POST", route_snapshot.RoutesPath, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, request)
+		Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+
+	Describe("long-polling", func() {
+		var (
+			etag      string
+			responses chan *httptest.ResponseRecorder
+		)
+
+		BeforeEach(func() {
+			etag = get("").Header().Get("ETag")
+			responses = make(chan *httptest.ResponseRecorder, 1)
+		})
+
+		JustBeforeEach(func() {
+			go func() {
+				responses <- get(etag)
+			}()
+			Eventually(clock.WatcherCount).Should(Equal(1))
+		})
+
+		It("responds when the routes change", func() {
+			Consistently(responses).ShouldNot(Receive())
+
+			table.MessagesToEmitReturns(routing_table.MessagesToEmit{
+				RegistrationMessages: []routing_table.RegistryMessage{message1},
+			})
+			_, err := server.Emit(routing_table.MessagesToEmit{
+				UnregistrationMessages: []routing_table.RegistryMessage{message2},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			var recorder *httptest.ResponseRecorder
+			Eventually(responses).Should(Receive(&recorder))
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("ETag")).NotTo(Equal(etag))
+			Expect(decode(recorder).Routes).To(HaveLen(1))
+		})
+
+		It("keeps waiting when messages are emitted without changing the routes", func() {
+			_, err := server.Emit(routing_table.MessagesToEmit{
+				RegistrationMessages: []routing_table.RegistryMessage{message1},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Consistently(responses).ShouldNot(Receive())
+		})
+
+		It("responds with 304 Not Modified when the routes do not change within the timeout", func() {
+			clock.Increment(30 * time.Second)
+
+			var recorder *httptest.ResponseRecorder
+			Eventually(responses).Should(Receive(&recorder))
+			Expect(recorder.Code).To(Equal(http.StatusNotModified))
+			Expect(recorder.Header().Get("ETag")).To(Equal(etag))
+			Expect(recorder.Body.Len()).To(BeZero())
+		})
+	})
+})
@@ -0,0 +1,55 @@
+// This file was generated by counterfeiter
+package fake_routing_api_emitter
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_api_emitter"
+)
+
+type FakeTokenFetcher struct {
+	FetchTokenStub        func(forceUpdate bool) (string, error)
+	fetchTokenMutex       sync.RWMutex
+	fetchTokenArgsForCall []struct {
+		forceUpdate bool
+	}
+	fetchTokenReturns struct {
+		result1 string
+		result2 error
+	}
+}
+
+func (fake *FakeTokenFetcher) FetchToken(forceUpdate bool) (string, error) {
+	fake.fetchTokenMutex.Lock()
+	fake.fetchTokenArgsForCall = append(fake.fetchTokenArgsForCall, struct {
+		forceUpdate bool
+	}{forceUpdate})
+	fake.fetchTokenMutex.Unlock()
+	if fake.FetchTokenStub != nil {
+		return fake.FetchTokenStub(forceUpdate)
+	} else {
+		return fake.fetchTokenReturns.result1, fake.fetchTokenReturns.result2
+	}
+}
+
+func (fake *FakeTokenFetcher) FetchTokenCallCount() int {
+	fake.fetchTokenMutex.RLock()
+	defer fake.fetchTokenMutex.RUnlock()
+	return len(fake.fetchTokenArgsForCall)
+}
+
+func (fake *FakeTokenFetcher) FetchTokenArgsForCall(i int) bool {
+	fake.fetchTokenMutex.RLock()
+	defer fake.fetchTokenMutex.RUnlock()
+	return fake.fetchTokenArgsForCall[i].forceUpdate
+}
+
+func (fake *FakeTokenFetcher) FetchTokenReturns(result1 string, result2 error) {
+	fake.FetchTokenStub = nil
+	fake.fetchTokenReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+var _ routing_api_emitter.TokenFetcher = new(FakeTokenFetcher)
@@ -0,0 +1,210 @@
+package routing_api_emitter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/route_sink"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/pivotal-golang/lager"
+)
+
+const RoutesPath = "/routing/v1/routes"
+
+// PruneIntervalsPerTTL is the number of prune intervals a route outlives its
+// last registration by. Routes are registered once per prune interval, so a
+// route survives missing a couple of registrations, as with the gorouter's
+// prune threshold.
+const PruneIntervalsPerTTL = 3
+
+// Route is the routing API representation of an HTTP route to an endpoint.
+type Route struct {
+	Route           string `json:"route"`
+	Port            uint32 `json:"port"`
+	IP              string `json:"ip"`
+	TTL             int    `json:"ttl"`
+	LogGuid         string `json:"log_guid,omitempty"`
+	RouteServiceUrl string `json:"route_service_url,omitempty"`
+}
+
+// TTLForPruneInterval returns the TTL, in seconds, of routes registered every
+// pruneInterval.
+func TTLForPruneInterval(pruneInterval time.Duration) int {
+	return int(math.Ceil((PruneIntervalsPerTTL * pruneInterval).Seconds()))
+}
+
+// RoutesFor returns a route for each of the URIs of the messages.
+func RoutesFor(messages []routing_table.RegistryMessage, ttl int) []Route {
+	routes := []Route{}
+	for _, message := range messages {
+		for _, uri := range message.URIs {
+			routes = append(routes, Route{
+				Route:           uri,
+				Port:            message.Port,
+				IP:              message.Host,
+				TTL:             ttl,
+				LogGuid:         message.App,
+				RouteServiceUrl: message.RouteServiceUrl,
+			})
+		}
+	}
+	return routes
+}
+
+type routingAPIEmitter struct {
+	routingAPIURL string
+	httpClient    *http.Client
+	tokenFetcher  TokenFetcher
+	ttl           int
+	batchSize     int
+	logger        lager.Logger
+}
+
+// New returns a RouteSink that registers routes with the routing API by
+// POSTing them to RoutesPath, and unregisters them by DELETEing them, in
+// batches of at most batchSize routes. Routes are registered with a TTL
+// derived from pruneInterval. Requests carry a bearer token from tokenFetcher,
+// unless it is nil; a token rejected by the routing API is refreshed once.
+func New(
+	routingAPIURL string,
+	httpClient *http.Client,
+	tokenFetcher TokenFetcher,
+	pruneInterval time.Duration,
+	batchSize int,
+	logger lager.Logger,
+) route_sink.RouteSink {
+	return &routingAPIEmitter{
+		routingAPIURL: routingAPIURL,
+		httpClient:    httpClient,
+		tokenFetcher:  tokenFetcher,
+		ttl:           TTLForPruneInterval(pruneInterval),
+		batchSize:     batchSize,
+		logger:        logger.Session("routing-api-emitter"),
+	}
+}
+
+func (r *routingAPIEmitter) Emit(messagesToEmit routing_table.MessagesToEmit) (route_sink.EmitResult, error) {
+	result := route_sink.EmitResult{}
+	var finalError error
+
+	err := r.emit("POST", route_sink.RegisterSubject, messagesToEmit.RegistrationMessages, r.ttl, &result)
+	if err != nil {
+		finalError = err
+	}
+
+	err = r.emit("DELETE", route_sink.UnregisterSubject, messagesToEmit.UnregistrationMessages, 0, &result)
+	if err != nil {
+		finalError = err
+	}
+
+	return result, finalError
+}
+
+// emit sends the routes of the messages in batches, and records the outcome
+// for each message in result. A message fails if any of its routes could not
+// be sent.
+func (r *routingAPIEmitter) emit(
+	method, subject string,
+	messages []routing_table.RegistryMessage,
+	ttl int,
+	result *route_sink.EmitResult,
+) error {
+	routes := []Route{}
+	owners := []int{}
+	for i, message := range messages {
+		for _, route := range RoutesFor([]routing_table.RegistryMessage{message}, ttl) {
+			routes = append(routes, route)
+			owners = append(owners, i)
+		}
+	}
+
+	var finalError error
+	failed := map[int]error{}
+	for start := 0; start < len(routes); {
+		end := len(routes)
+		if r.batchSize > 0 && start+r.batchSize < end {
+			end = start + r.batchSize
+		}
+
+		err := r.send(method, routes[start:end])
+		if err != nil {
+			finalError = err
+			for _, owner := range owners[start:end] {
+				failed[owner] = err
+			}
+		}
+
+		start = end
+	}
+
+	succeeded := 0
+	for i, message := range messages {
+		err, found := failed[i]
+		if !found {
+			succeeded++
+			continue
+		}
+
+		result.Fail(route_sink.FailedMessage{Subject: subject, Message: message, Err: err, Dropped: true})
+	}
+	result.Succeed(subject, succeeded)
+
+	return finalError
+}
+
+func (r *routingAPIEmitter) send(method string, routes []Route) error {
+	logger := r.logger.Session("send", lager.Data{"method": method, "num-routes": len(routes)})
+	logger.Debug("emit", lager.Data{"routes": routes})
+
+	payload, err := json.Marshal(routes)
+	if err != nil {
+		logger.Error("failed-to-marshal", err)
+		return err
+	}
+
+	status, err := r.do(method, payload, false)
+	if err == nil && status == http.StatusUnauthorized && r.tokenFetcher != nil {
+		logger.Info("refreshing-token")
+		status, err = r.do(method, payload, true)
+	}
+	if err != nil {
+		logger.Error("failed-to-send", err)
+		return err
+	}
+
+	if status < 200 || status >= 300 {
+		err = fmt.Errorf("routing api responded with status %d", status)
+		logger.Error("unexpected-response", err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *routingAPIEmitter) do(method string, payload []byte, forceTokenUpdate bool) (int, error) {
+	request, err := http.NewRequest(method, r.routingAPIURL+RoutesPath, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	if r.tokenFetcher != nil {
+		token, err := r.tokenFetcher.FetchToken(forceTokenUpdate)
+		if err != nil {
+			return 0, err
+		}
+		request.Header.Set("Authorization", "bearer "+token)
+	}
+
+	resp, err := r.httpClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,13 @@
+package routing_api_emitter_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestRoutingAPIEmitter(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Routing API Emitter Suite")
+}
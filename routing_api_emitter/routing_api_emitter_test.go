@@ -0,0 +1,230 @@
+package routing_api_emitter_test
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/route_sink"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_api_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_api_emitter/fake_routing_api_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RoutingAPIEmitter", func() {
+	var (
+		emitter          route_sink.RouteSink
+		routingAPIServer *ghttp.Server
+		tokenFetcher     *fake_routing_api_emitter.FakeTokenFetcher
+		batchSize        int
+	)
+
+	messagesToEmit := routing_table.MessagesToEmit{
+		RegistrationMessages: []routing_table.RegistryMessage{
+			{URIs: []string{"foo.com", "bar.com"}, Host: "1.1.1.1", Port: 11, App: "log-guid"},
+			{URIs: []string{"baz.com"}, Host: "2.2.2.2", Port: 22, RouteServiceUrl: "https://rs.example.com"},
+		},
+		UnregistrationMessages: []routing_table.RegistryMessage{
+			{URIs: []string{"wibble.com"}, Host: "3.3.3.3", Port: 33},
+		},
+	}
+
+	BeforeEach(func() {
+		routingAPIServer = ghttp.NewServer()
+		tokenFetcher = new(fake_routing_api_emitter.FakeTokenFetcher)
+		tokenFetcher.FetchTokenReturns("some-token", nil)
+		batchSize = 0
+	})
+
+	JustBeforeEach(func() {
+		logger := lagertest.NewTestLogger("test")
+		emitter = routing_api_emitter.New(routingAPIServer.URL(), &http.Client{}, tokenFetcher, 20*time.Second, batchSize, logger)
+	})
+
+	AfterEach(func() {
+		routingAPIServer.Close()
+	})
+
+	Describe("TTLForPruneInterval", func() {
+		It("outlives three prune intervals, rounded up to a second", func() {
+			Expect(routing_api_emitter.TTLForPruneInterval(20 * time.Second)).To(Equal(60))
+			Expect(routing_api_emitter.TTLForPruneInterval(1500 * time.Millisecond)).To(Equal(5))
+		})
+	})
+
+	Context("when the routing api accepts the routes", func() {
+		BeforeEach(func() {
+			routingAPIServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", routing_api_emitter.RoutesPath),
+					ghttp.VerifyContentType("application/json"),
+					ghttp.VerifyHeaderKV("Authorization", "bearer some-token"),
+					ghttp.VerifyJSON(`[
+						{"route": "foo.com", "port": 11, "ip": "1.1.1.1", "ttl": 60, "log_guid": "log-guid"},
+						{"route": "bar.com", "port": 11, "ip": "1.1.1.1", "ttl": 60, "log_guid": "log-guid"},
+						{"route": "baz.com", "port": 22, "ip": "2.2.2.2", "ttl": 60, "route_service_url": "https://rs.example.com"}
+					]`),
+					ghttp.RespondWith(http.StatusCreated, nil),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("DELETE", routing_api_emitter.RoutesPath),
+					ghttp.VerifyHeaderKV("Authorization", "bearer some-token"),
+					ghttp.VerifyJSON(`[
+						{"route": "wibble.com", "port": 33, "ip": "3.3.3.3", "ttl": 0}
+					]`),
+					ghttp.RespondWith(http.StatusNoContent, nil),
+				),
+			)
+		})
+
+		It("registers and unregisters the routes", func() {
+			_, err := emitter.Emit(messagesToEmit)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(routingAPIServer.ReceivedRequests()).To(HaveLen(2))
+		})
+
+		It("uses the cached token", func() {
+			emitter.Emit(messagesToEmit)
+			Expect(tokenFetcher.FetchTokenCallCount()).To(Equal(2))
+			Expect(tokenFetcher.FetchTokenArgsForCall(0)).To(BeFalse())
+			Expect(tokenFetcher.FetchTokenArgsForCall(1)).To(BeFalse())
+		})
+	})
+
+	Context("when the batch size is smaller than the number of routes", func() {
+		BeforeEach(func() {
+			batchSize = 2
+			routingAPIServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", routing_api_emitter.RoutesPath),
+					ghttp.VerifyJSON(`[
+						{"route": "foo.com", "port": 11, "ip": "1.1.1.1", "ttl": 60, "log_guid": "log-guid"},
+						{"route": "bar.com", "port": 11, "ip": "1.1.1.1", "ttl": 60, "log_guid": "log-guid"}
+					]`),
+					ghttp.RespondWith(http.StatusCreated, nil),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", routing_api_emitter.RoutesPath),
+					ghttp.VerifyJSON(`[
+						{"route": "baz.com", "port": 22, "ip": "2.2.2.2", "ttl": 60, "route_service_url": "https://rs.example.com"}
+					]`),
+					ghttp.RespondWith(http.StatusCreated, nil),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("DELETE", routing_api_emitter.RoutesPath),
+					ghttp.RespondWith(http.StatusNoContent, nil),
+				),
+			)
+		})
+
+		It("sends the routes in batches", func() {
+			_, err := emitter.Emit(messagesToEmit)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(routingAPIServer.ReceivedRequests()).To(HaveLen(3))
+		})
+	})
+
+	Context("when there is nothing to emit", func() {
+		It("does not call the routing api", func() {
+			_, err := emitter.Emit(routing_table.MessagesToEmit{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(routingAPIServer.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+
+	Context("when the routing api rejects the token", func() {
+		BeforeEach(func() {
+			tokenFetcher.FetchTokenStub = func(forceUpdate bool) (string, error) {
+				if forceUpdate {
+					return "new-token", nil
+				}
+				return "old-token", nil
+			}
+
+			routingAPIServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyHeaderKV("Authorization", "bearer old-token"),
+					ghttp.RespondWith(http.StatusUnauthorized, nil),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", routing_api_emitter.RoutesPath),
+					ghttp.VerifyHeaderKV("Authorization", "bearer new-token"),
+					ghttp.RespondWith(http.StatusCreated, nil),
+				),
+			)
+		})
+
+		It("refreshes the token and retries", func() {
+			_, err := emitter.Emit(routing_table.MessagesToEmit{
+				RegistrationMessages: messagesToEmit.RegistrationMessages,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(routingAPIServer.ReceivedRequests()).To(HaveLen(2))
+			Expect(tokenFetcher.FetchTokenArgsForCall(1)).To(BeTrue())
+		})
+	})
+
+	Context("when a token cannot be fetched", func() {
+		BeforeEach(func() {
+			tokenFetcher.FetchTokenReturns("", errors.New("no token"))
+		})
+
+		It("returns an error without calling the routing api", func() {
+			_, err := emitter.Emit(messagesToEmit)
+			Expect(err).To(MatchError("no token"))
+			Expect(routingAPIServer.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+
+	Context("when the routing api responds with an error", func() {
+		BeforeEach(func() {
+			routingAPIServer.AppendHandlers(
+				ghttp.RespondWith(http.StatusInternalServerError, nil),
+				ghttp.RespondWith(http.StatusNoContent, nil),
+			)
+		})
+
+		It("still attempts the unregistrations and returns an error", func() {
+			_, err := emitter.Emit(messagesToEmit)
+			Expect(err).To(MatchError("routing api responded with status 500"))
+			Expect(routingAPIServer.ReceivedRequests()).To(HaveLen(2))
+		})
+
+		It("reports the messages whose routes failed", func() {
+			result, _ := emitter.Emit(messagesToEmit)
+
+			Expect(result.Failed).To(Equal(map[string]int{"router.register": 2}))
+			Expect(result.Succeeded).To(Equal(map[string]int{"router.unregister": 1}))
+			Expect(result.Failures[0].Message).To(Equal(messagesToEmit.RegistrationMessages[0]))
+			Expect(result.Failures[0].Dropped).To(BeTrue())
+		})
+	})
+
+	Context("when there is no token fetcher", func() {
+		JustBeforeEach(func() {
+			emitter = routing_api_emitter.New(routingAPIServer.URL(), &http.Client{}, nil, 20*time.Second, 0, lagertest.NewTestLogger("test"))
+
+			routingAPIServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", routing_api_emitter.RoutesPath),
+					func(w http.ResponseWriter, req *http.Request) {
+						Expect(req.Header.Get("Authorization")).To(BeEmpty())
+					},
+					ghttp.RespondWith(http.StatusCreated, nil),
+				),
+			)
+		})
+
+		It("sends unauthenticated requests", func() {
+			_, err := emitter.Emit(routing_table.MessagesToEmit{
+				RegistrationMessages: messagesToEmit.RegistrationMessages,
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,123 @@
+package routing_api_emitter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+	"github.com/pivotal-golang/lager"
+)
+
+var ErrEmptyToken = errors.New("token endpoint returned an empty access token")
+
+// tokenExpiryMargin is how long before its expiry a token is refreshed, so
+// that it does not expire while a request is in flight.
+const tokenExpiryMargin = 30 * time.Second
+
+//go:generate counterfeiter -o fake_routing_api_emitter/fake_token_fetcher.go . TokenFetcher
+
+// TokenFetcher provides the bearer token used to authenticate with the
+// routing API.
+type TokenFetcher interface {
+	// FetchToken returns a cached token unless it is about to expire or
+	// forceUpdate is set, in which case a new token is requested.
+	FetchToken(forceUpdate bool) (string, error)
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+type tokenFetcher struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	httpClient   *http.Client
+	clock        clock.Clock
+	logger       lager.Logger
+
+	lock      sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// NewTokenFetcher returns a TokenFetcher that obtains tokens from tokenURL
+// with the OAuth client credentials grant.
+func NewTokenFetcher(
+	tokenURL, clientID, clientSecret string,
+	httpClient *http.Client,
+	clock clock.Clock,
+	logger lager.Logger,
+) TokenFetcher {
+	return &tokenFetcher{
+		tokenURL:     tokenURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		httpClient:   httpClient,
+		clock:        clock,
+		logger:       logger.Session("token-fetcher"),
+	}
+}
+
+func (t *tokenFetcher) FetchToken(forceUpdate bool) (string, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if !forceUpdate && t.token != "" && t.clock.Now().Before(t.expiresAt) {
+		return t.token, nil
+	}
+
+	logger := t.logger.Session("fetch")
+	logger.Debug("starting")
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	request, err := http.NewRequest("POST", t.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		logger.Error("failed-to-create-request", err)
+		return "", err
+	}
+	request.SetBasicAuth(t.clientID, t.clientSecret)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+
+	resp, err := t.httpClient.Do(request)
+	if err != nil {
+		logger.Error("failed-to-request-token", err)
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("token endpoint responded with status %d", resp.StatusCode)
+		logger.Error("unexpected-response", err)
+		return "", err
+	}
+
+	var token tokenResponse
+	err = json.NewDecoder(resp.Body).Decode(&token)
+	if err != nil {
+		logger.Error("failed-to-decode-token", err)
+		return "", err
+	}
+
+	if token.AccessToken == "" {
+		err = ErrEmptyToken
+		logger.Error("invalid-token", err)
+		return "", err
+	}
+
+	t.token = token.AccessToken
+	t.expiresAt = t.clock.Now().Add(time.Duration(token.ExpiresIn)*time.Second - tokenExpiryMargin)
+
+	logger.Debug("fetched", lager.Data{"expires-in": token.ExpiresIn})
+
+	return t.token, nil
+}
@@ -0,0 +1,108 @@
+package routing_api_emitter_test
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_api_emitter"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-golang/clock/fakeclock"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TokenFetcher", func() {
+	var (
+		tokenServer  *ghttp.Server
+		clock        *fakeclock.FakeClock
+		tokenFetcher routing_api_emitter.TokenFetcher
+	)
+
+	tokenHandler := func(token string) http.HandlerFunc {
+		return ghttp.CombineHandlers(
+			ghttp.VerifyRequest("POST", "/oauth/token"),
+			ghttp.VerifyBasicAuth("client-id", "client-secret"),
+			ghttp.VerifyContentType("application/x-www-form-urlencoded"),
+			ghttp.VerifyForm(url.Values{"grant_type": {"client_credentials"}}),
+			ghttp.RespondWith(http.StatusOK, `{"access_token": "`+token+`", "token_type": "bearer", "expires_in": 600}`),
+		)
+	}
+
+	BeforeEach(func() {
+		tokenServer = ghttp.NewServer()
+		clock = fakeclock.NewFakeClock(time.Now())
+		tokenFetcher = routing_api_emitter.NewTokenFetcher(
+			tokenServer.URL()+"/oauth/token",
+			"client-id",
+			"client-secret",
+			&http.Client{},
+			clock,
+			lagertest.NewTestLogger("test"),
+		)
+	})
+
+	AfterEach(func() {
+		tokenServer.Close()
+	})
+
+	Context("when the token endpoint grants a token", func() {
+		BeforeEach(func() {
+			tokenServer.AppendHandlers(tokenHandler("token-1"), tokenHandler("token-2"))
+		})
+
+		It("returns the token", func() {
+			token, err := tokenFetcher.FetchToken(false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token).To(Equal("token-1"))
+		})
+
+		It("caches the token until shortly before it expires", func() {
+			tokenFetcher.FetchToken(false)
+
+			clock.Increment(560 * time.Second)
+			token, err := tokenFetcher.FetchToken(false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token).To(Equal("token-1"))
+			Expect(tokenServer.ReceivedRequests()).To(HaveLen(1))
+
+			clock.Increment(20 * time.Second)
+			token, err = tokenFetcher.FetchToken(false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token).To(Equal("token-2"))
+			Expect(tokenServer.ReceivedRequests()).To(HaveLen(2))
+		})
+
+		It("fetches a new token when forced to", func() {
+			tokenFetcher.FetchToken(false)
+
+			token, err := tokenFetcher.FetchToken(true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token).To(Equal("token-2"))
+		})
+	})
+
+	Context("when the token endpoint responds with an error", func() {
+		BeforeEach(func() {
+			tokenServer.AppendHandlers(ghttp.RespondWith(http.StatusUnauthorized, nil))
+		})
+
+		It("returns an error", func() {
+			_, err := tokenFetcher.FetchToken(false)
+			Expect(err).To(MatchError("token endpoint responded with status 401"))
+		})
+	})
+
+	Context("when the token endpoint returns an empty token", func() {
+		BeforeEach(func() {
+			tokenServer.AppendHandlers(ghttp.RespondWith(http.StatusOK, `{"access_token": "", "expires_in": 600}`))
+		})
+
+		It("returns an error", func() {
+			_, err := tokenFetcher.FetchToken(false)
+			Expect(err).To(Equal(routing_api_emitter.ErrEmptyToken))
+		})
+	})
+})
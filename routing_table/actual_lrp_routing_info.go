@@ -5,6 +5,9 @@ import "github.com/cloudfoundry-incubator/bbs/models"
 type ActualLRPRoutingInfo struct {
 	ActualLRP  *models.ActualLRP
 	Evacuating bool
+
+	// AvailabilityZone of the cell running the LRP, if known.
+	AvailabilityZone string
 }
 
 func NewActualLRPRoutingInfo(actualLRPGroup *models.ActualLRPGroup) *ActualLRPRoutingInfo {
@@ -5,9 +5,11 @@ import (
 
 	"github.com/cloudfoundry-incubator/bbs/models"
 	"github.com/cloudfoundry-incubator/routing-info/cfroutes"
+	"github.com/cloudfoundry-incubator/routing-info/tcp_routes"
 )
 
 type RoutesByRoutingKey map[RoutingKey]Routes
+type TCPRoutesByRoutingKey map[RoutingKey]TCPRoutes
 type EndpointsByRoutingKey map[RoutingKey][]Endpoint
 
 func RoutesByRoutingKeyFromSchedulingInfos(schedulingInfos []*models.DesiredLRPSchedulingInfo) RoutesByRoutingKey {
@@ -29,6 +31,29 @@ func RoutesByRoutingKeyFromSchedulingInfos(schedulingInfos []*models.DesiredLRPS
 	return routesByRoutingKey
 }
 
+func TCPRoutesByRoutingKeyFromSchedulingInfos(schedulingInfos []*models.DesiredLRPSchedulingInfo) TCPRoutesByRoutingKey {
+	routesByRoutingKey := TCPRoutesByRoutingKey{}
+	for _, desired := range schedulingInfos {
+		routes, err := tcp_routes.TCPRoutesFromRoutingInfo(desired.Routes)
+		if err == nil && len(routes) > 0 {
+			modificationTag := desired.ModificationTag
+			for _, tcpRoute := range routes {
+				key := RoutingKey{ProcessGuid: desired.ProcessGuid, ContainerPort: tcpRoute.ContainerPort}
+				entry := routesByRoutingKey[key]
+				entry.ExternalEndpoints = append(entry.ExternalEndpoints, ExternalEndpointInfo{
+					RouterGroupGuid: tcpRoute.RouterGroupGuid,
+					Port:            tcpRoute.ExternalPort,
+				})
+				entry.LogGuid = desired.LogGuid
+				entry.ModificationTag = &modificationTag
+				routesByRoutingKey[key] = entry
+			}
+		}
+	}
+
+	return routesByRoutingKey
+}
+
 func EndpointsByRoutingKeyFromActuals(actuals []*ActualLRPRoutingInfo) EndpointsByRoutingKey {
 	endpointsByRoutingKey := EndpointsByRoutingKey{}
 	for _, actual := range actuals {
@@ -57,11 +82,18 @@ func EndpointsFromActual(actualLRPInfo *ActualLRPRoutingInfo) (map[uint32]Endpoi
 	for _, portMapping := range actual.Ports {
 		if portMapping != nil {
 			endpoint := Endpoint{
-				InstanceGuid:  actual.InstanceGuid,
-				Host:          actual.Address,
-				Port:          portMapping.HostPort,
-				ContainerPort: portMapping.ContainerPort,
-				Evacuating:    actualLRPInfo.Evacuating,
+				InstanceGuid:     actual.InstanceGuid,
+				Host:             actual.Address,
+				Port:             portMapping.HostPort,
+				ContainerPort:    portMapping.ContainerPort,
+				Evacuating:       actualLRPInfo.Evacuating,
+				ProcessGuid:      actual.ProcessGuid,
+				Index:            actual.Index,
+				Domain:           actual.Domain,
+				CellId:           actual.CellId,
+				AvailabilityZone: actualLRPInfo.AvailabilityZone,
+				ModificationTag:  modificationTagFromActual(actual),
+				Since:            actual.Since,
 			}
 			endpoints[portMapping.ContainerPort] = endpoint
 		}
@@ -70,6 +102,18 @@ func EndpointsFromActual(actualLRPInfo *ActualLRPRoutingInfo) (map[uint32]Endpoi
 	return endpoints, nil
 }
 
+// modificationTagFromActual returns nil when the actual LRP has no
+// modification tag, so that endpoints without one compare as before. With a
+// tag, RemoveEndpoint ignores the removal of an endpoint that the table
+// already holds in a newer version.
+func modificationTagFromActual(actual *models.ActualLRP) *models.ModificationTag {
+	if actual.ModificationTag.Epoch == "" {
+		return nil
+	}
+	tag := actual.ModificationTag
+	return &tag
+}
+
 func RoutingKeysFromActual(actual *models.ActualLRP) []RoutingKey {
 	keys := []RoutingKey{}
 	for _, portMapping := range actual.Ports {
@@ -92,3 +136,20 @@ func RoutingKeysFromSchedulingInfo(schedulingInfo *models.DesiredLRPSchedulingIn
 	}
 	return keys
 }
+
+func TCPRoutingKeysFromSchedulingInfo(schedulingInfo *models.DesiredLRPSchedulingInfo) []RoutingKey {
+	keys := []RoutingKey{}
+
+	routes, err := tcp_routes.TCPRoutesFromRoutingInfo(schedulingInfo.Routes)
+	if err == nil && len(routes) > 0 {
+		seen := map[uint32]struct{}{}
+		for _, tcpRoute := range routes {
+			if _, ok := seen[tcpRoute.ContainerPort]; ok {
+				continue
+			}
+			seen[tcpRoute.ContainerPort] = struct{}{}
+			keys = append(keys, RoutingKey{ProcessGuid: schedulingInfo.ProcessGuid, ContainerPort: tcpRoute.ContainerPort})
+		}
+	}
+	return keys
+}
@@ -3,6 +3,7 @@ package routing_table_test
 import (
 	"github.com/cloudfoundry-incubator/bbs/models"
 	"github.com/cloudfoundry-incubator/routing-info/cfroutes"
+	"github.com/cloudfoundry-incubator/routing-info/tcp_routes"
 	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
 
 	. "github.com/onsi/ginkgo"
@@ -47,6 +48,54 @@ var _ = Describe("ByRoutingKey", func() {
 		})
 	})
 
+	Describe("TCPRoutesByRoutingKeyFromSchedulingInfos", func() {
+		It("should build a map of tcp routes, grouping external ports by container port", func() {
+			abcRoutes := tcp_routes.TCPRoutes{
+				{RouterGroupGuid: "router-group-guid", ExternalPort: 61000, ContainerPort: 5222},
+				{RouterGroupGuid: "router-group-guid", ExternalPort: 61001, ContainerPort: 5222},
+				{RouterGroupGuid: "router-group-guid", ExternalPort: 61002, ContainerPort: 5223},
+			}
+			defRoutes := tcp_routes.TCPRoutes{
+				{RouterGroupGuid: "other-router-group-guid", ExternalPort: 62000, ContainerPort: 5222},
+			}
+
+			routes := routing_table.TCPRoutesByRoutingKeyFromSchedulingInfos([]*models.DesiredLRPSchedulingInfo{
+				{DesiredLRPKey: models.NewDesiredLRPKey("abc", "tests", "abc-guid"), Routes: abcRoutes.RoutingInfo(), ModificationTag: models.ModificationTag{Epoch: "abc", Index: 1}},
+				{DesiredLRPKey: models.NewDesiredLRPKey("def", "tests", "def-guid"), Routes: defRoutes.RoutingInfo(), ModificationTag: models.ModificationTag{Epoch: "def", Index: 2}},
+			})
+
+			Expect(routes).To(HaveLen(3))
+			Expect(routes[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 5222}].ExternalEndpoints).To(ConsistOf(
+				routing_table.ExternalEndpointInfo{RouterGroupGuid: "router-group-guid", Port: 61000},
+				routing_table.ExternalEndpointInfo{RouterGroupGuid: "router-group-guid", Port: 61001},
+			))
+			Expect(routes[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 5222}].LogGuid).To(Equal("abc-guid"))
+			Expect(routes[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 5222}].ModificationTag).To(Equal(&models.ModificationTag{Epoch: "abc", Index: 1}))
+
+			Expect(routes[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 5223}].ExternalEndpoints).To(ConsistOf(
+				routing_table.ExternalEndpointInfo{RouterGroupGuid: "router-group-guid", Port: 61002},
+			))
+
+			Expect(routes[routing_table.RoutingKey{ProcessGuid: "def", ContainerPort: 5222}].ExternalEndpoints).To(ConsistOf(
+				routing_table.ExternalEndpointInfo{RouterGroupGuid: "other-router-group-guid", Port: 62000},
+			))
+			Expect(routes[routing_table.RoutingKey{ProcessGuid: "def", ContainerPort: 5222}].LogGuid).To(Equal("def-guid"))
+			Expect(routes[routing_table.RoutingKey{ProcessGuid: "def", ContainerPort: 5222}].ModificationTag).To(Equal(&models.ModificationTag{Epoch: "def", Index: 2}))
+		})
+
+		Context("when the desired lrp only has http routes", func() {
+			It("should not be included in the results", func() {
+				routes := routing_table.TCPRoutesByRoutingKeyFromSchedulingInfos([]*models.DesiredLRPSchedulingInfo{
+					{
+						DesiredLRPKey: models.NewDesiredLRPKey("abc", "tests", "abc-guid"),
+						Routes:        cfroutes.CFRoutes{{Hostnames: []string{"foo.com"}, Port: 8080}}.RoutingInfo(),
+					},
+				})
+				Expect(routes).To(HaveLen(0))
+			})
+		})
+	})
+
 	Describe("EndpointsByRoutingKeyFromActuals", func() {
 		It("should build a map of endpoints, ignoring those without ports", func() {
 			endpoints := routing_table.EndpointsByRoutingKeyFromActuals([]*routing_table.ActualLRPRoutingInfo{
@@ -78,15 +127,15 @@ var _ = Describe("ByRoutingKey", func() {
 
 			Expect(endpoints).To(HaveLen(3))
 			Expect(endpoints[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 44}]).To(HaveLen(2))
-			Expect(endpoints[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 44}]).To(ContainElement(routing_table.Endpoint{Host: "1.1.1.1", Port: 11, ContainerPort: 44}))
-			Expect(endpoints[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 44}]).To(ContainElement(routing_table.Endpoint{Host: "2.2.2.2", Port: 22, ContainerPort: 44}))
+			Expect(endpoints[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 44}]).To(ContainElement(routing_table.Endpoint{Host: "1.1.1.1", Port: 11, ContainerPort: 44, ProcessGuid: "abc", Domain: "domain"}))
+			Expect(endpoints[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 44}]).To(ContainElement(routing_table.Endpoint{Host: "2.2.2.2", Port: 22, ContainerPort: 44, ProcessGuid: "abc", Index: 1, Domain: "domain"}))
 
 			Expect(endpoints[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 99}]).To(HaveLen(2))
-			Expect(endpoints[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 99}]).To(ContainElement(routing_table.Endpoint{Host: "1.1.1.1", Port: 66, ContainerPort: 99}))
-			Expect(endpoints[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 99}]).To(ContainElement(routing_table.Endpoint{Host: "2.2.2.2", Port: 88, ContainerPort: 99}))
+			Expect(endpoints[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 99}]).To(ContainElement(routing_table.Endpoint{Host: "1.1.1.1", Port: 66, ContainerPort: 99, ProcessGuid: "abc", Domain: "domain"}))
+			Expect(endpoints[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 99}]).To(ContainElement(routing_table.Endpoint{Host: "2.2.2.2", Port: 88, ContainerPort: 99, ProcessGuid: "abc", Index: 1, Domain: "domain"}))
 
 			Expect(endpoints[routing_table.RoutingKey{ProcessGuid: "def", ContainerPort: 55}]).To(HaveLen(1))
-			Expect(endpoints[routing_table.RoutingKey{ProcessGuid: "def", ContainerPort: 55}]).To(ContainElement(routing_table.Endpoint{Host: "3.3.3.3", Port: 33, ContainerPort: 55}))
+			Expect(endpoints[routing_table.RoutingKey{ProcessGuid: "def", ContainerPort: 55}]).To(ContainElement(routing_table.Endpoint{Host: "3.3.3.3", Port: 33, ContainerPort: 55, ProcessGuid: "def", Domain: "domain"}))
 		})
 	})
 
@@ -103,10 +152,42 @@ var _ = Describe("ByRoutingKey", func() {
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(endpoints).To(ConsistOf([]routing_table.Endpoint{
-				routing_table.Endpoint{Host: "1.1.1.1", Port: 11, InstanceGuid: "instance-guid", ContainerPort: 44, Evacuating: true},
-				routing_table.Endpoint{Host: "1.1.1.1", Port: 66, InstanceGuid: "instance-guid", ContainerPort: 99, Evacuating: true},
+				routing_table.Endpoint{Host: "1.1.1.1", Port: 11, InstanceGuid: "instance-guid", ContainerPort: 44, Evacuating: true, ProcessGuid: "process-guid", Domain: "domain", CellId: "cell-id"},
+				routing_table.Endpoint{Host: "1.1.1.1", Port: 66, InstanceGuid: "instance-guid", ContainerPort: 99, Evacuating: true, ProcessGuid: "process-guid", Domain: "domain", CellId: "cell-id"},
 			}))
 		})
+
+		It("carries the instance index and availability zone", func() {
+			endpoints, err := routing_table.EndpointsFromActual(&routing_table.ActualLRPRoutingInfo{
+				ActualLRP: &models.ActualLRP{
+					ActualLRPKey:         models.NewActualLRPKey("process-guid", 3, "domain"),
+					ActualLRPInstanceKey: models.NewActualLRPInstanceKey("instance-guid", "cell-id"),
+					ActualLRPNetInfo:     models.NewActualLRPNetInfo("1.1.1.1", models.NewPortMapping(11, 44)),
+				},
+				AvailabilityZone: "z1",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(endpoints).To(HaveLen(1))
+			Expect(endpoints[44].Index).To(BeEquivalentTo(3))
+			Expect(endpoints[44].AvailabilityZone).To(Equal("z1"))
+		})
+
+		It("carries the modification tag and update time", func() {
+			endpoints, err := routing_table.EndpointsFromActual(&routing_table.ActualLRPRoutingInfo{
+				ActualLRP: &models.ActualLRP{
+					ActualLRPKey:         models.NewActualLRPKey("process-guid", 3, "domain"),
+					ActualLRPInstanceKey: models.NewActualLRPInstanceKey("instance-guid", "cell-id"),
+					ActualLRPNetInfo:     models.NewActualLRPNetInfo("1.1.1.1", models.NewPortMapping(11, 44)),
+					ModificationTag:      models.ModificationTag{Epoch: "abc", Index: 2},
+					Since:                1234,
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(endpoints[44].ModificationTag).To(Equal(&models.ModificationTag{Epoch: "abc", Index: 2}))
+			Expect(endpoints[44].Since).To(BeEquivalentTo(1234))
+		})
 	})
 
 	Describe("RoutingKeysFromActual", func() {
@@ -166,4 +247,25 @@ var _ = Describe("ByRoutingKey", func() {
 			})
 		})
 	})
+
+	Describe("TCPRoutingKeysFromSchedulingInfo", func() {
+		It("creates one key per container port", func() {
+			routes := tcp_routes.TCPRoutes{
+				{RouterGroupGuid: "router-group-guid", ExternalPort: 61000, ContainerPort: 5222},
+				{RouterGroupGuid: "router-group-guid", ExternalPort: 61001, ContainerPort: 5222},
+				{RouterGroupGuid: "router-group-guid", ExternalPort: 61002, ContainerPort: 5223},
+			}
+
+			schedulingInfo := &models.DesiredLRPSchedulingInfo{
+				DesiredLRPKey: models.NewDesiredLRPKey("process-guid", "tests", "abc-guid"),
+				Routes:        routes.RoutingInfo(),
+			}
+
+			keys := routing_table.TCPRoutingKeysFromSchedulingInfo(schedulingInfo)
+
+			Expect(keys).To(HaveLen(2))
+			Expect(keys).To(ContainElement(routing_table.RoutingKey{ProcessGuid: "process-guid", ContainerPort: 5222}))
+			Expect(keys).To(ContainElement(routing_table.RoutingKey{ProcessGuid: "process-guid", ContainerPort: 5223}))
+		})
+	})
 })
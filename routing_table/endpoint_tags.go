@@ -0,0 +1,89 @@
+package routing_table
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// EndpointTag names a piece of endpoint metadata that can be included in the
+// tags of a registry message.
+type EndpointTag string
+
+const (
+	InstanceIndexTag    EndpointTag = "instance_index"
+	CellIdTag           EndpointTag = "cell_id"
+	DomainTag           EndpointTag = "domain"
+	ProcessGuidTag      EndpointTag = "process_guid"
+	EvacuatingTag       EndpointTag = "evacuating"
+	AvailabilityZoneTag EndpointTag = "availability_zone"
+)
+
+var AllEndpointTags = []EndpointTag{
+	InstanceIndexTag,
+	CellIdTag,
+	DomainTag,
+	ProcessGuidTag,
+	EvacuatingTag,
+	AvailabilityZoneTag,
+}
+
+// ParseEndpointTags parses a comma-separated list of endpoint tag names.
+func ParseEndpointTags(list string) ([]EndpointTag, error) {
+	tags := []EndpointTag{}
+
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		tag := EndpointTag(name)
+		if !tag.valid() {
+			return nil, fmt.Errorf("unknown endpoint tag: %s", name)
+		}
+
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
+func (tag EndpointTag) valid() bool {
+	for _, known := range AllEndpointTags {
+		if tag == known {
+			return true
+		}
+	}
+	return false
+}
+
+// endpointTagsFor returns the requested tags for the endpoint. The
+// availability zone is omitted when it is not known.
+func endpointTagsFor(endpoint Endpoint, tags []EndpointTag) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	values := map[string]string{}
+	for _, tag := range tags {
+		switch tag {
+		case InstanceIndexTag:
+			values[string(tag)] = strconv.Itoa(int(endpoint.Index))
+		case CellIdTag:
+			values[string(tag)] = endpoint.CellId
+		case DomainTag:
+			values[string(tag)] = endpoint.Domain
+		case ProcessGuidTag:
+			values[string(tag)] = endpoint.ProcessGuid
+		case EvacuatingTag:
+			values[string(tag)] = strconv.FormatBool(endpoint.Evacuating)
+		case AvailabilityZoneTag:
+			if endpoint.AvailabilityZone != "" {
+				values[string(tag)] = endpoint.AvailabilityZone
+			}
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,33 @@
+package routing_table_test
+
+import (
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("EndpointTags", func() {
+	Describe("ParseEndpointTags", func() {
+		It("parses a comma-separated list of tags", func() {
+			tags, err := routing_table.ParseEndpointTags("instance_index, cell_id,availability_zone")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tags).To(Equal([]routing_table.EndpointTag{
+				routing_table.InstanceIndexTag,
+				routing_table.CellIdTag,
+				routing_table.AvailabilityZoneTag,
+			}))
+		})
+
+		It("returns no tags for an empty list", func() {
+			tags, err := routing_table.ParseEndpointTags("")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tags).To(BeEmpty())
+		})
+
+		It("rejects unknown tags", func() {
+			_, err := routing_table.ParseEndpointTags("cell_id,rack")
+			Expect(err).To(MatchError("unknown endpoint tag: rack"))
+		})
+	})
+})
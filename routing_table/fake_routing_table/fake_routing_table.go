@@ -23,6 +23,14 @@ type FakeRoutingTable struct {
 	swapReturns struct {
 		result1 routing_table.MessagesToEmit
 	}
+	DiffStub        func(newTable routing_table.RoutingTable) routing_table.MessagesToEmit
+	diffMutex       sync.RWMutex
+	diffArgsForCall []struct {
+		newTable routing_table.RoutingTable
+	}
+	diffReturns struct {
+		result1 routing_table.MessagesToEmit
+	}
 	SetRoutesStub        func(key routing_table.RoutingKey, routes routing_table.Routes) routing_table.MessagesToEmit
 	setRoutesMutex       sync.RWMutex
 	setRoutesArgsForCall []struct {
@@ -65,6 +73,56 @@ type FakeRoutingTable struct {
 	messagesToEmitReturns struct {
 		result1 routing_table.MessagesToEmit
 	}
+	SnapshotStub        func() routing_table.Snapshot
+	snapshotMutex       sync.RWMutex
+	snapshotArgsForCall []struct{}
+	snapshotReturns     struct {
+		result1 routing_table.Snapshot
+	}
+	ConflictsStub        func() []routing_table.HostnameConflict
+	conflictsMutex       sync.RWMutex
+	conflictsArgsForCall []struct{}
+	conflictsReturns     struct {
+		result1 []routing_table.HostnameConflict
+	}
+	RejectedRoutesStub        func() []routing_table.RejectedRoutes
+	rejectedRoutesMutex       sync.RWMutex
+	rejectedRoutesArgsForCall []struct{}
+	rejectedRoutesReturns     struct {
+		result1 []routing_table.RejectedRoutes
+	}
+	RoutesForHostnameStub        func(hostname string) []routing_table.RouteInfo
+	routesForHostnameMutex       sync.RWMutex
+	routesForHostnameArgsForCall []struct {
+		hostname string
+	}
+	routesForHostnameReturns struct {
+		result1 []routing_table.RouteInfo
+	}
+	RoutesForProcessGuidStub        func(processGuid string) []routing_table.RouteInfo
+	routesForProcessGuidMutex       sync.RWMutex
+	routesForProcessGuidArgsForCall []struct {
+		processGuid string
+	}
+	routesForProcessGuidReturns struct {
+		result1 []routing_table.RouteInfo
+	}
+	RoutesForInstanceGuidStub        func(instanceGuid string) []routing_table.RouteInfo
+	routesForInstanceGuidMutex       sync.RWMutex
+	routesForInstanceGuidArgsForCall []struct {
+		instanceGuid string
+	}
+	routesForInstanceGuidReturns struct {
+		result1 []routing_table.RouteInfo
+	}
+	RoutesForCellStub        func(cellId string) []routing_table.RouteInfo
+	routesForCellMutex       sync.RWMutex
+	routesForCellArgsForCall []struct {
+		cellId string
+	}
+	routesForCellReturns struct {
+		result1 []routing_table.RouteInfo
+	}
 }
 
 func (fake *FakeRoutingTable) RouteCount() int {
@@ -123,6 +181,38 @@ func (fake *FakeRoutingTable) SwapReturns(result1 routing_table.MessagesToEmit)
 	}{result1}
 }
 
+func (fake *FakeRoutingTable) Diff(newTable routing_table.RoutingTable) routing_table.MessagesToEmit {
+	fake.diffMutex.Lock()
+	fake.diffArgsForCall = append(fake.diffArgsForCall, struct {
+		newTable routing_table.RoutingTable
+	}{newTable})
+	fake.diffMutex.Unlock()
+	if fake.DiffStub != nil {
+		return fake.DiffStub(newTable)
+	} else {
+		return fake.diffReturns.result1
+	}
+}
+
+func (fake *FakeRoutingTable) DiffCallCount() int {
+	fake.diffMutex.RLock()
+	defer fake.diffMutex.RUnlock()
+	return len(fake.diffArgsForCall)
+}
+
+func (fake *FakeRoutingTable) DiffArgsForCall(i int) routing_table.RoutingTable {
+	fake.diffMutex.RLock()
+	defer fake.diffMutex.RUnlock()
+	return fake.diffArgsForCall[i].newTable
+}
+
+func (fake *FakeRoutingTable) DiffReturns(result1 routing_table.MessagesToEmit) {
+	fake.DiffStub = nil
+	fake.diffReturns = struct {
+		result1 routing_table.MessagesToEmit
+	}{result1}
+}
+
 func (fake *FakeRoutingTable) SetRoutes(key routing_table.RoutingKey, routes routing_table.Routes) routing_table.MessagesToEmit {
 	fake.setRoutesMutex.Lock()
 	fake.setRoutesArgsForCall = append(fake.setRoutesArgsForCall, struct {
@@ -279,4 +369,204 @@ func (fake *FakeRoutingTable) MessagesToEmitReturns(result1 routing_table.Messag
 	}{result1}
 }
 
+func (fake *FakeRoutingTable) Snapshot() routing_table.Snapshot {
+	fake.snapshotMutex.Lock()
+	fake.snapshotArgsForCall = append(fake.snapshotArgsForCall, struct{}{})
+	fake.snapshotMutex.Unlock()
+	if fake.SnapshotStub != nil {
+		return fake.SnapshotStub()
+	} else {
+		return fake.snapshotReturns.result1
+	}
+}
+
+func (fake *FakeRoutingTable) SnapshotCallCount() int {
+	fake.snapshotMutex.RLock()
+	defer fake.snapshotMutex.RUnlock()
+	return len(fake.snapshotArgsForCall)
+}
+
+func (fake *FakeRoutingTable) SnapshotReturns(result1 routing_table.Snapshot) {
+	fake.SnapshotStub = nil
+	fake.snapshotReturns = struct {
+		result1 routing_table.Snapshot
+	}{result1}
+}
+
+func (fake *FakeRoutingTable) Conflicts() []routing_table.HostnameConflict {
+	fake.conflictsMutex.Lock()
+	fake.conflictsArgsForCall = append(fake.conflictsArgsForCall, struct{}{})
+	fake.conflictsMutex.Unlock()
+	if fake.ConflictsStub != nil {
+		return fake.ConflictsStub()
+	} else {
+		return fake.conflictsReturns.result1
+	}
+}
+
+func (fake *FakeRoutingTable) ConflictsCallCount() int {
+	fake.conflictsMutex.RLock()
+	defer fake.conflictsMutex.RUnlock()
+	return len(fake.conflictsArgsForCall)
+}
+
+func (fake *FakeRoutingTable) ConflictsReturns(result1 []routing_table.HostnameConflict) {
+	fake.ConflictsStub = nil
+	fake.conflictsReturns = struct {
+		result1 []routing_table.HostnameConflict
+	}{result1}
+}
+
+func (fake *FakeRoutingTable) RejectedRoutes() []routing_table.RejectedRoutes {
+	fake.rejectedRoutesMutex.Lock()
+	fake.rejectedRoutesArgsForCall = append(fake.rejectedRoutesArgsForCall, struct{}{})
+	fake.rejectedRoutesMutex.Unlock()
+	if fake.RejectedRoutesStub != nil {
+		return fake.RejectedRoutesStub()
+	} else {
+		return fake.rejectedRoutesReturns.result1
+	}
+}
+
+func (fake *FakeRoutingTable) RejectedRoutesCallCount() int {
+	fake.rejectedRoutesMutex.RLock()
+	defer fake.rejectedRoutesMutex.RUnlock()
+	return len(fake.rejectedRoutesArgsForCall)
+}
+
+func (fake *FakeRoutingTable) RejectedRoutesReturns(result1 []routing_table.RejectedRoutes) {
+	fake.RejectedRoutesStub = nil
+	fake.rejectedRoutesReturns = struct {
+		result1 []routing_table.RejectedRoutes
+	}{result1}
+}
+
+func (fake *FakeRoutingTable) RoutesForHostname(hostname string) []routing_table.RouteInfo {
+	fake.routesForHostnameMutex.Lock()
+	fake.routesForHostnameArgsForCall = append(fake.routesForHostnameArgsForCall, struct {
+		hostname string
+	}{hostname})
+	fake.routesForHostnameMutex.Unlock()
+	if fake.RoutesForHostnameStub != nil {
+		return fake.RoutesForHostnameStub(hostname)
+	} else {
+		return fake.routesForHostnameReturns.result1
+	}
+}
+
+func (fake *FakeRoutingTable) RoutesForHostnameCallCount() int {
+	fake.routesForHostnameMutex.RLock()
+	defer fake.routesForHostnameMutex.RUnlock()
+	return len(fake.routesForHostnameArgsForCall)
+}
+
+func (fake *FakeRoutingTable) RoutesForHostnameArgsForCall(i int) string {
+	fake.routesForHostnameMutex.RLock()
+	defer fake.routesForHostnameMutex.RUnlock()
+	return fake.routesForHostnameArgsForCall[i].hostname
+}
+
+func (fake *FakeRoutingTable) RoutesForHostnameReturns(result1 []routing_table.RouteInfo) {
+	fake.RoutesForHostnameStub = nil
+	fake.routesForHostnameReturns = struct {
+		result1 []routing_table.RouteInfo
+	}{result1}
+}
+
+func (fake *FakeRoutingTable) RoutesForProcessGuid(processGuid string) []routing_table.RouteInfo {
+	fake.routesForProcessGuidMutex.Lock()
+	fake.routesForProcessGuidArgsForCall = append(fake.routesForProcessGuidArgsForCall, struct {
+		processGuid string
+	}{processGuid})
+	fake.routesForProcessGuidMutex.Unlock()
+	if fake.RoutesForProcessGuidStub != nil {
+		return fake.RoutesForProcessGuidStub(processGuid)
+	} else {
+		return fake.routesForProcessGuidReturns.result1
+	}
+}
+
+func (fake *FakeRoutingTable) RoutesForProcessGuidCallCount() int {
+	fake.routesForProcessGuidMutex.RLock()
+	defer fake.routesForProcessGuidMutex.RUnlock()
+	return len(fake.routesForProcessGuidArgsForCall)
+}
+
+func (fake *FakeRoutingTable) RoutesForProcessGuidArgsForCall(i int) string {
+	fake.routesForProcessGuidMutex.RLock()
+	defer fake.routesForProcessGuidMutex.RUnlock()
+	return fake.routesForProcessGuidArgsForCall[i].processGuid
+}
+
+func (fake *FakeRoutingTable) RoutesForProcessGuidReturns(result1 []routing_table.RouteInfo) {
+	fake.RoutesForProcessGuidStub = nil
+	fake.routesForProcessGuidReturns = struct {
+		result1 []routing_table.RouteInfo
+	}{result1}
+}
+
+func (fake *FakeRoutingTable) RoutesForInstanceGuid(instanceGuid string) []routing_table.RouteInfo {
+	fake.routesForInstanceGuidMutex.Lock()
+	fake.routesForInstanceGuidArgsForCall = append(fake.routesForInstanceGuidArgsForCall, struct {
+		instanceGuid string
+	}{instanceGuid})
+	fake.routesForInstanceGuidMutex.Unlock()
+	if fake.RoutesForInstanceGuidStub != nil {
+		return fake.RoutesForInstanceGuidStub(instanceGuid)
+	} else {
+		return fake.routesForInstanceGuidReturns.result1
+	}
+}
+
+func (fake *FakeRoutingTable) RoutesForInstanceGuidCallCount() int {
+	fake.routesForInstanceGuidMutex.RLock()
+	defer fake.routesForInstanceGuidMutex.RUnlock()
+	return len(fake.routesForInstanceGuidArgsForCall)
+}
+
+func (fake *FakeRoutingTable) RoutesForInstanceGuidArgsForCall(i int) string {
+	fake.routesForInstanceGuidMutex.RLock()
+	defer fake.routesForInstanceGuidMutex.RUnlock()
+	return fake.routesForInstanceGuidArgsForCall[i].instanceGuid
+}
+
+func (fake *FakeRoutingTable) RoutesForInstanceGuidReturns(result1 []routing_table.RouteInfo) {
+	fake.RoutesForInstanceGuidStub = nil
+	fake.routesForInstanceGuidReturns = struct {
+		result1 []routing_table.RouteInfo
+	}{result1}
+}
+
+func (fake *FakeRoutingTable) RoutesForCell(cellId string) []routing_table.RouteInfo {
+	fake.routesForCellMutex.Lock()
+	fake.routesForCellArgsForCall = append(fake.routesForCellArgsForCall, struct {
+		cellId string
+	}{cellId})
+	fake.routesForCellMutex.Unlock()
+	if fake.RoutesForCellStub != nil {
+		return fake.RoutesForCellStub(cellId)
+	} else {
+		return fake.routesForCellReturns.result1
+	}
+}
+
+func (fake *FakeRoutingTable) RoutesForCellCallCount() int {
+	fake.routesForCellMutex.RLock()
+	defer fake.routesForCellMutex.RUnlock()
+	return len(fake.routesForCellArgsForCall)
+}
+
+func (fake *FakeRoutingTable) RoutesForCellArgsForCall(i int) string {
+	fake.routesForCellMutex.RLock()
+	defer fake.routesForCellMutex.RUnlock()
+	return fake.routesForCellArgsForCall[i].cellId
+}
+
+func (fake *FakeRoutingTable) RoutesForCellReturns(result1 []routing_table.RouteInfo) {
+	fake.RoutesForCellStub = nil
+	fake.routesForCellReturns = struct {
+		result1 []routing_table.RouteInfo
+	}{result1}
+}
+
 var _ routing_table.RoutingTable = new(FakeRoutingTable)
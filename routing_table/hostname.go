@@ -0,0 +1,208 @@
+package routing_table
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/idna"
+)
+
+const (
+	maxHostnameLength = 253
+	maxLabelLength    = 63
+)
+
+// NormalizeHostname validates a hostname against RFC 1123 and returns its
+// canonical form: lower-cased, without a trailing dot, and with
+// internationalized labels converted to punycode. A leading "*" label is
+// accepted for wildcard routes.
+func NormalizeHostname(hostname string) (string, error) {
+	hostname = strings.TrimSuffix(hostname, ".")
+	if hostname == "" {
+		return "", errors.New("hostname is empty")
+	}
+
+	hostname = strings.ToLower(hostname)
+	if !isASCII(hostname) {
+		ascii, err := idna.ToASCII(hostname)
+		if err != nil {
+			return "", fmt.Errorf("invalid internationalized hostname: %s", err)
+		}
+		hostname = ascii
+	}
+
+	if len(hostname) > maxHostnameLength {
+		return "", fmt.Errorf("hostname is longer than %d characters", maxHostnameLength)
+	}
+
+	labels := strings.Split(hostname, ".")
+	for i, label := range labels {
+		if i == 0 && label == "*" && len(labels) > 1 {
+			continue
+		}
+
+		err := validateLabel(label)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return hostname, nil
+}
+
+func validateLabel(label string) error {
+	if label == "" {
+		return errors.New("hostname has an empty label")
+	}
+
+	if len(label) > maxLabelLength {
+		return fmt.Errorf("label %q is longer than %d characters", label, maxLabelLength)
+	}
+
+	if label[0] == '-' || label[len(label)-1] == '-' {
+		return fmt.Errorf("label %q starts or ends with a hyphen", label)
+	}
+
+	for i := 0; i < len(label); i++ {
+		c := label[i]
+		if !('a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-') {
+			return fmt.Errorf("label %q contains invalid character %q", label, c)
+		}
+	}
+
+	return nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// RejectedHostname is a route that was not registered because its hostname
+// failed validation.
+type RejectedHostname struct {
+	Hostname string `json:"hostname"`
+	Reason   string `json:"reason"`
+}
+
+type RejectedRoutes struct {
+	ProcessGuid string             `json:"process_guid"`
+	Hostnames   []RejectedHostname `json:"hostnames"`
+}
+
+// normalizeRoutes parses route URIs, normalizing their hostnames. Routes with
+// an invalid hostname are left out and returned as rejected.
+func normalizeRoutes(uris []string) (map[Route]struct{}, []RejectedHostname) {
+	routes := map[Route]struct{}{}
+	rejected := []RejectedHostname{}
+
+	for _, uri := range uris {
+		route := NewRoute(uri)
+
+		hostname, err := NormalizeHostname(route.Hostname)
+		if err != nil {
+			rejected = append(rejected, RejectedHostname{Hostname: uri, Reason: err.Error()})
+			continue
+		}
+
+		route.Hostname = hostname
+		routes[route] = struct{}{}
+	}
+
+	return routes, rejected
+}
+
+type rejectedHostnames struct {
+	sync.Mutex
+	byKey map[RoutingKey][]RejectedHostname
+}
+
+func newRejectedHostnames() *rejectedHostnames {
+	return &rejectedHostnames{byKey: map[RoutingKey][]RejectedHostname{}}
+}
+
+// set records the rejected hostnames of key, and reports whether they changed.
+func (r *rejectedHostnames) set(key RoutingKey, rejected []RejectedHostname) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	changed := !sameRejections(r.byKey[key], rejected)
+	if len(rejected) == 0 {
+		delete(r.byKey, key)
+	} else {
+		r.byKey[key] = rejected
+	}
+
+	return changed
+}
+
+// replace swaps in the rejected hostnames of a complete set of routing keys,
+// returning the ones that changed.
+func (r *rejectedHostnames) replace(byKey map[RoutingKey][]RejectedHostname) map[RoutingKey][]RejectedHostname {
+	r.Lock()
+	defer r.Unlock()
+
+	changed := map[RoutingKey][]RejectedHostname{}
+	for key, rejected := range byKey {
+		if !sameRejections(r.byKey[key], rejected) {
+			changed[key] = rejected
+		}
+	}
+
+	r.byKey = byKey
+	return changed
+}
+
+func (r *rejectedHostnames) list() []RejectedRoutes {
+	r.Lock()
+	defer r.Unlock()
+
+	byProcessGuid := map[string][]RejectedHostname{}
+	for key, rejected := range r.byKey {
+		byProcessGuid[key.ProcessGuid] = append(byProcessGuid[key.ProcessGuid], rejected...)
+	}
+
+	list := []RejectedRoutes{}
+	for processGuid, rejected := range byProcessGuid {
+		sort.Sort(rejectedHostnamesByHostname(rejected))
+		list = append(list, RejectedRoutes{ProcessGuid: processGuid, Hostnames: rejected})
+	}
+
+	sort.Sort(rejectedRoutesByProcessGuid(list))
+	return list
+}
+
+func sameRejections(a, b []RejectedHostname) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+type rejectedHostnamesByHostname []RejectedHostname
+
+func (list rejectedHostnamesByHostname) Len() int      { return len(list) }
+func (list rejectedHostnamesByHostname) Swap(i, j int) { list[i], list[j] = list[j], list[i] }
+func (list rejectedHostnamesByHostname) Less(i, j int) bool {
+	return list[i].Hostname < list[j].Hostname
+}
+
+type rejectedRoutesByProcessGuid []RejectedRoutes
+
+func (list rejectedRoutesByProcessGuid) Len() int      { return len(list) }
+func (list rejectedRoutesByProcessGuid) Swap(i, j int) { list[i], list[j] = list[j], list[i] }
+func (list rejectedRoutesByProcessGuid) Less(i, j int) bool {
+	return list[i].ProcessGuid < list[j].ProcessGuid
+}
@@ -0,0 +1,282 @@
+package routing_table
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ConflictPolicy decides what happens when routing keys of different process
+// guids claim the same hostname.
+type ConflictPolicy string
+
+const (
+	// ConflictPolicyAllow registers the hostname for every claimant, so the
+	// router balances requests across all of them.
+	ConflictPolicyAllow ConflictPolicy = "allow"
+	// ConflictPolicyWarn behaves like ConflictPolicyAllow, but logs each new
+	// conflict.
+	ConflictPolicyWarn ConflictPolicy = "warn"
+	// ConflictPolicyFirstOwnerWins only registers the hostname for the process
+	// guid that claimed it first, and logs each claim it denies. Ownership
+	// follows the routes rather than the endpoints: the owner keeps the
+	// hostname until its routes no longer include it, whereupon the next
+	// claimant takes it over and registers it right away.
+	ConflictPolicyFirstOwnerWins ConflictPolicy = "first-owner-wins"
+)
+
+func ParseConflictPolicy(name string) (ConflictPolicy, error) {
+	switch policy := ConflictPolicy(name); policy {
+	case ConflictPolicyAllow, ConflictPolicyWarn, ConflictPolicyFirstOwnerWins:
+		return policy, nil
+	case "":
+		return ConflictPolicyAllow, nil
+	}
+	return "", fmt.Errorf("unknown hostname conflict policy: %s", name)
+}
+
+// HostnameConflict describes a hostname claimed by more than one process
+// guid. The hostname may carry a context path; routes on different paths of
+// the same host do not conflict. Owner is the process guid that claimed the
+// hostname first.
+type HostnameConflict struct {
+	Hostname  string   `json:"hostname"`
+	Owner     string   `json:"owner"`
+	Claimants []string `json:"claimants"`
+}
+
+// hostnameOwners indexes the routing keys claiming each hostname, in the
+// order in which they claimed it.
+type hostnameOwners struct {
+	sync.Mutex
+	claims      map[string][]RoutingKey
+	claimsByKey map[RoutingKey]map[string]struct{}
+}
+
+func newHostnameOwners() *hostnameOwners {
+	return &hostnameOwners{
+		claims:      map[string][]RoutingKey{},
+		claimsByKey: map[RoutingKey]map[string]struct{}{},
+	}
+}
+
+// claim replaces the hostnames claimed by key. It returns the hostnames owned
+// by another process guid, the conflicts introduced by the claim, and the
+// routing keys that took over a hostname released by key.
+func (owners *hostnameOwners) claim(key RoutingKey, hostnames map[string]struct{}) (map[string]struct{}, []HostnameConflict, []RoutingKey) {
+	owners.Lock()
+	defer owners.Unlock()
+
+	previous := owners.claimsByKey[key]
+	successors := []RoutingKey{}
+	for hostname := range previous {
+		if _, ok := hostnames[hostname]; !ok {
+			successors = append(successors, owners.release(hostname, key)...)
+		}
+	}
+
+	if len(hostnames) == 0 {
+		delete(owners.claimsByKey, key)
+	} else {
+		owners.claimsByKey[key] = hostnames
+	}
+
+	denied := map[string]struct{}{}
+	conflicts := []HostnameConflict{}
+	for hostname := range hostnames {
+		if _, ok := previous[hostname]; !ok {
+			owners.claims[hostname] = append(owners.claims[hostname], key)
+		}
+
+		owner := owners.claims[hostname][0].ProcessGuid
+		if owner == key.ProcessGuid {
+			continue
+		}
+
+		denied[hostname] = struct{}{}
+		if _, ok := previous[hostname]; !ok {
+			conflicts = append(conflicts, HostnameConflict{Hostname: hostname, Owner: owner, Claimants: []string{key.ProcessGuid}})
+		}
+	}
+
+	return denied, conflicts, successors
+}
+
+// release withdraws the claim of key on hostname. Should that hand the
+// hostname over to another process guid, it returns the routing keys of the
+// new owner.
+func (owners *hostnameOwners) release(hostname string, key RoutingKey) []RoutingKey {
+	claims := owners.claims[hostname]
+	if len(claims) == 0 {
+		return nil
+	}
+	owner := claims[0].ProcessGuid
+
+	for i := range claims {
+		if claims[i] == key {
+			claims = append(claims[:i:i], claims[i+1:]...)
+			break
+		}
+	}
+
+	if len(claims) == 0 {
+		delete(owners.claims, hostname)
+		return nil
+	}
+	owners.claims[hostname] = claims
+
+	if claims[0].ProcessGuid == owner {
+		return nil
+	}
+
+	successors := []RoutingKey{}
+	for _, claimant := range claims {
+		if claimant.ProcessGuid == claims[0].ProcessGuid {
+			successors = append(successors, claimant)
+		}
+	}
+	return successors
+}
+
+// owned returns the hostnames claimed by key that its process guid owns.
+func (owners *hostnameOwners) owned(key RoutingKey) map[string]struct{} {
+	owners.Lock()
+	defer owners.Unlock()
+
+	owned := map[string]struct{}{}
+	for hostname := range owners.claimsByKey[key] {
+		if owners.claims[hostname][0].ProcessGuid == key.ProcessGuid {
+			owned[hostname] = struct{}{}
+		}
+	}
+	return owned
+}
+
+// resolve orders the claims of a complete set of routing keys, as seen on a
+// sync. Keys that already claimed a hostname keep their place, and new
+// claimants follow in a stable order.
+func (owners *hostnameOwners) resolve(claimsByKey map[RoutingKey]map[string]struct{}) map[string][]RoutingKey {
+	owners.Lock()
+	defer owners.Unlock()
+
+	return owners.resolveLocked(claimsByKey)
+}
+
+func (owners *hostnameOwners) resolveLocked(claimsByKey map[RoutingKey]map[string]struct{}) map[string][]RoutingKey {
+	newClaimants := map[string][]RoutingKey{}
+	for key, hostnames := range claimsByKey {
+		for hostname := range hostnames {
+			if _, ok := owners.claimsByKey[key][hostname]; !ok {
+				newClaimants[hostname] = append(newClaimants[hostname], key)
+			}
+		}
+	}
+
+	claims := map[string][]RoutingKey{}
+	for hostname, existing := range owners.claims {
+		for _, key := range existing {
+			if _, ok := claimsByKey[key][hostname]; ok {
+				claims[hostname] = append(claims[hostname], key)
+			}
+		}
+	}
+
+	for hostname, keys := range newClaimants {
+		sort.Sort(byProcessGuidAndPort(keys))
+		claims[hostname] = append(claims[hostname], keys...)
+	}
+
+	return claims
+}
+
+// replace swaps in a complete set of claims. It returns the resolved claims and
+// the conflicts that did not exist before.
+func (owners *hostnameOwners) replace(claimsByKey map[RoutingKey]map[string]struct{}) (map[string][]RoutingKey, []HostnameConflict) {
+	owners.Lock()
+	defer owners.Unlock()
+
+	previous := conflictingClaimants(owners.claims)
+	owners.claims = owners.resolveLocked(claimsByKey)
+	owners.claimsByKey = claimsByKey
+
+	conflicts := []HostnameConflict{}
+	for _, conflict := range conflictsIn(owners.claims) {
+		for _, claimant := range conflict.Claimants {
+			if _, ok := previous[conflict.Hostname][claimant]; !ok {
+				conflicts = append(conflicts, HostnameConflict{Hostname: conflict.Hostname, Owner: conflict.Owner, Claimants: []string{claimant}})
+			}
+		}
+	}
+
+	return owners.claims, conflicts
+}
+
+func (owners *hostnameOwners) conflicts() []HostnameConflict {
+	owners.Lock()
+	defer owners.Unlock()
+
+	return conflictsIn(owners.claims)
+}
+
+// deniedHostnames returns the hostnames of key that are owned by another
+// process guid.
+func deniedHostnames(claims map[string][]RoutingKey, key RoutingKey, hostnames map[string]struct{}) map[string]struct{} {
+	denied := map[string]struct{}{}
+	for hostname := range hostnames {
+		if claims[hostname][0].ProcessGuid != key.ProcessGuid {
+			denied[hostname] = struct{}{}
+		}
+	}
+	return denied
+}
+
+func conflictsIn(claims map[string][]RoutingKey) []HostnameConflict {
+	conflicts := []HostnameConflict{}
+	for hostname, keys := range claims {
+		owner := keys[0].ProcessGuid
+		seen := map[string]struct{}{owner: struct{}{}}
+		claimants := []string{}
+		for _, key := range keys[1:] {
+			if _, ok := seen[key.ProcessGuid]; ok {
+				continue
+			}
+			seen[key.ProcessGuid] = struct{}{}
+			claimants = append(claimants, key.ProcessGuid)
+		}
+
+		if len(claimants) > 0 {
+			conflicts = append(conflicts, HostnameConflict{Hostname: hostname, Owner: owner, Claimants: claimants})
+		}
+	}
+
+	sort.Sort(byHostname(conflicts))
+	return conflicts
+}
+
+func conflictingClaimants(claims map[string][]RoutingKey) map[string]map[string]struct{} {
+	claimants := map[string]map[string]struct{}{}
+	for _, conflict := range conflictsIn(claims) {
+		claimants[conflict.Hostname] = map[string]struct{}{}
+		for _, claimant := range conflict.Claimants {
+			claimants[conflict.Hostname][claimant] = struct{}{}
+		}
+	}
+	return claimants
+}
+
+type byProcessGuidAndPort []RoutingKey
+
+func (keys byProcessGuidAndPort) Len() int      { return len(keys) }
+func (keys byProcessGuidAndPort) Swap(i, j int) { keys[i], keys[j] = keys[j], keys[i] }
+func (keys byProcessGuidAndPort) Less(i, j int) bool {
+	if keys[i].ProcessGuid != keys[j].ProcessGuid {
+		return keys[i].ProcessGuid < keys[j].ProcessGuid
+	}
+	return keys[i].ContainerPort < keys[j].ContainerPort
+}
+
+type byHostname []HostnameConflict
+
+func (conflicts byHostname) Len() int           { return len(conflicts) }
+func (conflicts byHostname) Swap(i, j int)      { conflicts[i], conflicts[j] = conflicts[j], conflicts[i] }
+func (conflicts byHostname) Less(i, j int) bool { return conflicts[i].Hostname < conflicts[j].Hostname }
@@ -0,0 +1,29 @@
+package routing_table_test
+
+import (
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParseConflictPolicy", func() {
+	It("parses the known policies", func() {
+		for _, name := range []string{"allow", "warn", "first-owner-wins"} {
+			policy, err := routing_table.ParseConflictPolicy(name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy).To(Equal(routing_table.ConflictPolicy(name)))
+		}
+	})
+
+	It("defaults to allow", func() {
+		policy, err := routing_table.ParseConflictPolicy("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policy).To(Equal(routing_table.ConflictPolicyAllow))
+	})
+
+	It("errors on an unknown policy", func() {
+		_, err := routing_table.ParseConflictPolicy("last-owner-wins")
+		Expect(err).To(MatchError("unknown hostname conflict policy: last-owner-wins"))
+	})
+})
@@ -0,0 +1,89 @@
+package routing_table_test
+
+import (
+	"strings"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NormalizeHostname", func() {
+	normalize := func(hostname string) string {
+		normalized, err := routing_table.NormalizeHostname(hostname)
+		Expect(err).NotTo(HaveOccurred())
+		return normalized
+	}
+
+	It("lower-cases the hostname", func() {
+		Expect(normalize("Foo.Example.COM")).To(Equal("foo.example.com"))
+	})
+
+	It("removes a trailing dot", func() {
+		Expect(normalize("foo.example.com.")).To(Equal("foo.example.com"))
+	})
+
+	It("converts internationalized hostnames to punycode", func() {
+		Expect(normalize("BÜCHER.example.com")).To(Equal("xn--bcher-kva.example.com"))
+		Expect(normalize("xn--bcher-kva.example.com")).To(Equal("xn--bcher-kva.example.com"))
+	})
+
+	It("accepts labels starting with a digit", func() {
+		Expect(normalize("123.example.com")).To(Equal("123.example.com"))
+	})
+
+	It("accepts a leading wildcard label", func() {
+		Expect(normalize("*.example.com")).To(Equal("*.example.com"))
+	})
+
+	It("rejects wildcards anywhere else", func() {
+		_, err := routing_table.NormalizeHostname("foo.*.example.com")
+		Expect(err).To(MatchError(`label "*" contains invalid character '*'`))
+
+		_, err = routing_table.NormalizeHostname("*")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects an empty hostname", func() {
+		_, err := routing_table.NormalizeHostname(".")
+		Expect(err).To(MatchError("hostname is empty"))
+	})
+
+	It("rejects empty labels", func() {
+		_, err := routing_table.NormalizeHostname("foo..example.com")
+		Expect(err).To(MatchError("hostname has an empty label"))
+	})
+
+	It("rejects invalid characters", func() {
+		_, err := routing_table.NormalizeHostname("foo_bar.example.com")
+		Expect(err).To(MatchError(`label "foo_bar" contains invalid character '_'`))
+
+		_, err = routing_table.NormalizeHostname("foo.example.com:8080")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects labels starting or ending with a hyphen", func() {
+		_, err := routing_table.NormalizeHostname("-foo.example.com")
+		Expect(err).To(MatchError(`label "-foo" starts or ends with a hyphen`))
+
+		_, err = routing_table.NormalizeHostname("foo-.example.com")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects labels longer than 63 characters", func() {
+		_, err := routing_table.NormalizeHostname(strings.Repeat("a", 63) + ".example.com")
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = routing_table.NormalizeHostname(strings.Repeat("a", 64) + ".example.com")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects hostnames longer than 253 characters", func() {
+		_, err := routing_table.NormalizeHostname(strings.Repeat("a.", 126) + "a")
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = routing_table.NormalizeHostname(strings.Repeat("a.", 126) + "ab")
+		Expect(err).To(MatchError("hostname is longer than 253 characters"))
+	})
+})
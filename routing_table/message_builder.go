@@ -1,5 +1,7 @@
 package routing_table
 
+import "time"
+
 type MessageBuilder interface {
 	RegistrationsFor(existingEntry *RoutableEndpoints, newEntry *RoutableEndpoints) MessagesToEmit
 	UnregistrationsFor(existingEntry *RoutableEndpoints, newEntry *RoutableEndpoints) MessagesToEmit
@@ -16,19 +18,35 @@ func (NoopMessageBuilder) UnregistrationsFor(existingEntry *RoutableEndpoints, n
 }
 
 type MessagesToEmitBuilder struct {
+	// EndpointTags selects the endpoint metadata included in the tags of
+	// each registry message.
+	EndpointTags []EndpointTag
+
+	// MessageVersion selects the layout of the registry messages, and
+	// StaleThreshold is advertised in version 2 messages.
+	MessageVersion RegistryMessageVersion
+	StaleThreshold time.Duration
+}
+
+func (builder MessagesToEmitBuilder) messageFor(endpoint Endpoint, routes Routes) RegistryMessage {
+	return RegistryMessageWithOptionsFor(endpoint, routes, RegistryMessageOptions{
+		Tags:           builder.EndpointTags,
+		Version:        builder.MessageVersion,
+		StaleThreshold: builder.StaleThreshold,
+	})
 }
 
-func (MessagesToEmitBuilder) RegistrationsFor(existingEntry *RoutableEndpoints, newEntry *RoutableEndpoints) MessagesToEmit {
+func (builder MessagesToEmitBuilder) RegistrationsFor(existingEntry *RoutableEndpoints, newEntry *RoutableEndpoints) MessagesToEmit {
 	messagesToEmit := MessagesToEmit{}
 
-	if len(newEntry.Hostnames) == 0 {
-		//no hostnames, so nothing could possibly be registered
+	if len(newEntry.Routes) == 0 {
+		//no routes, so nothing could possibly be registered
 		return messagesToEmit
 	}
 
-	if existingEntry == nil || hostnamesHaveChanged(existingEntry, newEntry) || routeServiceUrlHasChanged(existingEntry, newEntry) {
+	if existingEntry == nil || routesHaveChanged(existingEntry, newEntry) || routeServiceUrlHasChanged(existingEntry, newEntry) {
 		for _, endpoint := range newEntry.Endpoints {
-			message := RegistryMessageFor(endpoint, newEntry.routes())
+			message := builder.messageFor(endpoint, newEntry.routes())
 			messagesToEmit.RegistrationMessages = append(messagesToEmit.RegistrationMessages, message)
 		}
 		return messagesToEmit
@@ -37,7 +55,7 @@ func (MessagesToEmitBuilder) RegistrationsFor(existingEntry *RoutableEndpoints,
 	//otherwise only register *new* endpoints
 	for _, endpoint := range newEntry.Endpoints {
 		if !existingEntry.hasEndpoint(endpoint) {
-			message := RegistryMessageFor(endpoint, newEntry.routes())
+			message := builder.messageFor(endpoint, newEntry.routes())
 			messagesToEmit.RegistrationMessages = append(messagesToEmit.RegistrationMessages, message)
 		}
 	}
@@ -45,11 +63,11 @@ func (MessagesToEmitBuilder) RegistrationsFor(existingEntry *RoutableEndpoints,
 	return messagesToEmit
 }
 
-func (MessagesToEmitBuilder) UnregistrationsFor(existingEntry *RoutableEndpoints, newEntry *RoutableEndpoints) MessagesToEmit {
+func (builder MessagesToEmitBuilder) UnregistrationsFor(existingEntry *RoutableEndpoints, newEntry *RoutableEndpoints) MessagesToEmit {
 	messagesToEmit := MessagesToEmit{}
 
-	if len(existingEntry.Hostnames) == 0 {
-		// the existing entry has no hostnames and so there is nothing to unregister
+	if len(existingEntry.Routes) == 0 {
+		// the existing entry has no routes and so there is nothing to unregister
 		return messagesToEmit
 	}
 
@@ -58,24 +76,24 @@ func (MessagesToEmitBuilder) UnregistrationsFor(existingEntry *RoutableEndpoints
 		if newEntry.hasEndpoint(endpoint) {
 			endpointsThatAreStillPresent = append(endpointsThatAreStillPresent, endpoint)
 		} else {
-			//if the endpoint has disappeared unregister all its previous hostnames
-			message := RegistryMessageFor(endpoint, existingEntry.routes())
+			//if the endpoint has disappeared unregister all its previous routes
+			message := builder.messageFor(endpoint, existingEntry.routes())
 			messagesToEmit.UnregistrationMessages = append(messagesToEmit.UnregistrationMessages, message)
 		}
 	}
 
-	hostnamesThatDisappeared := []string{}
-	for hostname := range existingEntry.Hostnames {
-		if !newEntry.hasHostname(hostname) {
-			hostnamesThatDisappeared = append(hostnamesThatDisappeared, hostname)
+	routesThatDisappeared := []string{}
+	for route := range existingEntry.Routes {
+		if !newEntry.hasRoute(route) {
+			routesThatDisappeared = append(routesThatDisappeared, route.URI())
 		}
 	}
 
-	if len(hostnamesThatDisappeared) > 0 {
+	if len(routesThatDisappeared) > 0 {
 		for _, endpoint := range endpointsThatAreStillPresent {
-			//if a endpoint is still present, and hostnames have disappeared, unregister those hostnames
-			message := RegistryMessageFor(endpoint, Routes{
-				Hostnames: hostnamesThatDisappeared,
+			//if a endpoint is still present, and routes have disappeared, unregister those routes
+			message := builder.messageFor(endpoint, Routes{
+				Hostnames: routesThatDisappeared,
 				LogGuid:   existingEntry.LogGuid,
 			})
 			messagesToEmit.UnregistrationMessages = append(messagesToEmit.UnregistrationMessages, message)
@@ -85,12 +103,12 @@ func (MessagesToEmitBuilder) UnregistrationsFor(existingEntry *RoutableEndpoints
 	return messagesToEmit
 }
 
-func hostnamesHaveChanged(existingEntry *RoutableEndpoints, newEntry *RoutableEndpoints) bool {
-	if len(newEntry.Hostnames) != len(existingEntry.Hostnames) {
+func routesHaveChanged(existingEntry *RoutableEndpoints, newEntry *RoutableEndpoints) bool {
+	if len(newEntry.Routes) != len(existingEntry.Routes) {
 		return true
 	} else {
-		for hostname := range newEntry.Hostnames {
-			if !existingEntry.hasHostname(hostname) {
+		for route := range newEntry.Routes {
+			if !existingEntry.hasRoute(route) {
 				return true
 			}
 		}
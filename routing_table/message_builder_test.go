@@ -1,6 +1,8 @@
 package routing_table_test
 
 import (
+	"time"
+
 	"github.com/cloudfoundry-incubator/bbs/models"
 	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
 	. "github.com/cloudfoundry-incubator/route-emitter/routing_table/matchers"
@@ -17,6 +19,7 @@ var _ = Describe("MessagesToEmitBuilder", func() {
 
 	hostname1 := "foo.example.com"
 	hostname2 := "bar.example.com"
+	hostname1WithPath := "foo.example.com/api"
 
 	currentTag := &models.ModificationTag{Epoch: "abc", Index: 1}
 	endpoint1 := routing_table.Endpoint{InstanceGuid: "ig-1", Host: "1.1.1.1", Port: 11, ContainerPort: 8080, Evacuating: false, ModificationTag: currentTag}
@@ -31,7 +34,7 @@ var _ = Describe("MessagesToEmitBuilder", func() {
 			existingEntry = nil
 
 			newEntry = &routing_table.RoutableEndpoints{
-				Hostnames: map[string]struct{}{hostname1: struct{}{}},
+				Routes:    routing_table.RoutesAsMap([]string{hostname1}),
 				Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1}),
 			}
 		})
@@ -51,9 +54,45 @@ var _ = Describe("MessagesToEmitBuilder", func() {
 			})
 		})
 
+		Context("when the builder is configured with endpoint tags", func() {
+			BeforeEach(func() {
+				builder = routing_table.MessagesToEmitBuilder{
+					EndpointTags: []routing_table.EndpointTag{routing_table.InstanceIndexTag},
+				}
+			})
+
+			It("includes the tags in the registration", func() {
+				expected := routing_table.MessagesToEmit{
+					RegistrationMessages: []routing_table.RegistryMessage{
+						routing_table.RegistryMessageWithTagsFor(endpoint1, routing_table.Routes{Hostnames: []string{hostname1}}, builder.EndpointTags),
+					},
+				}
+				Expect(messages).To(MatchMessagesToEmit(expected))
+				Expect(messages.RegistrationMessages[0].Tags).To(HaveKey("instance_index"))
+			})
+		})
+
+		Context("when the builder is configured for version 2 messages", func() {
+			BeforeEach(func() {
+				builder = routing_table.MessagesToEmitBuilder{
+					MessageVersion: routing_table.RegistryMessageV2,
+					StaleThreshold: time.Minute,
+				}
+			})
+
+			It("includes the freshness metadata in the registration", func() {
+				Expect(messages.RegistrationMessages).To(HaveLen(1))
+
+				message := messages.RegistrationMessages[0]
+				Expect(message.Version).To(Equal(routing_table.RegistryMessageV2))
+				Expect(message.ModificationTag).To(Equal(endpoint1.ModificationTag))
+				Expect(message.StaleThresholdInSeconds).To(Equal(60))
+			})
+		})
+
 		Context("when new entry has no hostnames", func() {
 			BeforeEach(func() {
-				newEntry.Hostnames = make(map[string]struct{})
+				newEntry.Routes = make(map[routing_table.Route]struct{})
 			})
 
 			It("emits nothing", func() {
@@ -75,7 +114,7 @@ var _ = Describe("MessagesToEmitBuilder", func() {
 			Context("when route service url changes", func() {
 				BeforeEach(func() {
 					existingEntry = &routing_table.RoutableEndpoints{
-						Hostnames:       map[string]struct{}{hostname1: struct{}{}},
+						Routes:          routing_table.RoutesAsMap([]string{hostname1}),
 						Endpoints:       routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1}),
 						RouteServiceUrl: "https://new-rs-url.com",
 					}
@@ -94,7 +133,7 @@ var _ = Describe("MessagesToEmitBuilder", func() {
 			Context("when hostnames change", func() {
 				BeforeEach(func() {
 					existingEntry = &routing_table.RoutableEndpoints{
-						Hostnames: map[string]struct{}{hostname2: struct{}{}},
+						Routes:    routing_table.RoutesAsMap([]string{hostname2}),
 						Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1}),
 					}
 				})
@@ -109,11 +148,46 @@ var _ = Describe("MessagesToEmitBuilder", func() {
 				})
 			})
 
+			Context("when a context path is added to a hostname", func() {
+				BeforeEach(func() {
+					existingEntry = &routing_table.RoutableEndpoints{
+						Routes:    routing_table.RoutesAsMap([]string{hostname1}),
+						Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1}),
+					}
+
+					newEntry.Routes = routing_table.RoutesAsMap([]string{hostname1, hostname1WithPath})
+				})
+
+				It("emits a registration including the context path", func() {
+					expected := routing_table.MessagesToEmit{
+						RegistrationMessages: []routing_table.RegistryMessage{
+							routing_table.RegistryMessageFor(endpoint1, routing_table.Routes{Hostnames: []string{hostname1, hostname1WithPath}}),
+						},
+					}
+					Expect(messages).To(MatchMessagesToEmit(expected))
+				})
+			})
+
+			Context("when a context path is only spelled differently", func() {
+				BeforeEach(func() {
+					existingEntry = &routing_table.RoutableEndpoints{
+						Routes:    routing_table.RoutesAsMap([]string{hostname1WithPath}),
+						Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1}),
+					}
+
+					newEntry.Routes = routing_table.RoutesAsMap([]string{"foo.example.com//api/"})
+				})
+
+				It("emits nothing", func() {
+					Expect(messages).To(BeZero())
+				})
+			})
+
 			Context("when endpoints are changed", func() {
 				Context("when endpoints are added", func() {
 					BeforeEach(func() {
 						existingEntry = &routing_table.RoutableEndpoints{
-							Hostnames: map[string]struct{}{hostname1: struct{}{}},
+							Routes:    routing_table.RoutesAsMap([]string{hostname1}),
 							Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1}),
 						}
 
@@ -133,7 +207,7 @@ var _ = Describe("MessagesToEmitBuilder", func() {
 				Context("when endpoints are removed", func() {
 					BeforeEach(func() {
 						existingEntry = &routing_table.RoutableEndpoints{
-							Hostnames: map[string]struct{}{hostname1: struct{}{}},
+							Routes:    routing_table.RoutesAsMap([]string{hostname1}),
 							Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1, endpoint2}),
 						}
 
@@ -156,12 +230,12 @@ var _ = Describe("MessagesToEmitBuilder", func() {
 		Context("when there are no hostnames in the existing", func() {
 			BeforeEach(func() {
 				existingEntry = &routing_table.RoutableEndpoints{
-					Hostnames: map[string]struct{}{},
+					Routes:    map[routing_table.Route]struct{}{},
 					Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1}),
 				}
 
 				newEntry = &routing_table.RoutableEndpoints{
-					Hostnames: map[string]struct{}{hostname1: struct{}{}},
+					Routes:    routing_table.RoutesAsMap([]string{hostname1}),
 					Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1}),
 				}
 			})
@@ -175,12 +249,12 @@ var _ = Describe("MessagesToEmitBuilder", func() {
 			Context("when a hostname removed", func() {
 				BeforeEach(func() {
 					existingEntry = &routing_table.RoutableEndpoints{
-						Hostnames: map[string]struct{}{hostname1: struct{}{}, hostname2: struct{}{}},
+						Routes:    routing_table.RoutesAsMap([]string{hostname1, hostname2}),
 						Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1}),
 					}
 
 					newEntry = &routing_table.RoutableEndpoints{
-						Hostnames: map[string]struct{}{},
+						Routes:    map[routing_table.Route]struct{}{},
 						Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1}),
 					}
 				})
@@ -198,12 +272,12 @@ var _ = Describe("MessagesToEmitBuilder", func() {
 			Context("when a hostname has been added", func() {
 				BeforeEach(func() {
 					existingEntry = &routing_table.RoutableEndpoints{
-						Hostnames: map[string]struct{}{hostname1: struct{}{}},
+						Routes:    routing_table.RoutesAsMap([]string{hostname1}),
 						Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1}),
 					}
 
 					newEntry = &routing_table.RoutableEndpoints{
-						Hostnames: map[string]struct{}{hostname1: struct{}{}, hostname2: struct{}{}},
+						Routes:    routing_table.RoutesAsMap([]string{hostname1, hostname2}),
 						Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1}),
 					}
 				})
@@ -213,10 +287,33 @@ var _ = Describe("MessagesToEmitBuilder", func() {
 				})
 			})
 
+			Context("when a context path has been removed", func() {
+				BeforeEach(func() {
+					existingEntry = &routing_table.RoutableEndpoints{
+						Routes:    routing_table.RoutesAsMap([]string{hostname1, hostname1WithPath}),
+						Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1}),
+					}
+
+					newEntry = &routing_table.RoutableEndpoints{
+						Routes:    routing_table.RoutesAsMap([]string{hostname1}),
+						Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1}),
+					}
+				})
+
+				It("emits an unregistration for the route with the context path only", func() {
+					expected := routing_table.MessagesToEmit{
+						UnregistrationMessages: []routing_table.RegistryMessage{
+							routing_table.RegistryMessageFor(endpoint1, routing_table.Routes{Hostnames: []string{hostname1WithPath}}),
+						},
+					}
+					Expect(messages).To(MatchMessagesToEmit(expected))
+				})
+			})
+
 			Context("when a hostname has not changed", func() {
 				BeforeEach(func() {
 					existingEntry = &routing_table.RoutableEndpoints{
-						Hostnames: map[string]struct{}{hostname1: struct{}{}},
+						Routes:    routing_table.RoutesAsMap([]string{hostname1}),
 						Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1}),
 					}
 
@@ -233,12 +330,12 @@ var _ = Describe("MessagesToEmitBuilder", func() {
 			Context("when an endpoint is removed", func() {
 				BeforeEach(func() {
 					existingEntry = &routing_table.RoutableEndpoints{
-						Hostnames: map[string]struct{}{hostname1: struct{}{}, hostname2: struct{}{}},
+						Routes:    routing_table.RoutesAsMap([]string{hostname1, hostname2}),
 						Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1}),
 					}
 
 					newEntry = &routing_table.RoutableEndpoints{
-						Hostnames: map[string]struct{}{hostname1: struct{}{}, hostname2: struct{}{}},
+						Routes:    routing_table.RoutesAsMap([]string{hostname1, hostname2}),
 						Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{}),
 					}
 				})
@@ -256,12 +353,12 @@ var _ = Describe("MessagesToEmitBuilder", func() {
 			Context("when an endpoint has been added", func() {
 				BeforeEach(func() {
 					existingEntry = &routing_table.RoutableEndpoints{
-						Hostnames: map[string]struct{}{hostname1: struct{}{}, hostname2: struct{}{}},
+						Routes:    routing_table.RoutesAsMap([]string{hostname1, hostname2}),
 						Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1}),
 					}
 
 					newEntry = &routing_table.RoutableEndpoints{
-						Hostnames: map[string]struct{}{hostname1: struct{}{}, hostname2: struct{}{}},
+						Routes:    routing_table.RoutesAsMap([]string{hostname1, hostname2}),
 						Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1, endpoint2}),
 					}
 				})
@@ -274,7 +371,7 @@ var _ = Describe("MessagesToEmitBuilder", func() {
 			Context("when endpoints have not changed", func() {
 				BeforeEach(func() {
 					existingEntry = &routing_table.RoutableEndpoints{
-						Hostnames: map[string]struct{}{hostname1: struct{}{}},
+						Routes:    routing_table.RoutesAsMap([]string{hostname1}),
 						Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1}),
 					}
 
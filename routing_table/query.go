@@ -0,0 +1,231 @@
+package routing_table
+
+import (
+	"sort"
+	"sync"
+)
+
+// RouteInfo is a read-only view of the routes and endpoints of a routing key,
+// as returned by the lookup methods of a RoutingTable.
+type RouteInfo struct {
+	ProcessGuid     string     `json:"process_guid"`
+	ContainerPort   uint32     `json:"container_port"`
+	Hostnames       []string   `json:"hostnames"`
+	LogGuid         string     `json:"log_guid"`
+	RouteServiceUrl string     `json:"route_service_url,omitempty"`
+	Endpoints       []Endpoint `json:"endpoints"`
+}
+
+// RoutesForHostname returns the routing keys with a route on the hostname,
+// including routes on a context path of the hostname.
+func (table *routingTable) RoutesForHostname(hostname string) []RouteInfo {
+	hostname, err := NormalizeHostname(hostname)
+	if err != nil {
+		return []RouteInfo{}
+	}
+
+	return table.lookup(table.indexes.keys(table.indexes.byHostname, hostname), func(entry RoutableEndpoints) (RoutableEndpoints, bool) {
+		for route := range entry.Routes {
+			if route.Hostname == hostname {
+				return entry, true
+			}
+		}
+		return entry, false
+	})
+}
+
+func (table *routingTable) RoutesForProcessGuid(processGuid string) []RouteInfo {
+	return table.lookup(table.indexes.keys(table.indexes.byProcessGuid, processGuid), func(entry RoutableEndpoints) (RoutableEndpoints, bool) {
+		return entry, true
+	})
+}
+
+// RoutesForInstanceGuid returns the routing keys served by the instance, with
+// only the endpoints of that instance.
+func (table *routingTable) RoutesForInstanceGuid(instanceGuid string) []RouteInfo {
+	return table.lookup(table.indexes.keys(table.indexes.byInstanceGuid, instanceGuid), func(entry RoutableEndpoints) (RoutableEndpoints, bool) {
+		return entry.filterEndpoints(func(endpoint Endpoint) bool {
+			return endpoint.InstanceGuid == instanceGuid
+		})
+	})
+}
+
+// RoutesForCell returns the routing keys served by instances on the cell, with
+// only the endpoints on that cell.
+func (table *routingTable) RoutesForCell(cellId string) []RouteInfo {
+	return table.lookup(table.indexes.keys(table.indexes.byCellId, cellId), func(entry RoutableEndpoints) (RoutableEndpoints, bool) {
+		return entry.filterEndpoints(func(endpoint Endpoint) bool {
+			return endpoint.CellId == cellId
+		})
+	})
+}
+
+// lookup reads the entries of the keys found in an index. The index may be
+// updated between finding the keys and reading the entries, so match
+// re-checks each entry and may narrow it down.
+func (table *routingTable) lookup(keys []RoutingKey, match func(RoutableEndpoints) (RoutableEndpoints, bool)) []RouteInfo {
+	infos := []RouteInfo{}
+
+	for _, key := range keys {
+		shard := table.shardFor(key)
+		shard.RLock()
+		entry, ok := shard.entries[key]
+		if ok {
+			entry, ok = match(entry)
+		}
+		if ok {
+			infos = append(infos, routeInfoFor(key, entry))
+		}
+		shard.RUnlock()
+	}
+
+	sort.Sort(routeInfosByRoutingKey(infos))
+	return infos
+}
+
+func (entry RoutableEndpoints) filterEndpoints(match func(Endpoint) bool) (RoutableEndpoints, bool) {
+	filtered := entry
+	filtered.Endpoints = map[EndpointKey]Endpoint{}
+
+	for key, endpoint := range entry.Endpoints {
+		if match(endpoint) {
+			filtered.Endpoints[key] = endpoint
+		}
+	}
+
+	return filtered, len(filtered.Endpoints) > 0
+}
+
+func routeInfoFor(key RoutingKey, entry RoutableEndpoints) RouteInfo {
+	info := RouteInfo{
+		ProcessGuid:     key.ProcessGuid,
+		ContainerPort:   key.ContainerPort,
+		Hostnames:       entry.routes().Hostnames,
+		LogGuid:         entry.LogGuid,
+		RouteServiceUrl: entry.RouteServiceUrl,
+		Endpoints:       make([]Endpoint, 0, len(entry.Endpoints)),
+	}
+
+	for _, endpoint := range entry.Endpoints {
+		info.Endpoints = append(info.Endpoints, endpoint)
+	}
+
+	sort.Strings(info.Hostnames)
+	sort.Sort(endpointsByInstanceGuid(info.Endpoints))
+	return info
+}
+
+// tableIndexes are the secondary indexes of a routing table, mapping hostnames,
+// process guids, instance guids and cell ids to the routing keys whose entries
+// mention them.
+type tableIndexes struct {
+	sync.RWMutex
+	byHostname     map[string]map[RoutingKey]struct{}
+	byProcessGuid  map[string]map[RoutingKey]struct{}
+	byInstanceGuid map[string]map[RoutingKey]struct{}
+	byCellId       map[string]map[RoutingKey]struct{}
+}
+
+func newTableIndexes() *tableIndexes {
+	return &tableIndexes{
+		byHostname:     map[string]map[RoutingKey]struct{}{},
+		byProcessGuid:  map[string]map[RoutingKey]struct{}{},
+		byInstanceGuid: map[string]map[RoutingKey]struct{}{},
+		byCellId:       map[string]map[RoutingKey]struct{}{},
+	}
+}
+
+func (indexes *tableIndexes) keys(index map[string]map[RoutingKey]struct{}, value string) []RoutingKey {
+	indexes.RLock()
+	defer indexes.RUnlock()
+
+	keys := make([]RoutingKey, 0, len(index[value]))
+	for key := range index[value] {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (indexes *tableIndexes) update(key RoutingKey, oldEntry, newEntry RoutableEndpoints) {
+	indexes.Lock()
+	defer indexes.Unlock()
+
+	indexes.remove(key, oldEntry)
+	indexes.add(key, newEntry)
+}
+
+// replace re-indexes a shard whose entries are swapped wholesale.
+func (indexes *tableIndexes) replace(oldEntries, newEntries map[RoutingKey]RoutableEndpoints) {
+	indexes.Lock()
+	defer indexes.Unlock()
+
+	for key, entry := range oldEntries {
+		indexes.remove(key, entry)
+	}
+	for key, entry := range newEntries {
+		indexes.add(key, entry)
+	}
+}
+
+func (indexes *tableIndexes) add(key RoutingKey, entry RoutableEndpoints) {
+	indexes.forEachValue(key, entry, func(index map[string]map[RoutingKey]struct{}, value string) {
+		keys, ok := index[value]
+		if !ok {
+			keys = map[RoutingKey]struct{}{}
+			index[value] = keys
+		}
+		keys[key] = struct{}{}
+	})
+}
+
+func (indexes *tableIndexes) remove(key RoutingKey, entry RoutableEndpoints) {
+	indexes.forEachValue(key, entry, func(index map[string]map[RoutingKey]struct{}, value string) {
+		delete(index[value], key)
+		if len(index[value]) == 0 {
+			delete(index, value)
+		}
+	})
+}
+
+func (indexes *tableIndexes) forEachValue(key RoutingKey, entry RoutableEndpoints, f func(map[string]map[RoutingKey]struct{}, string)) {
+	if len(entry.Routes) == 0 && len(entry.Endpoints) == 0 {
+		return
+	}
+
+	f(indexes.byProcessGuid, key.ProcessGuid)
+
+	for route := range entry.Routes {
+		f(indexes.byHostname, route.Hostname)
+	}
+
+	for _, endpoint := range entry.Endpoints {
+		f(indexes.byInstanceGuid, endpoint.InstanceGuid)
+		if endpoint.CellId != "" {
+			f(indexes.byCellId, endpoint.CellId)
+		}
+	}
+}
+
+type routeInfosByRoutingKey []RouteInfo
+
+func (infos routeInfosByRoutingKey) Len() int      { return len(infos) }
+func (infos routeInfosByRoutingKey) Swap(i, j int) { infos[i], infos[j] = infos[j], infos[i] }
+func (infos routeInfosByRoutingKey) Less(i, j int) bool {
+	if infos[i].ProcessGuid != infos[j].ProcessGuid {
+		return infos[i].ProcessGuid < infos[j].ProcessGuid
+	}
+	return infos[i].ContainerPort < infos[j].ContainerPort
+}
+
+type endpointsByInstanceGuid []Endpoint
+
+func (endpoints endpointsByInstanceGuid) Len() int { return len(endpoints) }
+func (endpoints endpointsByInstanceGuid) Swap(i, j int) {
+	endpoints[i], endpoints[j] = endpoints[j], endpoints[i]
+}
+func (endpoints endpointsByInstanceGuid) Less(i, j int) bool {
+	if endpoints[i].InstanceGuid != endpoints[j].InstanceGuid {
+		return endpoints[i].InstanceGuid < endpoints[j].InstanceGuid
+	}
+	return !endpoints[i].Evacuating && endpoints[j].Evacuating
+}
@@ -0,0 +1,133 @@
+package routing_table_test
+
+import (
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RoutingTable lookups", func() {
+	var table routing_table.RoutingTable
+
+	webKey := routing_table.RoutingKey{ProcessGuid: "web-process-guid", ContainerPort: 8080}
+	adminKey := routing_table.RoutingKey{ProcessGuid: "web-process-guid", ContainerPort: 9090}
+	workerKey := routing_table.RoutingKey{ProcessGuid: "worker-process-guid", ContainerPort: 8080}
+
+	webEndpoint := routing_table.Endpoint{InstanceGuid: "ig-1", Host: "1.1.1.1", Port: 11, ContainerPort: 8080, CellId: "cell-1"}
+	adminEndpoint := routing_table.Endpoint{InstanceGuid: "ig-1", Host: "1.1.1.1", Port: 12, ContainerPort: 9090, CellId: "cell-1"}
+	workerEndpoint1 := routing_table.Endpoint{InstanceGuid: "ig-2", Host: "2.2.2.2", Port: 21, ContainerPort: 8080, CellId: "cell-1"}
+	workerEndpoint2 := routing_table.Endpoint{InstanceGuid: "ig-3", Host: "3.3.3.3", Port: 31, ContainerPort: 8080, CellId: "cell-2"}
+
+	BeforeEach(func() {
+		table = routing_table.NewTable()
+
+		table.SetRoutes(webKey, routing_table.Routes{Hostnames: []string{"foo.example.com", "bar.example.com/api"}, LogGuid: "web-log-guid"})
+		table.SetRoutes(adminKey, routing_table.Routes{Hostnames: []string{"bar.example.com"}, LogGuid: "web-log-guid"})
+		table.SetRoutes(workerKey, routing_table.Routes{Hostnames: []string{"worker.example.com"}, LogGuid: "worker-log-guid"})
+
+		table.AddEndpoint(webKey, webEndpoint)
+		table.AddEndpoint(adminKey, adminEndpoint)
+		table.AddEndpoint(workerKey, workerEndpoint1)
+		table.AddEndpoint(workerKey, workerEndpoint2)
+	})
+
+	Describe("RoutesForHostname", func() {
+		It("returns the routing keys routing the hostname, including on context paths", func() {
+			Expect(table.RoutesForHostname("bar.example.com")).To(Equal([]routing_table.RouteInfo{
+				{
+					ProcessGuid:   webKey.ProcessGuid,
+					ContainerPort: webKey.ContainerPort,
+					Hostnames:     []string{"bar.example.com/api", "foo.example.com"},
+					LogGuid:       "web-log-guid",
+					Endpoints:     []routing_table.Endpoint{webEndpoint},
+				},
+				{
+					ProcessGuid:   adminKey.ProcessGuid,
+					ContainerPort: adminKey.ContainerPort,
+					Hostnames:     []string{"bar.example.com"},
+					LogGuid:       "web-log-guid",
+					Endpoints:     []routing_table.Endpoint{adminEndpoint},
+				},
+			}))
+		})
+
+		It("normalizes the hostname", func() {
+			Expect(table.RoutesForHostname("Foo.Example.com.")).To(HaveLen(1))
+		})
+
+		It("returns nothing for an unknown or invalid hostname", func() {
+			Expect(table.RoutesForHostname("unknown.example.com")).To(BeEmpty())
+			Expect(table.RoutesForHostname("foo_bar")).To(BeEmpty())
+		})
+
+		It("no longer returns routing keys whose routes have changed", func() {
+			table.SetRoutes(adminKey, routing_table.Routes{Hostnames: []string{"admin.example.com"}, LogGuid: "web-log-guid"})
+
+			infos := table.RoutesForHostname("bar.example.com")
+			Expect(infos).To(HaveLen(1))
+			Expect(infos[0].ContainerPort).To(Equal(webKey.ContainerPort))
+		})
+	})
+
+	Describe("RoutesForProcessGuid", func() {
+		It("returns every routing key of the process guid", func() {
+			infos := table.RoutesForProcessGuid("web-process-guid")
+			Expect(infos).To(HaveLen(2))
+			Expect(infos[0].ContainerPort).To(Equal(webKey.ContainerPort))
+			Expect(infos[1].ContainerPort).To(Equal(adminKey.ContainerPort))
+		})
+	})
+
+	Describe("RoutesForInstanceGuid", func() {
+		It("returns the routing keys served by the instance, with only its endpoints", func() {
+			Expect(table.RoutesForInstanceGuid("ig-3")).To(Equal([]routing_table.RouteInfo{
+				{
+					ProcessGuid:   workerKey.ProcessGuid,
+					ContainerPort: workerKey.ContainerPort,
+					Hostnames:     []string{"worker.example.com"},
+					LogGuid:       "worker-log-guid",
+					Endpoints:     []routing_table.Endpoint{workerEndpoint2},
+				},
+			}))
+		})
+
+		It("no longer returns routing keys once the instance's endpoints are removed", func() {
+			table.RemoveEndpoint(workerKey, workerEndpoint2)
+			Expect(table.RoutesForInstanceGuid("ig-3")).To(BeEmpty())
+		})
+	})
+
+	Describe("RoutesForCell", func() {
+		It("returns the routing keys served by instances on the cell, with only their endpoints", func() {
+			infos := table.RoutesForCell("cell-1")
+			Expect(infos).To(HaveLen(3))
+			Expect(infos[2].ProcessGuid).To(Equal(workerKey.ProcessGuid))
+			Expect(infos[2].Endpoints).To(Equal([]routing_table.Endpoint{workerEndpoint1}))
+		})
+	})
+
+	Context("after a sync", func() {
+		BeforeEach(func() {
+			tempTable := routing_table.NewTempTable(
+				routing_table.RoutesByRoutingKey{workerKey: routing_table.Routes{Hostnames: []string{"worker.example.com"}, LogGuid: "worker-log-guid"}},
+				routing_table.EndpointsByRoutingKey{workerKey: {workerEndpoint2}},
+			)
+			table.Swap(tempTable)
+		})
+
+		It("reflects the swapped in entries", func() {
+			Expect(table.RoutesForProcessGuid("web-process-guid")).To(BeEmpty())
+			Expect(table.RoutesForHostname("foo.example.com")).To(BeEmpty())
+			Expect(table.RoutesForCell("cell-1")).To(BeEmpty())
+			Expect(table.RoutesForCell("cell-2")).To(HaveLen(1))
+		})
+	})
+
+	Context("when the table is restored from a snapshot", func() {
+		It("indexes the restored entries", func() {
+			restored := routing_table.NewTableFromSnapshot(table.Snapshot(), routing_table.TableOptions{})
+			Expect(restored.RoutesForCell("cell-1")).To(Equal(table.RoutesForCell("cell-1")))
+		})
+	})
+})
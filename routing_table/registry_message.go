@@ -1,5 +1,33 @@
 package routing_table
 
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudfoundry-incubator/bbs/models"
+)
+
+// RegistryMessageVersion selects the layout of registry messages. Version 2
+// adds freshness metadata that lets routers tell a stale re-emission from a
+// newer update; its fields are omitted from version 1 messages, so version 1
+// consumers can read either.
+type RegistryMessageVersion int
+
+const (
+	RegistryMessageV1 RegistryMessageVersion = 1
+	RegistryMessageV2 RegistryMessageVersion = 2
+)
+
+func ParseRegistryMessageVersion(version int) (RegistryMessageVersion, error) {
+	switch v := RegistryMessageVersion(version); v {
+	case RegistryMessageV1, RegistryMessageV2:
+		return v, nil
+	case 0:
+		return RegistryMessageV1, nil
+	}
+	return 0, fmt.Errorf("unknown registry message version: %d", version)
+}
+
 type RegistryMessage struct {
 	Host              string   `json:"host"`
 	Port              uint32   `json:"port"`
@@ -7,10 +35,39 @@ type RegistryMessage struct {
 	App               string   `json:"app,omitempty"`
 	RouteServiceUrl   string   `json:"route_service_url,omitempty"`
 	PrivateInstanceId string   `json:"private_instance_id,omitempty"`
+
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// Version is only set on version 2 messages, along with the fields below.
+	Version RegistryMessageVersion `json:"version,omitempty"`
+	// ModificationTag is the modification tag of the actual LRP behind the
+	// endpoint, when it is known.
+	ModificationTag *models.ModificationTag `json:"modification_tag,omitempty"`
+	// StaleThresholdInSeconds is how long the router should keep the route
+	// without hearing from the emitter again.
+	StaleThresholdInSeconds int `json:"stale_threshold_in_seconds,omitempty"`
+	// EndpointUpdatedAt is when the actual LRP behind the endpoint last
+	// changed, in nanoseconds since the Unix epoch, when it is known.
+	EndpointUpdatedAt int64 `json:"endpoint_updated_at,omitempty"`
+}
+
+// RegistryMessageOptions controls the optional content of registry messages.
+type RegistryMessageOptions struct {
+	Tags           []EndpointTag
+	Version        RegistryMessageVersion
+	StaleThreshold time.Duration
 }
 
 func RegistryMessageFor(endpoint Endpoint, routes Routes) RegistryMessage {
-	return RegistryMessage{
+	return RegistryMessageWithTagsFor(endpoint, routes, nil)
+}
+
+func RegistryMessageWithTagsFor(endpoint Endpoint, routes Routes, tags []EndpointTag) RegistryMessage {
+	return RegistryMessageWithOptionsFor(endpoint, routes, RegistryMessageOptions{Tags: tags})
+}
+
+func RegistryMessageWithOptionsFor(endpoint Endpoint, routes Routes, options RegistryMessageOptions) RegistryMessage {
+	message := RegistryMessage{
 		URIs: routes.Hostnames,
 		Host: endpoint.Host,
 		Port: endpoint.Port,
@@ -18,10 +75,25 @@ func RegistryMessageFor(endpoint Endpoint, routes Routes) RegistryMessage {
 
 		PrivateInstanceId: endpoint.InstanceGuid,
 		RouteServiceUrl:   routes.RouteServiceUrl,
+
+		Tags: endpointTagsFor(endpoint, options.Tags),
+	}
+
+	if options.Version == RegistryMessageV2 {
+		message.Version = RegistryMessageV2
+		message.ModificationTag = endpoint.ModificationTag
+		message.StaleThresholdInSeconds = int(options.StaleThreshold / time.Second)
+		message.EndpointUpdatedAt = endpoint.Since
 	}
+
+	return message
 }
 
+// RouterGreetingMessage is sent by a router in router.start messages and in
+// replies to router.greet. Id and Hosts identify the router, if it sends them.
 type RouterGreetingMessage struct {
-	MinimumRegisterInterval int `json:"minimumRegisterIntervalInSeconds"`
-	PruneThresholdInSeconds int `json:"pruneThresholdInSeconds"`
+	Id                      string   `json:"id,omitempty"`
+	Hosts                   []string `json:"hosts,omitempty"`
+	MinimumRegisterInterval int      `json:"minimumRegisterIntervalInSeconds"`
+	PruneThresholdInSeconds int      `json:"pruneThresholdInSeconds"`
 }
@@ -2,6 +2,9 @@ package routing_table_test
 
 import (
 	"encoding/json"
+	"time"
+
+	"github.com/cloudfoundry-incubator/bbs/models"
 
 	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
 
@@ -71,4 +74,178 @@ var _ = Describe("RegistryMessage", func() {
 			Expect(message).To(Equal(expectedMessage))
 		})
 	})
+
+	Describe("RegistryMessageWithTagsFor", func() {
+		var (
+			endpoint routing_table.Endpoint
+			routes   routing_table.Routes
+		)
+
+		BeforeEach(func() {
+			endpoint = routing_table.Endpoint{
+				InstanceGuid:     "instance-guid",
+				Host:             "1.1.1.1",
+				Port:             61001,
+				ContainerPort:    11,
+				Evacuating:       true,
+				ProcessGuid:      "process-guid",
+				Index:            2,
+				Domain:           "domain",
+				CellId:           "cell-id",
+				AvailabilityZone: "z1",
+			}
+			routes = routing_table.Routes{
+				Hostnames:       []string{"host-1.example.com", "host-2.example.com"},
+				LogGuid:         "app-guid",
+				RouteServiceUrl: "https://hello.com",
+			}
+		})
+
+		It("includes every requested tag", func() {
+			message := routing_table.RegistryMessageWithTagsFor(endpoint, routes, routing_table.AllEndpointTags)
+
+			expectedMessage.Tags = map[string]string{
+				"instance_index":    "2",
+				"cell_id":           "cell-id",
+				"domain":            "domain",
+				"process_guid":      "process-guid",
+				"evacuating":        "true",
+				"availability_zone": "z1",
+			}
+			Expect(message).To(Equal(expectedMessage))
+		})
+
+		It("includes only the requested tags", func() {
+			message := routing_table.RegistryMessageWithTagsFor(endpoint, routes, []routing_table.EndpointTag{routing_table.CellIdTag})
+
+			Expect(message.Tags).To(Equal(map[string]string{"cell_id": "cell-id"}))
+		})
+
+		It("omits the availability zone when it is unknown", func() {
+			endpoint.AvailabilityZone = ""
+			message := routing_table.RegistryMessageWithTagsFor(endpoint, routes, []routing_table.EndpointTag{routing_table.AvailabilityZoneTag, routing_table.InstanceIndexTag})
+
+			Expect(message.Tags).To(Equal(map[string]string{"instance_index": "2"}))
+		})
+
+		It("serializes the tags", func() {
+			message := routing_table.RegistryMessageWithTagsFor(endpoint, routes, []routing_table.EndpointTag{routing_table.ProcessGuidTag})
+
+			payload, err := json.Marshal(message)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(payload).To(MatchJSON(`{
+				"host": "1.1.1.1",
+				"port": 61001,
+				"uris": ["host-1.example.com", "host-2.example.com"],
+				"app" : "app-guid",
+				"private_instance_id": "instance-guid",
+				"route_service_url": "https://hello.com",
+				"tags": {"process_guid": "process-guid"}
+			}`))
+		})
+
+		It("does not include tags when none are requested", func() {
+			message := routing_table.RegistryMessageWithTagsFor(endpoint, routes, nil)
+			Expect(message).To(Equal(expectedMessage))
+		})
+	})
+
+	Describe("RegistryMessageWithOptionsFor", func() {
+		var (
+			endpoint routing_table.Endpoint
+			routes   routing_table.Routes
+		)
+
+		BeforeEach(func() {
+			endpoint = routing_table.Endpoint{
+				InstanceGuid:    "instance-guid",
+				Host:            "1.1.1.1",
+				Port:            61001,
+				ContainerPort:   11,
+				ModificationTag: &models.ModificationTag{Epoch: "abc", Index: 3},
+				Since:           1444000000000000000,
+			}
+			routes = routing_table.Routes{
+				Hostnames:       []string{"host-1.example.com", "host-2.example.com"},
+				LogGuid:         "app-guid",
+				RouteServiceUrl: "https://hello.com",
+			}
+		})
+
+		It("builds a version 1 message by default", func() {
+			message := routing_table.RegistryMessageWithOptionsFor(endpoint, routes, routing_table.RegistryMessageOptions{
+				StaleThreshold: 2 * time.Minute,
+			})
+			Expect(message).To(Equal(expectedMessage))
+		})
+
+		It("includes the freshness metadata in version 2 messages", func() {
+			message := routing_table.RegistryMessageWithOptionsFor(endpoint, routes, routing_table.RegistryMessageOptions{
+				Version:        routing_table.RegistryMessageV2,
+				StaleThreshold: 2 * time.Minute,
+			})
+
+			payload, err := json.Marshal(message)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(payload).To(MatchJSON(`{
+				"host": "1.1.1.1",
+				"port": 61001,
+				"uris": ["host-1.example.com", "host-2.example.com"],
+				"app" : "app-guid",
+				"private_instance_id": "instance-guid",
+				"route_service_url": "https://hello.com",
+				"version": 2,
+				"modification_tag": {"epoch": "abc", "index": 3},
+				"stale_threshold_in_seconds": 120,
+				"endpoint_updated_at": 1444000000000000000
+			}`))
+		})
+
+		It("omits the modification tag and update time when they are unknown", func() {
+			endpoint.ModificationTag = nil
+			endpoint.Since = 0
+
+			message := routing_table.RegistryMessageWithOptionsFor(endpoint, routes, routing_table.RegistryMessageOptions{
+				Version: routing_table.RegistryMessageV2,
+			})
+
+			payload, err := json.Marshal(message)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(payload).NotTo(ContainSubstring("modification_tag"))
+			Expect(payload).NotTo(ContainSubstring("endpoint_updated_at"))
+		})
+
+		It("can be read by version 1 consumers", func() {
+			message := routing_table.RegistryMessageWithOptionsFor(endpoint, routes, routing_table.RegistryMessageOptions{
+				Version: routing_table.RegistryMessageV2,
+			})
+			payload, err := json.Marshal(message)
+			Expect(err).NotTo(HaveOccurred())
+
+			v1Message := struct {
+				Host string   `json:"host"`
+				Port uint32   `json:"port"`
+				URIs []string `json:"uris"`
+			}{}
+			err = json.Unmarshal(payload, &v1Message)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v1Message.URIs).To(Equal(routes.Hostnames))
+		})
+	})
+
+	Describe("ParseRegistryMessageVersion", func() {
+		It("parses the known versions", func() {
+			Expect(routing_table.ParseRegistryMessageVersion(1)).To(Equal(routing_table.RegistryMessageV1))
+			Expect(routing_table.ParseRegistryMessageVersion(2)).To(Equal(routing_table.RegistryMessageV2))
+		})
+
+		It("defaults to version 1", func() {
+			Expect(routing_table.ParseRegistryMessageVersion(0)).To(Equal(routing_table.RegistryMessageV1))
+		})
+
+		It("rejects unknown versions", func() {
+			_, err := routing_table.ParseRegistryMessageVersion(3)
+			Expect(err).To(MatchError("unknown registry message version: 3"))
+		})
+	})
 })
@@ -0,0 +1,108 @@
+package routing_table
+
+import (
+	"path"
+	"strings"
+)
+
+// Route is a hostname optionally qualified by a context path, e.g.
+// "example.com/api". A route without a path has an empty Path.
+type Route struct {
+	Hostname string
+	Path     string
+}
+
+// NewRoute parses a route URI of the form "hostname[/path]". The path is
+// normalized with NormalizePath.
+func NewRoute(uri string) Route {
+	slash := strings.Index(uri, "/")
+	if slash < 0 {
+		return Route{Hostname: uri}
+	}
+
+	return Route{
+		Hostname: uri[:slash],
+		Path:     NormalizePath(uri[slash:]),
+	}
+}
+
+// URI returns the route as it appears in the uris of a registry message.
+func (r Route) URI() string {
+	return r.Hostname + r.Path
+}
+
+// NormalizePath canonicalizes a context path so that equivalent spellings
+// produce the same route:
+//
+//   - the path always has a single leading slash and no trailing slash,
+//     and the root path "/" normalizes to the empty path
+//   - repeated slashes and "." and ".." segments are resolved
+//   - percent-encodings of unreserved characters (RFC 3986, section 2.3)
+//     are decoded, and the hex digits of any remaining encodings are
+//     upper-cased; an encoded "/" is therefore not treated as a separator
+//   - case is otherwise preserved, as paths are case-sensitive
+//
+// Malformed percent-encodings are left untouched.
+func NormalizePath(p string) string {
+	p = normalizePercentEncoding(p)
+	p = path.Clean("/" + p)
+	if p == "/" {
+		return ""
+	}
+	return p
+}
+
+func normalizePercentEncoding(p string) string {
+	if !strings.Contains(p, "%") {
+		return p
+	}
+
+	normalized := make([]byte, 0, len(p))
+	for i := 0; i < len(p); i++ {
+		if p[i] != '%' || i+2 >= len(p) {
+			normalized = append(normalized, p[i])
+			continue
+		}
+
+		hi, hiOk := unhex(p[i+1])
+		lo, loOk := unhex(p[i+2])
+		if !hiOk || !loOk {
+			normalized = append(normalized, p[i])
+			continue
+		}
+
+		c := hi<<4 | lo
+		if isUnreserved(c) {
+			normalized = append(normalized, c)
+		} else {
+			normalized = append(normalized, '%', upperHex[hi], upperHex[lo])
+		}
+		i += 2
+	}
+
+	return string(normalized)
+}
+
+const upperHex = "0123456789ABCDEF"
+
+func unhex(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+func isUnreserved(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	case c == '-', c == '.', c == '_', c == '~':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package routing_table_test
+
+import (
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Route", func() {
+	Describe("NewRoute", func() {
+		It("parses a bare hostname", func() {
+			route := routing_table.NewRoute("foo.example.com")
+			Expect(route).To(Equal(routing_table.Route{Hostname: "foo.example.com"}))
+			Expect(route.URI()).To(Equal("foo.example.com"))
+		})
+
+		It("parses a hostname with a context path", func() {
+			route := routing_table.NewRoute("foo.example.com/api/v1")
+			Expect(route).To(Equal(routing_table.Route{Hostname: "foo.example.com", Path: "/api/v1"}))
+			Expect(route.URI()).To(Equal("foo.example.com/api/v1"))
+		})
+
+		It("normalizes the context path", func() {
+			route := routing_table.NewRoute("foo.example.com//api/")
+			Expect(route.URI()).To(Equal("foo.example.com/api"))
+		})
+
+		It("treats a root path as no path", func() {
+			route := routing_table.NewRoute("foo.example.com/")
+			Expect(route).To(Equal(routing_table.Route{Hostname: "foo.example.com"}))
+		})
+	})
+
+	Describe("NormalizePath", func() {
+		It("leaves an empty path empty", func() {
+			Expect(routing_table.NormalizePath("")).To(Equal(""))
+		})
+
+		It("treats a root path as no path", func() {
+			Expect(routing_table.NormalizePath("/")).To(Equal(""))
+		})
+
+		It("keeps a simple path", func() {
+			Expect(routing_table.NormalizePath("/api")).To(Equal("/api"))
+		})
+
+		It("adds a missing leading slash", func() {
+			Expect(routing_table.NormalizePath("api")).To(Equal("/api"))
+		})
+
+		It("strips trailing slashes", func() {
+			Expect(routing_table.NormalizePath("/api/")).To(Equal("/api"))
+		})
+
+		It("collapses repeated slashes", func() {
+			Expect(routing_table.NormalizePath("//api///v1//")).To(Equal("/api/v1"))
+		})
+
+		It("resolves dot segments", func() {
+			Expect(routing_table.NormalizePath("/api/./v1/../v2")).To(Equal("/api/v2"))
+		})
+
+		It("preserves case", func() {
+			Expect(routing_table.NormalizePath("/API/Users")).To(Equal("/API/Users"))
+		})
+
+		It("decodes percent-encoded unreserved characters", func() {
+			Expect(routing_table.NormalizePath("/%61pi/%7Euser")).To(Equal("/api/~user"))
+		})
+
+		It("upper-cases the hex digits of remaining encodings", func() {
+			Expect(routing_table.NormalizePath("/a%2fb%3a")).To(Equal("/a%2Fb%3A"))
+		})
+
+		It("does not treat an encoded slash as a separator", func() {
+			Expect(routing_table.NormalizePath("/a%2F/")).To(Equal("/a%2F"))
+		})
+
+		It("leaves malformed encodings untouched", func() {
+			Expect(routing_table.NormalizePath("/a%zz/b%4")).To(Equal("/a%zz/b%4"))
+		})
+	})
+})
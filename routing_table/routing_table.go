@@ -1,9 +1,10 @@
 package routing_table
 
 import (
-	"sync"
+	"time"
 
 	"github.com/cloudfoundry-incubator/bbs/models"
+	"github.com/pivotal-golang/lager"
 )
 
 //go:generate counterfeiter -o fake_routing_table/fake_routing_table.go . RoutingTable
@@ -11,6 +12,7 @@ type RoutingTable interface {
 	RouteCount() int
 
 	Swap(newTable RoutingTable) MessagesToEmit
+	Diff(newTable RoutingTable) MessagesToEmit
 
 	SetRoutes(key RoutingKey, routes Routes) MessagesToEmit
 	RemoveRoutes(key RoutingKey, modTag *models.ModificationTag) MessagesToEmit
@@ -18,31 +20,85 @@ type RoutingTable interface {
 	RemoveEndpoint(key RoutingKey, endpoint Endpoint) MessagesToEmit
 
 	MessagesToEmit() MessagesToEmit
+
+	Snapshot() Snapshot
+
+	Conflicts() []HostnameConflict
+	RejectedRoutes() []RejectedRoutes
+
+	RoutesForHostname(hostname string) []RouteInfo
+	RoutesForProcessGuid(processGuid string) []RouteInfo
+	RoutesForInstanceGuid(instanceGuid string) []RouteInfo
+	RoutesForCell(cellId string) []RouteInfo
 }
 
 type noopLocker struct{}
 
-func (noopLocker) Lock()   {}
-func (noopLocker) Unlock() {}
+func (noopLocker) Lock()    {}
+func (noopLocker) Unlock()  {}
+func (noopLocker) RLock()   {}
+func (noopLocker) RUnlock() {}
 
+// routingTable partitions its entries into shards by process guid, each with
+// its own lock, so that walking the table does not block updates to other
+// shards. Operations spanning the whole table visit one shard at a time.
 type routingTable struct {
-	entries map[RoutingKey]RoutableEndpoints
-	sync.Locker
+	shards         []*tableShard
+	indexes        *tableIndexes
 	messageBuilder MessageBuilder
+
+	owners         *hostnameOwners
+	conflictPolicy ConflictPolicy
+	rejected       *rejectedHostnames
+	logger         lager.Logger
 }
 
+type TableOptions struct {
+	EndpointTags   []EndpointTag
+	ConflictPolicy ConflictPolicy
+
+	// MessageVersion selects the layout of registry messages and defaults to
+	// RegistryMessageV1. StaleThreshold is advertised in version 2 messages.
+	// It is configured rather than taken from the router greetings: every
+	// router receives the same messages but greets with its own prune
+	// threshold, and the table builds messages before any router has greeted
+	// the emitter.
+	MessageVersion RegistryMessageVersion
+	StaleThreshold time.Duration
+
+	// Logger receives hostname conflicts and rejected hostnames. It defaults
+	// to a logger without sinks.
+	Logger lager.Logger
+}
+
+// NewTempTable builds a table to be passed to Swap or Diff. Its secondary
+// indexes are not populated.
 func NewTempTable(routes RoutesByRoutingKey, endpoints EndpointsByRoutingKey) RoutingTable {
-	entries := make(map[RoutingKey]RoutableEndpoints)
+	table := &routingTable{
+		shards:         newShards(newNoopLocker),
+		indexes:        newTableIndexes(),
+		messageBuilder: NoopMessageBuilder{},
+		owners:         newHostnameOwners(),
+		conflictPolicy: ConflictPolicyAllow,
+		rejected:       newRejectedHostnames(),
+		logger:         lager.NewLogger("routing-table"),
+	}
 
 	for key, entry := range routes {
-		entries[key] = RoutableEndpoints{
-			Hostnames:       routesAsMap(entry.Hostnames),
+		routes, rejected := normalizeRoutes(entry.Hostnames)
+		if len(rejected) > 0 {
+			table.rejected.byKey[key] = rejected
+		}
+
+		table.shardFor(key).entries[key] = RoutableEndpoints{
+			Routes:          routes,
 			LogGuid:         entry.LogGuid,
 			RouteServiceUrl: entry.RouteServiceUrl,
 		}
 	}
 
 	for key, endpoints := range endpoints {
+		entries := table.shardFor(key).entries
 		entry, ok := entries[key]
 		if !ok {
 			entry = RoutableEndpoints{}
@@ -51,30 +107,57 @@ func NewTempTable(routes RoutesByRoutingKey, endpoints EndpointsByRoutingKey) Ro
 		entries[key] = entry
 	}
 
-	return &routingTable{
-		entries:        entries,
-		Locker:         noopLocker{},
-		messageBuilder: NoopMessageBuilder{},
-	}
+	return table
 }
 
 func NewTable() RoutingTable {
+	return NewTableWithEndpointTags(nil)
+}
+
+func NewTableWithEndpointTags(endpointTags []EndpointTag) RoutingTable {
+	return NewTableWithOptions(TableOptions{EndpointTags: endpointTags})
+}
+
+func NewTableWithOptions(options TableOptions) RoutingTable {
+	conflictPolicy := options.ConflictPolicy
+	if conflictPolicy == "" {
+		conflictPolicy = ConflictPolicyAllow
+	}
+
+	logger := options.Logger
+	if logger == nil {
+		logger = lager.NewLogger("routing-table")
+	}
+
 	return &routingTable{
-		entries:        make(map[RoutingKey]RoutableEndpoints),
-		Locker:         &sync.Mutex{},
-		messageBuilder: MessagesToEmitBuilder{},
+		shards:  newShards(newRWMutex),
+		indexes: newTableIndexes(),
+		messageBuilder: MessagesToEmitBuilder{
+			EndpointTags:   options.EndpointTags,
+			MessageVersion: options.MessageVersion,
+			StaleThreshold: options.StaleThreshold,
+		},
+		owners:         newHostnameOwners(),
+		conflictPolicy: conflictPolicy,
+		rejected:       newRejectedHostnames(),
+		logger:         logger.Session("routing-table"),
 	}
 }
 
-func (table *routingTable) RouteCount() int {
-	table.Lock()
+func (table *routingTable) shardFor(key RoutingKey) *tableShard {
+	return table.shards[shardIndex(key)]
+}
 
+func (table *routingTable) RouteCount() int {
 	count := 0
-	for _, entry := range table.entries {
-		count += len(entry.Hostnames)
+	for _, shard := range table.shards {
+		shard.RLock()
+		for _, entry := range shard.entries {
+			count += len(entry.Routes)
+		}
+		shard.RUnlock()
 	}
 
-	table.Unlock()
 	return count
 }
 
@@ -85,91 +168,162 @@ func (table *routingTable) Swap(t RoutingTable) MessagesToEmit {
 	if !ok {
 		return messagesToEmit
 	}
-	newEntries := newTable.entries
 
-	table.Lock()
-	for _, newEntry := range newEntries {
-		//always register everything on sync
-		messagesToEmit = messagesToEmit.merge(table.messageBuilder.RegistrationsFor(nil, &newEntry))
+	for key, rejected := range table.rejected.replace(newTable.rejected.byKey) {
+		table.reportRejected(key, rejected)
 	}
 
-	for key, existingEntry := range table.entries {
-		newEntry := newEntries[key]
-		messagesToEmit = messagesToEmit.merge(table.messageBuilder.UnregistrationsFor(&existingEntry, &newEntry))
+	claimsByKey := newTable.hostnameClaims()
+	claims, conflicts := table.owners.replace(claimsByKey)
+	table.reportConflicts(conflicts)
+	if table.conflictPolicy == ConflictPolicyFirstOwnerWins {
+		newTable.removeDeniedHostnames(claims, claimsByKey)
 	}
 
-	table.entries = newEntries
-	table.Unlock()
+	for i, shard := range table.shards {
+		newEntries := newTable.shards[i].entries
+
+		shard.Lock()
+		for _, newEntry := range newEntries {
+			//always register everything on sync
+			messagesToEmit = messagesToEmit.merge(table.messageBuilder.RegistrationsFor(nil, &newEntry))
+		}
+
+		for key, existingEntry := range shard.entries {
+			newEntry := newEntries[key]
+			messagesToEmit = messagesToEmit.merge(table.messageBuilder.UnregistrationsFor(&existingEntry, &newEntry))
+		}
+
+		table.indexes.replace(shard.entries, newEntries)
+		shard.entries = newEntries
+		shard.Unlock()
+	}
 
 	return messagesToEmit
 }
 
-func (table *routingTable) MessagesToEmit() MessagesToEmit {
-	table.Lock()
+// Diff reports how the table differs from newTable without modifying it: the
+// registrations missing from the table and the unregistrations Swap would
+// emit. Unlike Swap, it does not re-register routes that are unchanged.
+func (table *routingTable) Diff(t RoutingTable) MessagesToEmit {
+	messagesToEmit := MessagesToEmit{}
+
+	newTable, ok := t.(*routingTable)
+	if !ok {
+		return messagesToEmit
+	}
+
+	if table.conflictPolicy == ConflictPolicyFirstOwnerWins {
+		claimsByKey := newTable.hostnameClaims()
+		newTable.removeDeniedHostnames(table.owners.resolve(claimsByKey), claimsByKey)
+	}
 
+	for i, shard := range table.shards {
+		newEntries := newTable.shards[i].entries
+
+		shard.RLock()
+		for key, newEntry := range newEntries {
+			existingEntry := shard.entries[key]
+			messagesToEmit = messagesToEmit.merge(table.messageBuilder.RegistrationsFor(&existingEntry, &newEntry))
+		}
+
+		for key, existingEntry := range shard.entries {
+			newEntry := newEntries[key]
+			messagesToEmit = messagesToEmit.merge(table.messageBuilder.UnregistrationsFor(&existingEntry, &newEntry))
+		}
+		shard.RUnlock()
+	}
+
+	return messagesToEmit
+}
+
+func (table *routingTable) MessagesToEmit() MessagesToEmit {
 	messagesToEmit := MessagesToEmit{}
-	for _, entry := range table.entries {
-		messagesToEmit = messagesToEmit.merge(table.messageBuilder.RegistrationsFor(nil, &entry))
+
+	for _, shard := range table.shards {
+		shard.RLock()
+		for _, entry := range shard.entries {
+			messagesToEmit = messagesToEmit.merge(table.messageBuilder.RegistrationsFor(nil, &entry))
+		}
+		shard.RUnlock()
 	}
 
-	table.Unlock()
 	return messagesToEmit
 }
 
 func (table *routingTable) SetRoutes(key RoutingKey, routes Routes) MessagesToEmit {
-	table.Lock()
-	defer table.Unlock()
+	messagesToEmit, successors := table.setRoutes(key, routes)
+	return messagesToEmit.merge(table.handOver(successors))
+}
+
+func (table *routingTable) setRoutes(key RoutingKey, routes Routes) (MessagesToEmit, []RoutingKey) {
+	shard := table.shardFor(key)
+	shard.Lock()
+	defer shard.Unlock()
 
-	currentEntry := table.entries[key]
+	currentEntry := shard.entries[key]
 	if !currentEntry.ModificationTag.SucceededBy(routes.ModificationTag) {
-		return MessagesToEmit{}
+		return MessagesToEmit{}, nil
 	}
 
 	newEntry := currentEntry.copy()
-	newEntry.Hostnames = routesAsMap(routes.Hostnames)
+	claimed, successors := table.claimRoutes(key, table.normalizeRoutes(key, routes.Hostnames))
+	newEntry.Routes = claimed
 	newEntry.LogGuid = routes.LogGuid
 	newEntry.ModificationTag = routes.ModificationTag
 	newEntry.RouteServiceUrl = routes.RouteServiceUrl
 
-	table.entries[key] = newEntry
+	table.store(shard, key, currentEntry, newEntry)
 
-	return table.emit(key, currentEntry, newEntry)
+	return table.emit(key, currentEntry, newEntry), successors
 }
 
 func (table *routingTable) RemoveRoutes(key RoutingKey, modTag *models.ModificationTag) MessagesToEmit {
-	table.Lock()
-	defer table.Unlock()
+	messagesToEmit, successors := table.removeRoutes(key, modTag)
+	return messagesToEmit.merge(table.handOver(successors))
+}
+
+func (table *routingTable) removeRoutes(key RoutingKey, modTag *models.ModificationTag) (MessagesToEmit, []RoutingKey) {
+	shard := table.shardFor(key)
+	shard.Lock()
+	defer shard.Unlock()
 
-	currentEntry := table.entries[key]
+	currentEntry := shard.entries[key]
 	if !(currentEntry.ModificationTag.Equal(modTag) || currentEntry.ModificationTag.SucceededBy(modTag)) {
-		return MessagesToEmit{}
+		return MessagesToEmit{}, nil
 	}
 
-	newEntry := NewRoutableEndpoints()
-	newEntry.Endpoints = currentEntry.Endpoints
+	_, _, successors := table.owners.claim(key, nil)
+	table.rejected.set(key, nil)
 
-	table.entries[key] = currentEntry
+	newEntry := currentEntry.copy()
+	newEntry.Routes = map[Route]struct{}{}
+	newEntry.LogGuid = ""
+	newEntry.RouteServiceUrl = ""
+	table.store(shard, key, currentEntry, newEntry)
 
-	return table.emit(key, currentEntry, newEntry)
+	return table.emit(key, currentEntry, newEntry), successors
 }
 
 func (table *routingTable) AddEndpoint(key RoutingKey, endpoint Endpoint) MessagesToEmit {
-	table.Lock()
-	defer table.Unlock()
+	shard := table.shardFor(key)
+	shard.Lock()
+	defer shard.Unlock()
 
-	currentEntry := table.entries[key]
+	currentEntry := shard.entries[key]
 	newEntry := currentEntry.copy()
 	newEntry.Endpoints[endpoint.key()] = endpoint
-	table.entries[key] = newEntry
+	table.store(shard, key, currentEntry, newEntry)
 
 	return table.emit(key, currentEntry, newEntry)
 }
 
 func (table *routingTable) RemoveEndpoint(key RoutingKey, endpoint Endpoint) MessagesToEmit {
-	table.Lock()
-	defer table.Unlock()
+	shard := table.shardFor(key)
+	shard.Lock()
+	defer shard.Unlock()
 
-	currentEntry := table.entries[key]
+	currentEntry := shard.entries[key]
 	endpointKey := endpoint.key()
 	currentEndpoint, ok := currentEntry.Endpoints[endpointKey]
 	if !ok || !(currentEndpoint.ModificationTag.Equal(endpoint.ModificationTag) || currentEndpoint.ModificationTag.SucceededBy(endpoint.ModificationTag)) {
@@ -178,14 +332,178 @@ func (table *routingTable) RemoveEndpoint(key RoutingKey, endpoint Endpoint) Mes
 
 	newEntry := currentEntry.copy()
 	delete(newEntry.Endpoints, endpointKey)
-	table.entries[key] = newEntry
+	table.store(shard, key, currentEntry, newEntry)
 
 	return table.emit(key, currentEntry, newEntry)
 }
 
+// store replaces the entry of key in its shard, whose lock must be held, and
+// keeps the secondary indexes up to date.
+func (table *routingTable) store(shard *tableShard, key RoutingKey, oldEntry, newEntry RoutableEndpoints) {
+	shard.entries[key] = newEntry
+	table.indexes.update(key, oldEntry, newEntry)
+}
+
 func (table *routingTable) emit(key RoutingKey, oldEntry RoutableEndpoints, newEntry RoutableEndpoints) MessagesToEmit {
 	messagesToEmit := table.messageBuilder.RegistrationsFor(&oldEntry, &newEntry)
 	messagesToEmit = messagesToEmit.merge(table.messageBuilder.UnregistrationsFor(&oldEntry, &newEntry))
 
 	return messagesToEmit
 }
+
+func (table *routingTable) Conflicts() []HostnameConflict {
+	return table.owners.conflicts()
+}
+
+func (table *routingTable) RejectedRoutes() []RejectedRoutes {
+	return table.rejected.list()
+}
+
+// normalizeRoutes parses the route URIs of key, recording and logging the
+// ones that are rejected.
+func (table *routingTable) normalizeRoutes(key RoutingKey, uris []string) map[Route]struct{} {
+	routes, rejected := normalizeRoutes(uris)
+	if table.rejected.set(key, rejected) {
+		table.reportRejected(key, rejected)
+	}
+	return routes
+}
+
+func (table *routingTable) reportRejected(key RoutingKey, rejected []RejectedHostname) {
+	if len(rejected) == 0 {
+		return
+	}
+
+	table.logger.Info("rejected-hostnames", lager.Data{
+		"process-guid":   key.ProcessGuid,
+		"container-port": key.ContainerPort,
+		"hostnames":      rejected,
+	})
+}
+
+// claimRoutes records the hostnames claimed by key, and returns the routes it
+// may register under the conflict policy, along with the routing keys that
+// took over hostnames key no longer claims.
+func (table *routingTable) claimRoutes(key RoutingKey, routes map[Route]struct{}) (map[Route]struct{}, []RoutingKey) {
+	denied, conflicts, successors := table.owners.claim(key, hostnamesOf(routes))
+	table.reportConflicts(conflicts)
+
+	if table.conflictPolicy != ConflictPolicyFirstOwnerWins {
+		return routes, successors
+	}
+	return withoutHostnames(routes, denied), successors
+}
+
+// handOver registers the hostnames that the successors took over from a
+// process guid that released them. Only the first-owner-wins policy withholds
+// hostnames from their claimants. It must be called without holding a shard
+// lock, as the successors may live in any shard.
+func (table *routingTable) handOver(successors []RoutingKey) MessagesToEmit {
+	messagesToEmit := MessagesToEmit{}
+	if table.conflictPolicy != ConflictPolicyFirstOwnerWins {
+		return messagesToEmit
+	}
+
+	handedOver := map[RoutingKey]struct{}{}
+	for _, key := range successors {
+		if _, ok := handedOver[key]; ok {
+			continue
+		}
+		handedOver[key] = struct{}{}
+
+		messagesToEmit = messagesToEmit.merge(table.grantOwnedRoutes(key))
+	}
+
+	return messagesToEmit
+}
+
+// grantOwnedRoutes adds the routes on the hostnames key owns, but was denied
+// when it claimed them, to its entry.
+func (table *routingTable) grantOwnedRoutes(key RoutingKey) MessagesToEmit {
+	shard := table.shardFor(key)
+	shard.Lock()
+	defer shard.Unlock()
+
+	currentEntry, ok := shard.entries[key]
+	if !ok {
+		return MessagesToEmit{}
+	}
+
+	newEntry := currentEntry.copy()
+	for hostname := range table.owners.owned(key) {
+		newEntry.Routes[NewRoute(hostname)] = struct{}{}
+	}
+	if len(newEntry.Routes) == len(currentEntry.Routes) {
+		return MessagesToEmit{}
+	}
+
+	table.store(shard, key, currentEntry, newEntry)
+
+	return table.emit(key, currentEntry, newEntry)
+}
+
+func (table *routingTable) reportConflicts(conflicts []HostnameConflict) {
+	if table.conflictPolicy == ConflictPolicyAllow {
+		return
+	}
+
+	for _, conflict := range conflicts {
+		table.logger.Info("hostname-conflict", lager.Data{
+			"hostname":  conflict.Hostname,
+			"owner":     conflict.Owner,
+			"claimants": conflict.Claimants,
+			"policy":    table.conflictPolicy,
+		})
+	}
+}
+
+// hostnameClaims returns the hostnames routed by each routing key.
+func (table *routingTable) hostnameClaims() map[RoutingKey]map[string]struct{} {
+	claimsByKey := map[RoutingKey]map[string]struct{}{}
+
+	for _, shard := range table.shards {
+		shard.RLock()
+		for key, entry := range shard.entries {
+			if len(entry.Routes) > 0 {
+				claimsByKey[key] = hostnamesOf(entry.Routes)
+			}
+		}
+		shard.RUnlock()
+	}
+
+	return claimsByKey
+}
+
+func (table *routingTable) removeDeniedHostnames(claims map[string][]RoutingKey, claimsByKey map[RoutingKey]map[string]struct{}) {
+	for key, hostnames := range claimsByKey {
+		denied := deniedHostnames(claims, key, hostnames)
+		if len(denied) == 0 {
+			continue
+		}
+
+		shard := table.shardFor(key)
+		shard.Lock()
+		entry := shard.entries[key]
+		entry.Routes = withoutHostnames(entry.Routes, denied)
+		shard.entries[key] = entry
+		shard.Unlock()
+	}
+}
+
+func hostnamesOf(routes map[Route]struct{}) map[string]struct{} {
+	hostnames := make(map[string]struct{}, len(routes))
+	for route := range routes {
+		hostnames[route.URI()] = struct{}{}
+	}
+	return hostnames
+}
+
+func withoutHostnames(routes map[Route]struct{}, denied map[string]struct{}) map[Route]struct{} {
+	allowed := make(map[Route]struct{}, len(routes))
+	for route := range routes {
+		if _, ok := denied[route.URI()]; !ok {
+			allowed[route] = struct{}{}
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,128 @@
+package routing_table_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+)
+
+const benchmarkRoutingKeys = 100000
+
+func benchmarkKey(i int) routing_table.RoutingKey {
+	return routing_table.RoutingKey{ProcessGuid: fmt.Sprintf("process-guid-%d", i), ContainerPort: 8080}
+}
+
+func benchmarkEndpoint(i int) routing_table.Endpoint {
+	return routing_table.Endpoint{
+		InstanceGuid:  fmt.Sprintf("instance-guid-%d", i),
+		Host:          "1.1.1.1",
+		Port:          uint32(60000 + i%5000),
+		ContainerPort: 8080,
+	}
+}
+
+func benchmarkRoutes(i int) routing_table.Routes {
+	return routing_table.Routes{
+		Hostnames: []string{fmt.Sprintf("host-%d.example.com", i)},
+		LogGuid:   fmt.Sprintf("log-guid-%d", i),
+	}
+}
+
+func populatedTable(b *testing.B) routing_table.RoutingTable {
+	table := routing_table.NewTable()
+	for i := 0; i < benchmarkRoutingKeys; i++ {
+		key := benchmarkKey(i)
+		table.SetRoutes(key, benchmarkRoutes(i))
+		table.AddEndpoint(key, benchmarkEndpoint(i))
+	}
+	b.ResetTimer()
+	return table
+}
+
+func tempTable(offset int) routing_table.RoutingTable {
+	routes := routing_table.RoutesByRoutingKey{}
+	endpoints := routing_table.EndpointsByRoutingKey{}
+	for i := 0; i < benchmarkRoutingKeys; i++ {
+		key := benchmarkKey(i + offset)
+		routes[key] = benchmarkRoutes(i + offset)
+		endpoints[key] = []routing_table.Endpoint{benchmarkEndpoint(i + offset)}
+	}
+	return routing_table.NewTempTable(routes, endpoints)
+}
+
+func BenchmarkAddEndpoint(b *testing.B) {
+	table := populatedTable(b)
+
+	for i := 0; i < b.N; i++ {
+		n := i % benchmarkRoutingKeys
+		table.AddEndpoint(benchmarkKey(n), benchmarkEndpoint(n+benchmarkRoutingKeys))
+	}
+}
+
+func BenchmarkAddEndpointParallel(b *testing.B) {
+	table := populatedTable(b)
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			n := i % benchmarkRoutingKeys
+			table.AddEndpoint(benchmarkKey(n), benchmarkEndpoint(n+benchmarkRoutingKeys))
+			i += 7919
+		}
+	})
+}
+
+func BenchmarkAddEndpointDuringMessagesToEmit(b *testing.B) {
+	table := populatedTable(b)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				table.MessagesToEmit()
+			}
+		}
+	}()
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			n := i % benchmarkRoutingKeys
+			table.AddEndpoint(benchmarkKey(n), benchmarkEndpoint(n+benchmarkRoutingKeys))
+			i += 7919
+		}
+	})
+}
+
+func BenchmarkRouteCount(b *testing.B) {
+	table := populatedTable(b)
+
+	for i := 0; i < b.N; i++ {
+		table.RouteCount()
+	}
+}
+
+func BenchmarkMessagesToEmit(b *testing.B) {
+	table := populatedTable(b)
+
+	for i := 0; i < b.N; i++ {
+		table.MessagesToEmit()
+	}
+}
+
+func BenchmarkSwap(b *testing.B) {
+	table := populatedTable(b)
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		temp := tempTable((i % 2) * benchmarkRoutingKeys / 2)
+		b.StartTimer()
+
+		table.Swap(temp)
+	}
+}
@@ -8,27 +8,38 @@ type EndpointKey struct {
 }
 
 type Endpoint struct {
-	InstanceGuid    string
-	Host            string
-	Port            uint32
-	ContainerPort   uint32
-	Evacuating      bool
-	ModificationTag *models.ModificationTag
+	InstanceGuid     string                  `json:"instance_guid"`
+	Host             string                  `json:"host"`
+	Port             uint32                  `json:"port"`
+	ContainerPort    uint32                  `json:"container_port"`
+	Evacuating       bool                    `json:"evacuating"`
+	ProcessGuid      string                  `json:"process_guid"`
+	Index            int32                   `json:"index"`
+	Domain           string                  `json:"domain"`
+	CellId           string                  `json:"cell_id"`
+	AvailabilityZone string                  `json:"availability_zone,omitempty"`
+	ModificationTag  *models.ModificationTag `json:"modification_tag,omitempty"`
+
+	// Since is when the actual LRP last changed, in nanoseconds since the
+	// Unix epoch.
+	Since int64 `json:"since"`
 }
 
 func (e Endpoint) key() EndpointKey {
 	return EndpointKey{InstanceGuid: e.InstanceGuid, Evacuating: e.Evacuating}
 }
 
+// Routes describes the routes of a routing key. Each of the Hostnames may be
+// qualified by a context path, e.g. "example.com/api".
 type Routes struct {
-	Hostnames       []string
-	LogGuid         string
-	RouteServiceUrl string
-	ModificationTag *models.ModificationTag
+	Hostnames       []string                `json:"hostnames"`
+	LogGuid         string                  `json:"log_guid"`
+	RouteServiceUrl string                  `json:"route_service_url,omitempty"`
+	ModificationTag *models.ModificationTag `json:"modification_tag,omitempty"`
 }
 
 type RoutableEndpoints struct {
-	Hostnames       map[string]struct{}
+	Routes          map[Route]struct{}
 	Endpoints       map[EndpointKey]Endpoint
 	LogGuid         string
 	ModificationTag *models.ModificationTag
@@ -42,7 +53,7 @@ type RoutingKey struct {
 
 func NewRoutableEndpoints() RoutableEndpoints {
 	return RoutableEndpoints{
-		Hostnames: map[string]struct{}{},
+		Routes:    map[Route]struct{}{},
 		Endpoints: map[EndpointKey]Endpoint{},
 	}
 }
@@ -57,22 +68,22 @@ func (entry RoutableEndpoints) hasEndpoint(endpoint Endpoint) bool {
 	return found
 }
 
-func (entry RoutableEndpoints) hasHostname(hostname string) bool {
-	_, ok := entry.Hostnames[hostname]
+func (entry RoutableEndpoints) hasRoute(route Route) bool {
+	_, ok := entry.Routes[route]
 	return ok
 }
 
 func (entry RoutableEndpoints) copy() RoutableEndpoints {
 	clone := RoutableEndpoints{
-		Hostnames:       map[string]struct{}{},
+		Routes:          map[Route]struct{}{},
 		Endpoints:       map[EndpointKey]Endpoint{},
 		LogGuid:         entry.LogGuid,
 		ModificationTag: entry.ModificationTag,
 		RouteServiceUrl: entry.RouteServiceUrl,
 	}
 
-	for k, v := range entry.Hostnames {
-		clone.Hostnames[k] = v
+	for k, v := range entry.Routes {
+		clone.Routes[k] = v
 	}
 
 	for k, v := range entry.Endpoints {
@@ -83,11 +94,11 @@ func (entry RoutableEndpoints) copy() RoutableEndpoints {
 }
 
 func (entry RoutableEndpoints) routes() Routes {
-	hostnames := make([]string, len(entry.Hostnames))
+	hostnames := make([]string, len(entry.Routes))
 
 	i := 0
-	for hostname := range entry.Hostnames {
-		hostnames[i] = hostname
+	for route := range entry.Routes {
+		hostnames[i] = route.URI()
 		i++
 	}
 
@@ -98,10 +109,10 @@ func (entry RoutableEndpoints) routes() Routes {
 	}
 }
 
-func routesAsMap(routes []string) map[string]struct{} {
-	routesMap := map[string]struct{}{}
-	for _, route := range routes {
-		routesMap[route] = struct{}{}
+func RoutesAsMap(uris []string) map[Route]struct{} {
+	routesMap := map[Route]struct{}{}
+	for _, uri := range uris {
+		routesMap[NewRoute(uri)] = struct{}{}
 	}
 	return routesMap
 }
@@ -1,8 +1,12 @@
 package routing_table_test
 
 import (
+	"fmt"
+	"sync"
+
 	"github.com/cloudfoundry-incubator/bbs/models"
 	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/pivotal-golang/lager/lagertest"
 
 	. "github.com/cloudfoundry-incubator/route-emitter/routing_table/matchers"
 	. "github.com/onsi/ginkgo"
@@ -527,6 +531,50 @@ var _ = Describe("RoutingTable", func() {
 		})
 	})
 
+	Describe("Diff", func() {
+		BeforeEach(func() {
+			table.SetRoutes(key, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: logGuid, ModificationTag: currentTag})
+			table.AddEndpoint(key, endpoint1)
+			table.AddEndpoint(key, endpoint2)
+		})
+
+		It("reports missing registrations and stale routes", func() {
+			tempTable := routing_table.NewTempTable(
+				routing_table.RoutesByRoutingKey{key: routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: logGuid}},
+				routing_table.EndpointsByRoutingKey{key: {endpoint1, endpoint3}},
+			)
+
+			messagesToEmit = table.Diff(tempTable)
+
+			expected := routing_table.MessagesToEmit{
+				RegistrationMessages: []routing_table.RegistryMessage{
+					routing_table.RegistryMessageFor(endpoint3, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: logGuid}),
+				},
+				UnregistrationMessages: []routing_table.RegistryMessage{
+					routing_table.RegistryMessageFor(endpoint2, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: logGuid}),
+				},
+			}
+			Expect(messagesToEmit).To(MatchMessagesToEmit(expected))
+		})
+
+		It("reports nothing when the tables agree", func() {
+			tempTable := routing_table.NewTempTable(
+				routing_table.RoutesByRoutingKey{key: routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: logGuid}},
+				routing_table.EndpointsByRoutingKey{key: {endpoint1, endpoint2}},
+			)
+
+			Expect(table.Diff(tempTable)).To(BeZero())
+		})
+
+		It("does not modify the table", func() {
+			before := table.MessagesToEmit()
+
+			table.Diff(routing_table.NewTempTable(routing_table.RoutesByRoutingKey{}, routing_table.EndpointsByRoutingKey{}))
+
+			Expect(table.MessagesToEmit()).To(MatchMessagesToEmit(before))
+		})
+	})
+
 	Describe("Processing deltas", func() {
 		Context("when the table is empty", func() {
 			Context("When setting routes", func() {
@@ -583,6 +631,18 @@ var _ = Describe("RoutingTable", func() {
 					Expect(messagesToEmit).To(MatchMessagesToEmit(expected))
 				})
 
+				It("emits registrations with the normalized path when a context path is added to a route", func() {
+					messagesToEmit = table.SetRoutes(key, routing_table.Routes{Hostnames: []string{hostname1, hostname2, hostname1 + "/api/"}, LogGuid: logGuid, ModificationTag: newerTag})
+
+					expected := routing_table.MessagesToEmit{
+						RegistrationMessages: []routing_table.RegistryMessage{
+							routing_table.RegistryMessageFor(endpoint1, routing_table.Routes{Hostnames: []string{hostname1, hostname2, hostname1 + "/api"}, LogGuid: logGuid}),
+							routing_table.RegistryMessageFor(endpoint2, routing_table.Routes{Hostnames: []string{hostname1, hostname2, hostname1 + "/api"}, LogGuid: logGuid}),
+						},
+					}
+					Expect(messagesToEmit).To(MatchMessagesToEmit(expected))
+				})
+
 				It("emits nothing when a hostname is added to a route with an older tag", func() {
 					messagesToEmit = table.SetRoutes(key, routing_table.Routes{Hostnames: []string{hostname1, hostname2, hostname3}, LogGuid: logGuid, ModificationTag: olderTag})
 					Expect(messagesToEmit).To(BeZero())
@@ -672,6 +732,11 @@ var _ = Describe("RoutingTable", func() {
 					messagesToEmit = table.RemoveRoutes(key, olderTag)
 					Expect(messagesToEmit).To(BeZero())
 				})
+
+				It("no longer registers the routes", func() {
+					table.RemoveRoutes(key, currentTag)
+					Expect(table.MessagesToEmit().RegistrationMessages).To(BeEmpty())
+				})
 			})
 
 			Context("AddEndpoint", func() {
@@ -940,6 +1005,12 @@ var _ = Describe("RoutingTable", func() {
 			Expect(table.RouteCount()).To(Equal(2))
 		})
 
+		It("counts routes that differ only by context path separately", func() {
+			table.SetRoutes(routing_table.RoutingKey{ProcessGuid: "fake-process-guid"}, routing_table.Routes{Hostnames: []string{"fake-route-url", "fake-route-url/path", "fake-route-url/path/"}, LogGuid: logGuid})
+
+			Expect(table.RouteCount()).To(Equal(2))
+		})
+
 		It("returns 4 after associating 2 urls with two processes", func() {
 			table.SetRoutes(routing_table.RoutingKey{ProcessGuid: "fake-process-guid-a"}, routing_table.Routes{Hostnames: []string{"fake-route-url-a-1", "fake-route-url-a-2"}, LogGuid: logGuid})
 			table.SetRoutes(routing_table.RoutingKey{ProcessGuid: "fake-process-guid-b"}, routing_table.Routes{Hostnames: []string{"fake-route-url-b-1", "fake-route-url-b-2"}, LogGuid: logGuid})
@@ -947,4 +1018,388 @@ var _ = Describe("RoutingTable", func() {
 			Expect(table.RouteCount()).To(Equal(4))
 		})
 	})
+
+	Describe("Snapshot", func() {
+		BeforeEach(func() {
+			table.SetRoutes(key, routing_table.Routes{Hostnames: []string{hostname1, hostname2}, LogGuid: logGuid, ModificationTag: currentTag})
+			table.AddEndpoint(key, endpoint1)
+			table.AddEndpoint(key, evacuating1)
+		})
+
+		It("captures the routes and endpoints of each routing key", func() {
+			snapshot := table.Snapshot()
+
+			Expect(snapshot.Entries).To(HaveLen(1))
+			entry := snapshot.Entries[0]
+			Expect(entry.ProcessGuid).To(Equal(key.ProcessGuid))
+			Expect(entry.ContainerPort).To(Equal(key.ContainerPort))
+			Expect(entry.Hostnames).To(ConsistOf(hostname1, hostname2))
+			Expect(entry.LogGuid).To(Equal(logGuid))
+			Expect(entry.ModificationTag).To(Equal(currentTag))
+			Expect(entry.Endpoints).To(ConsistOf(endpoint1, evacuating1))
+		})
+
+		It("can be restored into an equivalent table", func() {
+			restored := routing_table.NewTableFromSnapshot(table.Snapshot(), routing_table.TableOptions{})
+
+			Expect(restored.RouteCount()).To(Equal(table.RouteCount()))
+			Expect(restored.MessagesToEmit()).To(MatchMessagesToEmit(table.MessagesToEmit()))
+		})
+
+		It("restores modification tags so stale updates are still rejected", func() {
+			restored := routing_table.NewTableFromSnapshot(table.Snapshot(), routing_table.TableOptions{})
+
+			messagesToEmit = restored.SetRoutes(key, routing_table.Routes{Hostnames: []string{hostname3}, LogGuid: logGuid, ModificationTag: olderTag})
+			Expect(messagesToEmit).To(BeZero())
+		})
+	})
+
+	Describe("hostname conflicts", func() {
+		var (
+			logger        *lagertest.TestLogger
+			policy        routing_table.ConflictPolicy
+			otherKey      routing_table.RoutingKey
+			otherEndpoint routing_table.Endpoint
+		)
+
+		BeforeEach(func() {
+			logger = lagertest.NewTestLogger("test")
+			policy = routing_table.ConflictPolicyAllow
+			otherKey = routing_table.RoutingKey{ProcessGuid: "other-process-guid", ContainerPort: 8080}
+			otherEndpoint = routing_table.Endpoint{InstanceGuid: "ig-4", Host: "4.4.4.4", Port: 44, ContainerPort: 8080, ModificationTag: currentTag}
+		})
+
+		JustBeforeEach(func() {
+			table = routing_table.NewTableWithOptions(routing_table.TableOptions{ConflictPolicy: policy, Logger: logger})
+			table.SetRoutes(key, routing_table.Routes{Hostnames: []string{hostname1, hostname2}, LogGuid: logGuid, ModificationTag: currentTag})
+			table.AddEndpoint(key, endpoint1)
+			table.AddEndpoint(otherKey, otherEndpoint)
+		})
+
+		It("does not report routes on different context paths of the same host", func() {
+			table.SetRoutes(otherKey, routing_table.Routes{Hostnames: []string{hostname1 + "/api"}, LogGuid: "other-log-guid", ModificationTag: currentTag})
+			Expect(table.Conflicts()).To(BeEmpty())
+		})
+
+		It("does not report a hostname claimed by several ports of the same process guid", func() {
+			sameProcessKey := routing_table.RoutingKey{ProcessGuid: key.ProcessGuid, ContainerPort: 9090}
+			table.SetRoutes(sameProcessKey, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: logGuid, ModificationTag: currentTag})
+			Expect(table.Conflicts()).To(BeEmpty())
+		})
+
+		Context("when the policy is allow", func() {
+			It("registers the hostname for every claimant", func() {
+				messagesToEmit = table.SetRoutes(otherKey, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: "other-log-guid", ModificationTag: currentTag})
+
+				expected := routing_table.MessagesToEmit{
+					RegistrationMessages: []routing_table.RegistryMessage{
+						routing_table.RegistryMessageFor(otherEndpoint, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: "other-log-guid"}),
+					},
+				}
+				Expect(messagesToEmit).To(MatchMessagesToEmit(expected))
+			})
+
+			It("lists the conflict without logging it", func() {
+				table.SetRoutes(otherKey, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: "other-log-guid", ModificationTag: currentTag})
+
+				Expect(table.Conflicts()).To(Equal([]routing_table.HostnameConflict{
+					{Hostname: hostname1, Owner: key.ProcessGuid, Claimants: []string{otherKey.ProcessGuid}},
+				}))
+				Expect(logger.LogMessages()).NotTo(ContainElement("test.routing-table.hostname-conflict"))
+			})
+
+			It("forgets the conflict once a claimant releases the hostname", func() {
+				table.SetRoutes(otherKey, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: "other-log-guid", ModificationTag: currentTag})
+				table.RemoveRoutes(key, currentTag)
+
+				Expect(table.Conflicts()).To(BeEmpty())
+			})
+		})
+
+		Context("when the policy is warn", func() {
+			BeforeEach(func() {
+				policy = routing_table.ConflictPolicyWarn
+			})
+
+			It("registers the hostname and logs the conflict once", func() {
+				messagesToEmit = table.SetRoutes(otherKey, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: "other-log-guid", ModificationTag: currentTag})
+				Expect(messagesToEmit.RegistrationMessages).To(HaveLen(1))
+
+				table.SetRoutes(otherKey, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: "other-log-guid", ModificationTag: newerTag})
+
+				Expect(logger.LogMessages()).To(Equal([]string{"test.routing-table.hostname-conflict"}))
+				Expect(logger.Logs()[0].Data).To(HaveKeyWithValue("owner", key.ProcessGuid))
+				Expect(logger.Logs()[0].Data).To(HaveKeyWithValue("hostname", hostname1))
+			})
+
+			It("logs conflicts that appear on a sync", func() {
+				tempTable := routing_table.NewTempTable(
+					routing_table.RoutesByRoutingKey{
+						key:      routing_table.Routes{Hostnames: []string{hostname1, hostname2}, LogGuid: logGuid},
+						otherKey: routing_table.Routes{Hostnames: []string{hostname2}, LogGuid: "other-log-guid"},
+					},
+					routing_table.EndpointsByRoutingKey{key: {endpoint1}, otherKey: {otherEndpoint}},
+				)
+				table.Swap(tempTable)
+
+				Expect(logger.LogMessages()).To(Equal([]string{"test.routing-table.hostname-conflict"}))
+				Expect(table.Conflicts()).To(Equal([]routing_table.HostnameConflict{
+					{Hostname: hostname2, Owner: key.ProcessGuid, Claimants: []string{otherKey.ProcessGuid}},
+				}))
+			})
+		})
+
+		Context("when the policy is first-owner-wins", func() {
+			BeforeEach(func() {
+				policy = routing_table.ConflictPolicyFirstOwnerWins
+			})
+
+			It("does not register the hostname for later claimants", func() {
+				messagesToEmit = table.SetRoutes(otherKey, routing_table.Routes{Hostnames: []string{hostname1, hostname3}, LogGuid: "other-log-guid", ModificationTag: currentTag})
+
+				expected := routing_table.MessagesToEmit{
+					RegistrationMessages: []routing_table.RegistryMessage{
+						routing_table.RegistryMessageFor(otherEndpoint, routing_table.Routes{Hostnames: []string{hostname3}, LogGuid: "other-log-guid"}),
+					},
+				}
+				Expect(messagesToEmit).To(MatchMessagesToEmit(expected))
+				Expect(logger.LogMessages()).To(Equal([]string{"test.routing-table.hostname-conflict"}))
+				Expect(table.Conflicts()).To(HaveLen(1))
+			})
+
+			It("hands the hostname over to a claimant as soon as the owner drops it from its routes", func() {
+				table.SetRoutes(otherKey, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: "other-log-guid", ModificationTag: currentTag})
+
+				messagesToEmit = table.SetRoutes(key, routing_table.Routes{Hostnames: []string{hostname2}, LogGuid: logGuid, ModificationTag: newerTag})
+
+				expected := routing_table.MessagesToEmit{
+					RegistrationMessages: []routing_table.RegistryMessage{
+						routing_table.RegistryMessageFor(endpoint1, routing_table.Routes{Hostnames: []string{hostname2}, LogGuid: logGuid}),
+						routing_table.RegistryMessageFor(otherEndpoint, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: "other-log-guid"}),
+					},
+					UnregistrationMessages: []routing_table.RegistryMessage{
+						routing_table.RegistryMessageFor(endpoint1, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: logGuid}),
+					},
+				}
+				Expect(messagesToEmit).To(MatchMessagesToEmit(expected))
+				Expect(table.Conflicts()).To(BeEmpty())
+
+				By("not registering the hostname again when the claimant's routes are next set")
+				messagesToEmit = table.SetRoutes(otherKey, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: "other-log-guid", ModificationTag: newerTag})
+				Expect(messagesToEmit).To(BeZero())
+			})
+
+			It("hands the hostname over to a claimant as soon as the owner's routes are removed", func() {
+				table.SetRoutes(otherKey, routing_table.Routes{Hostnames: []string{hostname1, hostname3}, LogGuid: "other-log-guid", ModificationTag: currentTag})
+
+				messagesToEmit = table.RemoveRoutes(key, currentTag)
+
+				expected := routing_table.MessagesToEmit{
+					RegistrationMessages: []routing_table.RegistryMessage{
+						routing_table.RegistryMessageFor(otherEndpoint, routing_table.Routes{Hostnames: []string{hostname1, hostname3}, LogGuid: "other-log-guid"}),
+					},
+					UnregistrationMessages: []routing_table.RegistryMessage{
+						routing_table.RegistryMessageFor(endpoint1, routing_table.Routes{Hostnames: []string{hostname1, hostname2}, LogGuid: logGuid}),
+					},
+				}
+				Expect(messagesToEmit).To(MatchMessagesToEmit(expected))
+				Expect(table.MessagesToEmit()).To(MatchMessagesToEmit(routing_table.MessagesToEmit{
+					RegistrationMessages: []routing_table.RegistryMessage{
+						routing_table.RegistryMessageFor(otherEndpoint, routing_table.Routes{Hostnames: []string{hostname1, hostname3}, LogGuid: "other-log-guid"}),
+					},
+				}))
+			})
+
+			It("keeps the hostname with the owner while it has routes but no endpoints", func() {
+				table.SetRoutes(otherKey, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: "other-log-guid", ModificationTag: currentTag})
+
+				messagesToEmit = table.RemoveEndpoint(key, endpoint1)
+
+				expected := routing_table.MessagesToEmit{
+					UnregistrationMessages: []routing_table.RegistryMessage{
+						routing_table.RegistryMessageFor(endpoint1, routing_table.Routes{Hostnames: []string{hostname1, hostname2}, LogGuid: logGuid}),
+					},
+				}
+				Expect(messagesToEmit).To(MatchMessagesToEmit(expected))
+				Expect(table.Conflicts()).To(HaveLen(1))
+			})
+
+			It("keeps the existing owner on a sync", func() {
+				tempTable := routing_table.NewTempTable(
+					routing_table.RoutesByRoutingKey{
+						key:      routing_table.Routes{Hostnames: []string{hostname1, hostname2}, LogGuid: logGuid},
+						otherKey: routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: "other-log-guid"},
+					},
+					routing_table.EndpointsByRoutingKey{key: {endpoint1}, otherKey: {otherEndpoint}},
+				)
+				messagesToEmit = table.Swap(tempTable)
+
+				expected := routing_table.MessagesToEmit{
+					RegistrationMessages: []routing_table.RegistryMessage{
+						routing_table.RegistryMessageFor(endpoint1, routing_table.Routes{Hostnames: []string{hostname1, hostname2}, LogGuid: logGuid}),
+					},
+				}
+				Expect(messagesToEmit).To(MatchMessagesToEmit(expected))
+				Expect(table.Conflicts()).To(Equal([]routing_table.HostnameConflict{
+					{Hostname: hostname1, Owner: key.ProcessGuid, Claimants: []string{otherKey.ProcessGuid}},
+				}))
+			})
+
+			It("applies the policy when diffing", func() {
+				tempTable := routing_table.NewTempTable(
+					routing_table.RoutesByRoutingKey{
+						key:      routing_table.Routes{Hostnames: []string{hostname1, hostname2}, LogGuid: logGuid},
+						otherKey: routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: "other-log-guid"},
+					},
+					routing_table.EndpointsByRoutingKey{key: {endpoint1}, otherKey: {otherEndpoint}},
+				)
+
+				Expect(table.Diff(tempTable)).To(BeZero())
+			})
+		})
+	})
+
+	Describe("hostname validation", func() {
+		var logger *lagertest.TestLogger
+
+		BeforeEach(func() {
+			logger = lagertest.NewTestLogger("test")
+			table = routing_table.NewTableWithOptions(routing_table.TableOptions{Logger: logger})
+			table.AddEndpoint(key, endpoint1)
+		})
+
+		It("registers normalized hostnames", func() {
+			messagesToEmit = table.SetRoutes(key, routing_table.Routes{Hostnames: []string{"Foo.Example.com.", "foo.example.com", "FOO.example.com/api"}, LogGuid: logGuid, ModificationTag: currentTag})
+
+			expected := routing_table.MessagesToEmit{
+				RegistrationMessages: []routing_table.RegistryMessage{
+					routing_table.RegistryMessageFor(endpoint1, routing_table.Routes{Hostnames: []string{"foo.example.com", "foo.example.com/api"}, LogGuid: logGuid}),
+				},
+			}
+			Expect(messagesToEmit).To(MatchMessagesToEmit(expected))
+		})
+
+		It("does not register invalid hostnames and reports them per process guid", func() {
+			messagesToEmit = table.SetRoutes(key, routing_table.Routes{Hostnames: []string{hostname1, "foo_bar.example.com"}, LogGuid: logGuid, ModificationTag: currentTag})
+
+			expected := routing_table.MessagesToEmit{
+				RegistrationMessages: []routing_table.RegistryMessage{
+					routing_table.RegistryMessageFor(endpoint1, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: logGuid}),
+				},
+			}
+			Expect(messagesToEmit).To(MatchMessagesToEmit(expected))
+			Expect(table.RejectedRoutes()).To(Equal([]routing_table.RejectedRoutes{
+				{
+					ProcessGuid: key.ProcessGuid,
+					Hostnames: []routing_table.RejectedHostname{
+						{Hostname: "foo_bar.example.com", Reason: `label "foo_bar" contains invalid character '_'`},
+					},
+				},
+			}))
+			Expect(logger.LogMessages()).To(Equal([]string{"test.routing-table.rejected-hostnames"}))
+		})
+
+		It("only logs rejected hostnames when they change", func() {
+			table.SetRoutes(key, routing_table.Routes{Hostnames: []string{"foo_bar.example.com"}, LogGuid: logGuid, ModificationTag: currentTag})
+			table.SetRoutes(key, routing_table.Routes{Hostnames: []string{"foo_bar.example.com"}, LogGuid: logGuid, ModificationTag: newerTag})
+
+			Expect(logger.LogMessages()).To(HaveLen(1))
+		})
+
+		It("forgets rejected hostnames when the routes are removed", func() {
+			table.SetRoutes(key, routing_table.Routes{Hostnames: []string{"foo_bar.example.com"}, LogGuid: logGuid, ModificationTag: currentTag})
+			table.RemoveRoutes(key, currentTag)
+
+			Expect(table.RejectedRoutes()).To(BeEmpty())
+		})
+
+		It("replaces rejected hostnames on a sync", func() {
+			table.SetRoutes(key, routing_table.Routes{Hostnames: []string{"foo_bar.example.com"}, LogGuid: logGuid, ModificationTag: currentTag})
+
+			tempTable := routing_table.NewTempTable(
+				routing_table.RoutesByRoutingKey{key: routing_table.Routes{Hostnames: []string{hostname1, "-foo.example.com"}, LogGuid: logGuid}},
+				routing_table.EndpointsByRoutingKey{key: {endpoint1}},
+			)
+			messagesToEmit = table.Swap(tempTable)
+
+			expected := routing_table.MessagesToEmit{
+				RegistrationMessages: []routing_table.RegistryMessage{
+					routing_table.RegistryMessageFor(endpoint1, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: logGuid}),
+				},
+			}
+			Expect(messagesToEmit).To(MatchMessagesToEmit(expected))
+			Expect(table.RejectedRoutes()).To(HaveLen(1))
+			Expect(table.RejectedRoutes()[0].Hostnames).To(ConsistOf(
+				routing_table.RejectedHostname{Hostname: "-foo.example.com", Reason: `label "-foo" starts or ends with a hyphen`},
+			))
+		})
+	})
+
+	Describe("removing endpoints built from actual LRPs", func() {
+		actualLRPWithTag := func(tag models.ModificationTag) *routing_table.ActualLRPRoutingInfo {
+			return &routing_table.ActualLRPRoutingInfo{
+				ActualLRP: &models.ActualLRP{
+					ActualLRPKey:         models.NewActualLRPKey(key.ProcessGuid, 0, "domain"),
+					ActualLRPInstanceKey: models.NewActualLRPInstanceKey("ig-1", "cell-id"),
+					ActualLRPNetInfo:     models.NewActualLRPNetInfo("1.1.1.1", models.NewPortMapping(11, 8080)),
+					State:                models.ActualLRPStateRunning,
+					ModificationTag:      tag,
+				},
+			}
+		}
+
+		endpointFor := func(actualLRPInfo *routing_table.ActualLRPRoutingInfo) routing_table.Endpoint {
+			endpoints, err := routing_table.EndpointsFromActual(actualLRPInfo)
+			Expect(err).NotTo(HaveOccurred())
+			return endpoints[8080]
+		}
+
+		BeforeEach(func() {
+			table.SetRoutes(key, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: logGuid})
+			table.AddEndpoint(key, endpointFor(actualLRPWithTag(models.ModificationTag{Epoch: "abc", Index: 2})))
+		})
+
+		It("removes the endpoint when the actual LRP is removed in the same version", func() {
+			messagesToEmit = table.RemoveEndpoint(key, endpointFor(actualLRPWithTag(models.ModificationTag{Epoch: "abc", Index: 2})))
+			Expect(messagesToEmit.UnregistrationMessages).To(HaveLen(1))
+			Expect(table.MessagesToEmit().RegistrationMessages).To(BeEmpty())
+		})
+
+		It("removes the endpoint when the actual LRP is removed in a newer version", func() {
+			messagesToEmit = table.RemoveEndpoint(key, endpointFor(actualLRPWithTag(models.ModificationTag{Epoch: "abc", Index: 3})))
+			Expect(messagesToEmit.UnregistrationMessages).To(HaveLen(1))
+			Expect(table.MessagesToEmit().RegistrationMessages).To(BeEmpty())
+		})
+
+		It("keeps the endpoint when a stale version of the actual LRP is removed", func() {
+			messagesToEmit = table.RemoveEndpoint(key, endpointFor(actualLRPWithTag(models.ModificationTag{Epoch: "abc", Index: 1})))
+			Expect(messagesToEmit).To(BeZero())
+			Expect(table.MessagesToEmit().RegistrationMessages).To(HaveLen(1))
+		})
+	})
+
+	Describe("concurrent updates", func() {
+		It("applies updates to many routing keys from many goroutines", func() {
+			const numKeys = 200
+
+			wg := sync.WaitGroup{}
+			for i := 0; i < numKeys; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer GinkgoRecover()
+					defer wg.Done()
+
+					key := routing_table.RoutingKey{ProcessGuid: fmt.Sprintf("process-guid-%d", i), ContainerPort: 8080}
+					table.SetRoutes(key, routing_table.Routes{Hostnames: []string{fmt.Sprintf("host-%d.example.com", i)}, LogGuid: logGuid})
+					table.AddEndpoint(key, endpoint1)
+					table.MessagesToEmit()
+				}(i)
+			}
+			wg.Wait()
+
+			Expect(table.RouteCount()).To(Equal(numKeys))
+			Expect(table.MessagesToEmit().RegistrationMessages).To(HaveLen(numKeys))
+			Expect(table.Snapshot().Entries).To(HaveLen(numKeys))
+		})
+	})
 })
@@ -0,0 +1,82 @@
+package routing_table
+
+import "github.com/cloudfoundry-incubator/bbs/models"
+
+// SnapshotVersion identifies the layout of Snapshot. It must be bumped
+// whenever the serialized form of a snapshot changes incompatibly.
+//
+// Version 2 serializes endpoints with snake_case keys rather than Go field
+// names.
+const SnapshotVersion = 2
+
+// Snapshot is the HTTP routing table, as persisted across restarts. TCP
+// routes are deliberately left out: the routing API holds on to TCP route
+// mappings until their TTL expires, so they outlive a restart, and the TCP
+// table is rebuilt by the first sync.
+type Snapshot struct {
+	Entries []SnapshotEntry `json:"entries"`
+}
+
+type SnapshotEntry struct {
+	ProcessGuid     string                  `json:"process_guid"`
+	ContainerPort   uint32                  `json:"container_port"`
+	Hostnames       []string                `json:"hostnames"`
+	LogGuid         string                  `json:"log_guid"`
+	RouteServiceUrl string                  `json:"route_service_url,omitempty"`
+	ModificationTag *models.ModificationTag `json:"modification_tag,omitempty"`
+	Endpoints       []Endpoint              `json:"endpoints"`
+}
+
+func NewTableFromSnapshot(snapshot Snapshot, options TableOptions) RoutingTable {
+	table := NewTableWithOptions(options).(*routingTable)
+
+	for _, snapshotEntry := range snapshot.Entries {
+		key := RoutingKey{ProcessGuid: snapshotEntry.ProcessGuid, ContainerPort: snapshotEntry.ContainerPort}
+		table.store(table.shardFor(key), key, RoutableEndpoints{}, RoutableEndpoints{
+			Routes:          RoutesAsMap(snapshotEntry.Hostnames),
+			Endpoints:       EndpointsAsMap(snapshotEntry.Endpoints),
+			LogGuid:         snapshotEntry.LogGuid,
+			ModificationTag: snapshotEntry.ModificationTag,
+			RouteServiceUrl: snapshotEntry.RouteServiceUrl,
+		})
+	}
+
+	table.owners.replace(table.hostnameClaims())
+
+	return table
+}
+
+func (table *routingTable) Snapshot() Snapshot {
+	snapshot := Snapshot{Entries: []SnapshotEntry{}}
+
+	for _, shard := range table.shards {
+		shard.RLock()
+		snapshot.Entries = append(snapshot.Entries, shard.snapshotEntries()...)
+		shard.RUnlock()
+	}
+
+	return snapshot
+}
+
+func (shard *tableShard) snapshotEntries() []SnapshotEntry {
+	entries := make([]SnapshotEntry, 0, len(shard.entries))
+	for key, entry := range shard.entries {
+		snapshotEntry := SnapshotEntry{
+			ProcessGuid:     key.ProcessGuid,
+			ContainerPort:   key.ContainerPort,
+			Hostnames:       entry.routes().Hostnames,
+			LogGuid:         entry.LogGuid,
+			RouteServiceUrl: entry.RouteServiceUrl,
+			ModificationTag: entry.ModificationTag,
+			Endpoints:       make([]Endpoint, 0, len(entry.Endpoints)),
+		}
+
+		for _, endpoint := range entry.Endpoints {
+			snapshotEntry.Endpoints = append(snapshotEntry.Endpoints, endpoint)
+		}
+
+		entries = append(entries, snapshotEntry)
+	}
+
+	return entries
+}
@@ -0,0 +1,48 @@
+package routing_table
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// shardCount is the number of shards in every routing table. Temporary tables
+// use the same layout, so that Swap and Diff can work shard by shard.
+const shardCount = 32
+
+type rwLocker interface {
+	sync.Locker
+	RLock()
+	RUnlock()
+}
+
+type tableShard struct {
+	entries map[RoutingKey]RoutableEndpoints
+	rwLocker
+}
+
+func newShards(newLocker func() rwLocker) []*tableShard {
+	shards := make([]*tableShard, shardCount)
+	for i := range shards {
+		shards[i] = &tableShard{
+			entries:  make(map[RoutingKey]RoutableEndpoints),
+			rwLocker: newLocker(),
+		}
+	}
+	return shards
+}
+
+func newRWMutex() rwLocker {
+	return &sync.RWMutex{}
+}
+
+func newNoopLocker() rwLocker {
+	return noopLocker{}
+}
+
+// shardIndex hashes the process guid, so all routing keys of a process live
+// in the same shard.
+func shardIndex(key RoutingKey) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(key.ProcessGuid))
+	return int(hash.Sum32() % shardCount)
+}
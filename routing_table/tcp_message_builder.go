@@ -0,0 +1,52 @@
+package routing_table
+
+type TCPMessageBuilder interface {
+	RegistrationsFor(existingEntry *TCPRoutableEndpoints, newEntry *TCPRoutableEndpoints) TCPMessagesToEmit
+	UnregistrationsFor(existingEntry *TCPRoutableEndpoints, newEntry *TCPRoutableEndpoints) TCPMessagesToEmit
+}
+
+type NoopTCPMessageBuilder struct {
+}
+
+func (NoopTCPMessageBuilder) RegistrationsFor(existingEntry *TCPRoutableEndpoints, newEntry *TCPRoutableEndpoints) TCPMessagesToEmit {
+	return TCPMessagesToEmit{}
+}
+func (NoopTCPMessageBuilder) UnregistrationsFor(existingEntry *TCPRoutableEndpoints, newEntry *TCPRoutableEndpoints) TCPMessagesToEmit {
+	return TCPMessagesToEmit{}
+}
+
+type TCPMessagesToEmitBuilder struct {
+}
+
+func (TCPMessagesToEmitBuilder) RegistrationsFor(existingEntry *TCPRoutableEndpoints, newEntry *TCPRoutableEndpoints) TCPMessagesToEmit {
+	messagesToEmit := TCPMessagesToEmit{}
+
+	existingMappings := map[TCPRouteMapping]struct{}{}
+	if existingEntry != nil {
+		existingMappings = existingEntry.routeMappings()
+	}
+
+	//only register mappings that the router does not already know about
+	for mapping := range newEntry.routeMappings() {
+		if _, found := existingMappings[mapping]; !found {
+			messagesToEmit.RegistrationMessages = append(messagesToEmit.RegistrationMessages, mapping)
+		}
+	}
+
+	return messagesToEmit
+}
+
+func (TCPMessagesToEmitBuilder) UnregistrationsFor(existingEntry *TCPRoutableEndpoints, newEntry *TCPRoutableEndpoints) TCPMessagesToEmit {
+	messagesToEmit := TCPMessagesToEmit{}
+
+	newMappings := newEntry.routeMappings()
+
+	//either the external port or the backend has gone away
+	for mapping := range existingEntry.routeMappings() {
+		if _, found := newMappings[mapping]; !found {
+			messagesToEmit.UnregistrationMessages = append(messagesToEmit.UnregistrationMessages, mapping)
+		}
+	}
+
+	return messagesToEmit
+}
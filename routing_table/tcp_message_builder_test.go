@@ -0,0 +1,175 @@
+package routing_table_test
+
+import (
+	"github.com/cloudfoundry-incubator/bbs/models"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TCPMessagesToEmitBuilder", func() {
+	var builder routing_table.TCPMessagesToEmitBuilder
+	var existingEntry *routing_table.TCPRoutableEndpoints
+	var newEntry *routing_table.TCPRoutableEndpoints
+	var messages routing_table.TCPMessagesToEmit
+
+	external1 := routing_table.ExternalEndpointInfo{RouterGroupGuid: "router-group-guid", Port: 61000}
+	external2 := routing_table.ExternalEndpointInfo{RouterGroupGuid: "router-group-guid", Port: 61001}
+
+	currentTag := &models.ModificationTag{Epoch: "abc", Index: 1}
+	endpoint1 := routing_table.Endpoint{InstanceGuid: "ig-1", Host: "1.1.1.1", Port: 11, ContainerPort: 5222, Evacuating: false, ModificationTag: currentTag}
+	endpoint2 := routing_table.Endpoint{InstanceGuid: "ig-2", Host: "2.2.2.2", Port: 22, ContainerPort: 5222, Evacuating: false, ModificationTag: currentTag}
+
+	BeforeEach(func() {
+		builder = routing_table.TCPMessagesToEmitBuilder{}
+	})
+
+	Describe("RegistrationsFor", func() {
+		BeforeEach(func() {
+			existingEntry = nil
+
+			newEntry = &routing_table.TCPRoutableEndpoints{
+				ExternalEndpoints: map[routing_table.ExternalEndpointInfo]struct{}{external1: struct{}{}},
+				Endpoints:         routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1}),
+			}
+		})
+
+		JustBeforeEach(func() {
+			messages = builder.RegistrationsFor(existingEntry, newEntry)
+		})
+
+		Context("when no existing entry", func() {
+			It("emits a registration", func() {
+				Expect(messages.RegistrationMessages).To(ConsistOf(
+					routing_table.TCPRouteMappingFor(endpoint1, external1),
+				))
+				Expect(messages.UnregistrationMessages).To(BeEmpty())
+			})
+		})
+
+		Context("when new entry has no external endpoints", func() {
+			BeforeEach(func() {
+				newEntry.ExternalEndpoints = map[routing_table.ExternalEndpointInfo]struct{}{}
+			})
+
+			It("emits nothing", func() {
+				Expect(messages).To(BeZero())
+			})
+		})
+
+		Context("when we have an existing entry", func() {
+			Context("when existing == new", func() {
+				BeforeEach(func() {
+					existingEntry = newEntry
+				})
+
+				It("emits nothing", func() {
+					Expect(messages).To(BeZero())
+				})
+			})
+
+			Context("when an external endpoint is added", func() {
+				BeforeEach(func() {
+					existingEntry = &routing_table.TCPRoutableEndpoints{
+						ExternalEndpoints: map[routing_table.ExternalEndpointInfo]struct{}{external1: struct{}{}},
+						Endpoints:         routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1, endpoint2}),
+					}
+
+					newEntry = &routing_table.TCPRoutableEndpoints{
+						ExternalEndpoints: map[routing_table.ExternalEndpointInfo]struct{}{external1: struct{}{}, external2: struct{}{}},
+						Endpoints:         routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1, endpoint2}),
+					}
+				})
+
+				It("registers only the new external endpoint for every backend", func() {
+					Expect(messages.RegistrationMessages).To(ConsistOf(
+						routing_table.TCPRouteMappingFor(endpoint1, external2),
+						routing_table.TCPRouteMappingFor(endpoint2, external2),
+					))
+				})
+			})
+
+			Context("when a backend is added", func() {
+				BeforeEach(func() {
+					existingEntry = &routing_table.TCPRoutableEndpoints{
+						ExternalEndpoints: map[routing_table.ExternalEndpointInfo]struct{}{external1: struct{}{}},
+						Endpoints:         routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1}),
+					}
+
+					newEntry = &routing_table.TCPRoutableEndpoints{
+						ExternalEndpoints: map[routing_table.ExternalEndpointInfo]struct{}{external1: struct{}{}},
+						Endpoints:         routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1, endpoint2}),
+					}
+				})
+
+				It("registers only the new backend", func() {
+					Expect(messages.RegistrationMessages).To(ConsistOf(
+						routing_table.TCPRouteMappingFor(endpoint2, external1),
+					))
+				})
+			})
+		})
+	})
+
+	Describe("UnregistrationsFor", func() {
+		BeforeEach(func() {
+			existingEntry = &routing_table.TCPRoutableEndpoints{
+				ExternalEndpoints: map[routing_table.ExternalEndpointInfo]struct{}{external1: struct{}{}, external2: struct{}{}},
+				Endpoints:         routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1, endpoint2}),
+			}
+
+			newEntry = &routing_table.TCPRoutableEndpoints{
+				ExternalEndpoints: map[routing_table.ExternalEndpointInfo]struct{}{external1: struct{}{}, external2: struct{}{}},
+				Endpoints:         routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1, endpoint2}),
+			}
+		})
+
+		JustBeforeEach(func() {
+			messages = builder.UnregistrationsFor(existingEntry, newEntry)
+		})
+
+		Context("when nothing changes", func() {
+			It("emits nothing", func() {
+				Expect(messages).To(BeZero())
+			})
+		})
+
+		Context("when an external endpoint disappears", func() {
+			BeforeEach(func() {
+				delete(newEntry.ExternalEndpoints, external2)
+			})
+
+			It("unregisters the external endpoint for every backend", func() {
+				Expect(messages.UnregistrationMessages).To(ConsistOf(
+					routing_table.TCPRouteMappingFor(endpoint1, external2),
+					routing_table.TCPRouteMappingFor(endpoint2, external2),
+				))
+				Expect(messages.RegistrationMessages).To(BeEmpty())
+			})
+		})
+
+		Context("when a backend disappears", func() {
+			BeforeEach(func() {
+				newEntry.Endpoints = routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1})
+			})
+
+			It("unregisters the backend from every external endpoint", func() {
+				Expect(messages.UnregistrationMessages).To(ConsistOf(
+					routing_table.TCPRouteMappingFor(endpoint2, external1),
+					routing_table.TCPRouteMappingFor(endpoint2, external2),
+				))
+			})
+		})
+
+		Context("when the new entry is empty", func() {
+			BeforeEach(func() {
+				newEntry = &routing_table.TCPRoutableEndpoints{}
+			})
+
+			It("unregisters everything", func() {
+				Expect(messages.UnregistrationMessages).To(HaveLen(4))
+			})
+		})
+	})
+})
@@ -0,0 +1,17 @@
+package routing_table
+
+type TCPRouteMapping struct {
+	RouterGroupGuid string `json:"router_group_guid"`
+	ExternalPort    uint32 `json:"port"`
+	HostIP          string `json:"backend_ip"`
+	HostPort        uint32 `json:"backend_port"`
+}
+
+func TCPRouteMappingFor(endpoint Endpoint, externalEndpoint ExternalEndpointInfo) TCPRouteMapping {
+	return TCPRouteMapping{
+		RouterGroupGuid: externalEndpoint.RouterGroupGuid,
+		ExternalPort:    externalEndpoint.Port,
+		HostIP:          endpoint.Host,
+		HostPort:        endpoint.Port,
+	}
+}
@@ -0,0 +1,213 @@
+package routing_table
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/bbs/models"
+)
+
+//go:generate counterfeiter -o fake_routing_table/fake_tcp_routing_table.go . TCPRoutingTable
+type TCPRoutingTable interface {
+	RouteCount() int
+
+	Swap(newTable TCPRoutingTable) TCPMessagesToEmit
+	Diff(newTable TCPRoutingTable) TCPMessagesToEmit
+
+	SetRoutes(key RoutingKey, routes TCPRoutes) TCPMessagesToEmit
+	RemoveRoutes(key RoutingKey, modTag *models.ModificationTag) TCPMessagesToEmit
+	AddEndpoint(key RoutingKey, endpoint Endpoint) TCPMessagesToEmit
+	RemoveEndpoint(key RoutingKey, endpoint Endpoint) TCPMessagesToEmit
+
+	MessagesToEmit() TCPMessagesToEmit
+}
+
+type tcpRoutingTable struct {
+	entries map[RoutingKey]TCPRoutableEndpoints
+	sync.Locker
+	messageBuilder TCPMessageBuilder
+}
+
+func NewTempTCPTable(routes TCPRoutesByRoutingKey, endpoints EndpointsByRoutingKey) TCPRoutingTable {
+	entries := make(map[RoutingKey]TCPRoutableEndpoints)
+
+	for key, entry := range routes {
+		entries[key] = TCPRoutableEndpoints{
+			ExternalEndpoints: externalEndpointsAsMap(entry.ExternalEndpoints),
+			LogGuid:           entry.LogGuid,
+			ModificationTag:   entry.ModificationTag,
+		}
+	}
+
+	for key, endpoints := range endpoints {
+		entry, ok := entries[key]
+		if !ok {
+			entry = TCPRoutableEndpoints{}
+		}
+		entry.Endpoints = EndpointsAsMap(endpoints)
+		entries[key] = entry
+	}
+
+	return &tcpRoutingTable{
+		entries:        entries,
+		Locker:         noopLocker{},
+		messageBuilder: NoopTCPMessageBuilder{},
+	}
+}
+
+func NewTCPTable() TCPRoutingTable {
+	return &tcpRoutingTable{
+		entries:        make(map[RoutingKey]TCPRoutableEndpoints),
+		Locker:         &sync.Mutex{},
+		messageBuilder: TCPMessagesToEmitBuilder{},
+	}
+}
+
+func (table *tcpRoutingTable) RouteCount() int {
+	table.Lock()
+
+	count := 0
+	for _, entry := range table.entries {
+		count += len(entry.ExternalEndpoints)
+	}
+
+	table.Unlock()
+	return count
+}
+
+func (table *tcpRoutingTable) Swap(t TCPRoutingTable) TCPMessagesToEmit {
+	messagesToEmit := TCPMessagesToEmit{}
+
+	newTable, ok := t.(*tcpRoutingTable)
+	if !ok {
+		return messagesToEmit
+	}
+	newEntries := newTable.entries
+
+	table.Lock()
+	for _, newEntry := range newEntries {
+		//always register everything on sync
+		messagesToEmit = messagesToEmit.merge(table.messageBuilder.RegistrationsFor(nil, &newEntry))
+	}
+
+	for key, existingEntry := range table.entries {
+		newEntry := newEntries[key]
+		messagesToEmit = messagesToEmit.merge(table.messageBuilder.UnregistrationsFor(&existingEntry, &newEntry))
+	}
+
+	table.entries = newEntries
+	table.Unlock()
+
+	return messagesToEmit
+}
+
+// Diff reports the route mappings Swap would add and remove, without
+// modifying the table.
+func (table *tcpRoutingTable) Diff(t TCPRoutingTable) TCPMessagesToEmit {
+	messagesToEmit := TCPMessagesToEmit{}
+
+	newTable, ok := t.(*tcpRoutingTable)
+	if !ok {
+		return messagesToEmit
+	}
+	newEntries := newTable.entries
+
+	table.Lock()
+	for key, newEntry := range newEntries {
+		existingEntry := table.entries[key]
+		messagesToEmit = messagesToEmit.merge(table.messageBuilder.RegistrationsFor(&existingEntry, &newEntry))
+	}
+
+	for key, existingEntry := range table.entries {
+		newEntry := newEntries[key]
+		messagesToEmit = messagesToEmit.merge(table.messageBuilder.UnregistrationsFor(&existingEntry, &newEntry))
+	}
+	table.Unlock()
+
+	return messagesToEmit
+}
+
+func (table *tcpRoutingTable) MessagesToEmit() TCPMessagesToEmit {
+	table.Lock()
+
+	messagesToEmit := TCPMessagesToEmit{}
+	for _, entry := range table.entries {
+		messagesToEmit = messagesToEmit.merge(table.messageBuilder.RegistrationsFor(nil, &entry))
+	}
+
+	table.Unlock()
+	return messagesToEmit
+}
+
+func (table *tcpRoutingTable) SetRoutes(key RoutingKey, routes TCPRoutes) TCPMessagesToEmit {
+	table.Lock()
+	defer table.Unlock()
+
+	currentEntry := table.entries[key]
+	if !currentEntry.ModificationTag.SucceededBy(routes.ModificationTag) {
+		return TCPMessagesToEmit{}
+	}
+
+	newEntry := currentEntry.copy()
+	newEntry.ExternalEndpoints = externalEndpointsAsMap(routes.ExternalEndpoints)
+	newEntry.LogGuid = routes.LogGuid
+	newEntry.ModificationTag = routes.ModificationTag
+
+	table.entries[key] = newEntry
+
+	return table.emit(key, currentEntry, newEntry)
+}
+
+func (table *tcpRoutingTable) RemoveRoutes(key RoutingKey, modTag *models.ModificationTag) TCPMessagesToEmit {
+	table.Lock()
+	defer table.Unlock()
+
+	currentEntry := table.entries[key]
+	if !(currentEntry.ModificationTag.Equal(modTag) || currentEntry.ModificationTag.SucceededBy(modTag)) {
+		return TCPMessagesToEmit{}
+	}
+
+	newEntry := NewTCPRoutableEndpoints()
+	newEntry.Endpoints = currentEntry.Endpoints
+	newEntry.ModificationTag = modTag
+
+	table.entries[key] = newEntry
+
+	return table.emit(key, currentEntry, newEntry)
+}
+
+func (table *tcpRoutingTable) AddEndpoint(key RoutingKey, endpoint Endpoint) TCPMessagesToEmit {
+	table.Lock()
+	defer table.Unlock()
+
+	currentEntry := table.entries[key]
+	newEntry := currentEntry.copy()
+	newEntry.Endpoints[endpoint.key()] = endpoint
+	table.entries[key] = newEntry
+
+	return table.emit(key, currentEntry, newEntry)
+}
+
+func (table *tcpRoutingTable) RemoveEndpoint(key RoutingKey, endpoint Endpoint) TCPMessagesToEmit {
+	table.Lock()
+	defer table.Unlock()
+
+	currentEntry := table.entries[key]
+	endpointKey := endpoint.key()
+	currentEndpoint, ok := currentEntry.Endpoints[endpointKey]
+	if !ok || !(currentEndpoint.ModificationTag.Equal(endpoint.ModificationTag) || currentEndpoint.ModificationTag.SucceededBy(endpoint.ModificationTag)) {
+		return TCPMessagesToEmit{}
+	}
+
+	newEntry := currentEntry.copy()
+	delete(newEntry.Endpoints, endpointKey)
+	table.entries[key] = newEntry
+
+	return table.emit(key, currentEntry, newEntry)
+}
+
+func (table *tcpRoutingTable) emit(key RoutingKey, oldEntry TCPRoutableEndpoints, newEntry TCPRoutableEndpoints) TCPMessagesToEmit {
+	messagesToEmit := table.messageBuilder.RegistrationsFor(&oldEntry, &newEntry)
+	messagesToEmit = messagesToEmit.merge(table.messageBuilder.UnregistrationsFor(&oldEntry, &newEntry))
+
+	return messagesToEmit
+}
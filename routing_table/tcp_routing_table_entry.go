@@ -0,0 +1,66 @@
+package routing_table
+
+import "github.com/cloudfoundry-incubator/bbs/models"
+
+type ExternalEndpointInfo struct {
+	RouterGroupGuid string
+	Port            uint32
+}
+
+type TCPRoutes struct {
+	ExternalEndpoints []ExternalEndpointInfo
+	LogGuid           string
+	ModificationTag   *models.ModificationTag
+}
+
+type TCPRoutableEndpoints struct {
+	ExternalEndpoints map[ExternalEndpointInfo]struct{}
+	Endpoints         map[EndpointKey]Endpoint
+	LogGuid           string
+	ModificationTag   *models.ModificationTag
+}
+
+func NewTCPRoutableEndpoints() TCPRoutableEndpoints {
+	return TCPRoutableEndpoints{
+		ExternalEndpoints: map[ExternalEndpointInfo]struct{}{},
+		Endpoints:         map[EndpointKey]Endpoint{},
+	}
+}
+
+func (entry TCPRoutableEndpoints) copy() TCPRoutableEndpoints {
+	clone := TCPRoutableEndpoints{
+		ExternalEndpoints: map[ExternalEndpointInfo]struct{}{},
+		Endpoints:         map[EndpointKey]Endpoint{},
+		LogGuid:           entry.LogGuid,
+		ModificationTag:   entry.ModificationTag,
+	}
+
+	for k, v := range entry.ExternalEndpoints {
+		clone.ExternalEndpoints[k] = v
+	}
+
+	for k, v := range entry.Endpoints {
+		clone.Endpoints[k] = v
+	}
+
+	return clone
+}
+
+// routeMappings pairs every external endpoint with every backend endpoint
+func (entry TCPRoutableEndpoints) routeMappings() map[TCPRouteMapping]struct{} {
+	mappings := map[TCPRouteMapping]struct{}{}
+	for externalEndpoint := range entry.ExternalEndpoints {
+		for _, endpoint := range entry.Endpoints {
+			mappings[TCPRouteMappingFor(endpoint, externalEndpoint)] = struct{}{}
+		}
+	}
+	return mappings
+}
+
+func externalEndpointsAsMap(externalEndpoints []ExternalEndpointInfo) map[ExternalEndpointInfo]struct{} {
+	externalEndpointsMap := map[ExternalEndpointInfo]struct{}{}
+	for _, externalEndpoint := range externalEndpoints {
+		externalEndpointsMap[externalEndpoint] = struct{}{}
+	}
+	return externalEndpointsMap
+}
@@ -0,0 +1,236 @@
+package routing_table_test
+
+import (
+	"github.com/cloudfoundry-incubator/bbs/models"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/routing-info/tcp_routes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TCPRoutingTable", func() {
+	var (
+		table          routing_table.TCPRoutingTable
+		messagesToEmit routing_table.TCPMessagesToEmit
+	)
+
+	key := routing_table.RoutingKey{ProcessGuid: "some-process-guid", ContainerPort: 5222}
+
+	external1 := routing_table.ExternalEndpointInfo{RouterGroupGuid: "router-group-guid", Port: 61000}
+	external2 := routing_table.ExternalEndpointInfo{RouterGroupGuid: "router-group-guid", Port: 61001}
+
+	olderTag := &models.ModificationTag{Epoch: "abc", Index: 0}
+	currentTag := &models.ModificationTag{Epoch: "abc", Index: 1}
+	newerTag := &models.ModificationTag{Epoch: "def", Index: 0}
+
+	endpoint1 := routing_table.Endpoint{InstanceGuid: "ig-1", Host: "1.1.1.1", Port: 11, ContainerPort: 5222, Evacuating: false, ModificationTag: currentTag}
+	endpoint2 := routing_table.Endpoint{InstanceGuid: "ig-2", Host: "2.2.2.2", Port: 22, ContainerPort: 5222, Evacuating: false, ModificationTag: currentTag}
+
+	logGuid := "some-log-guid"
+
+	BeforeEach(func() {
+		table = routing_table.NewTCPTable()
+	})
+
+	Describe("Diff", func() {
+		BeforeEach(func() {
+			table.Swap(routing_table.NewTempTCPTable(
+				routing_table.TCPRoutesByRoutingKey{key: routing_table.TCPRoutes{ExternalEndpoints: []routing_table.ExternalEndpointInfo{external1}, LogGuid: logGuid}},
+				routing_table.EndpointsByRoutingKey{key: {endpoint1}},
+			))
+		})
+
+		It("reports only the mappings that would change, without applying them", func() {
+			tempTable := routing_table.NewTempTCPTable(
+				routing_table.TCPRoutesByRoutingKey{key: routing_table.TCPRoutes{ExternalEndpoints: []routing_table.ExternalEndpointInfo{external2}, LogGuid: logGuid}},
+				routing_table.EndpointsByRoutingKey{key: {endpoint1}},
+			)
+
+			messagesToEmit = table.Diff(tempTable)
+			Expect(messagesToEmit.RegistrationMessages).To(ConsistOf(routing_table.TCPRouteMappingFor(endpoint1, external2)))
+			Expect(messagesToEmit.UnregistrationMessages).To(ConsistOf(routing_table.TCPRouteMappingFor(endpoint1, external1)))
+
+			Expect(table.MessagesToEmit().RegistrationMessages).To(ConsistOf(routing_table.TCPRouteMappingFor(endpoint1, external1)))
+		})
+	})
+
+	Describe("Swap", func() {
+		Context("when a new routing key arrives", func() {
+			BeforeEach(func() {
+				tempTable := routing_table.NewTempTCPTable(
+					routing_table.TCPRoutesByRoutingKey{key: routing_table.TCPRoutes{ExternalEndpoints: []routing_table.ExternalEndpointInfo{external1}, LogGuid: logGuid}},
+					routing_table.EndpointsByRoutingKey{key: {endpoint1, endpoint2}},
+				)
+
+				messagesToEmit = table.Swap(tempTable)
+			})
+
+			It("emits registrations for each pairing", func() {
+				Expect(messagesToEmit.RegistrationMessages).To(ConsistOf(
+					routing_table.TCPRouteMappingFor(endpoint1, external1),
+					routing_table.TCPRouteMappingFor(endpoint2, external1),
+				))
+				Expect(messagesToEmit.UnregistrationMessages).To(BeEmpty())
+			})
+
+			It("counts the external endpoints as routes", func() {
+				Expect(table.RouteCount()).To(Equal(1))
+			})
+
+			Context("when the routing key subsequently loses an endpoint", func() {
+				BeforeEach(func() {
+					tempTable := routing_table.NewTempTCPTable(
+						routing_table.TCPRoutesByRoutingKey{key: routing_table.TCPRoutes{ExternalEndpoints: []routing_table.ExternalEndpointInfo{external1}, LogGuid: logGuid}},
+						routing_table.EndpointsByRoutingKey{key: {endpoint1}},
+					)
+
+					messagesToEmit = table.Swap(tempTable)
+				})
+
+				It("re-registers what remains and unregisters the missing endpoint", func() {
+					Expect(messagesToEmit.RegistrationMessages).To(ConsistOf(
+						routing_table.TCPRouteMappingFor(endpoint1, external1),
+					))
+					Expect(messagesToEmit.UnregistrationMessages).To(ConsistOf(
+						routing_table.TCPRouteMappingFor(endpoint2, external1),
+					))
+				})
+			})
+
+			Context("when the routing key subsequently disappears", func() {
+				BeforeEach(func() {
+					messagesToEmit = table.Swap(routing_table.NewTempTCPTable(routing_table.TCPRoutesByRoutingKey{}, routing_table.EndpointsByRoutingKey{}))
+				})
+
+				It("unregisters everything", func() {
+					Expect(messagesToEmit.RegistrationMessages).To(BeEmpty())
+					Expect(messagesToEmit.UnregistrationMessages).To(ConsistOf(
+						routing_table.TCPRouteMappingFor(endpoint1, external1),
+						routing_table.TCPRouteMappingFor(endpoint2, external1),
+					))
+				})
+			})
+		})
+
+		Context("when the process only has endpoints", func() {
+			BeforeEach(func() {
+				tempTable := routing_table.NewTempTCPTable(
+					routing_table.TCPRoutesByRoutingKey{},
+					routing_table.EndpointsByRoutingKey{key: {endpoint1}},
+				)
+				messagesToEmit = table.Swap(tempTable)
+			})
+
+			It("should not emit a registration", func() {
+				Expect(messagesToEmit).To(BeZero())
+			})
+		})
+	})
+
+	Describe("Processing deltas", func() {
+		Context("when the table is empty", func() {
+			It("emits nothing when routes are set", func() {
+				messagesToEmit = table.SetRoutes(key, routing_table.TCPRoutes{ExternalEndpoints: []routing_table.ExternalEndpointInfo{external1}, LogGuid: logGuid})
+				Expect(messagesToEmit).To(BeZero())
+			})
+
+			It("emits nothing when an endpoint is added", func() {
+				messagesToEmit = table.AddEndpoint(key, endpoint1)
+				Expect(messagesToEmit).To(BeZero())
+			})
+		})
+
+		Context("when there are both endpoints and routes in the table", func() {
+			BeforeEach(func() {
+				table.SetRoutes(key, routing_table.TCPRoutes{ExternalEndpoints: []routing_table.ExternalEndpointInfo{external1}, LogGuid: logGuid, ModificationTag: currentTag})
+				table.AddEndpoint(key, endpoint1)
+			})
+
+			Describe("SetRoutes", func() {
+				It("emits nothing when the external endpoints do not change", func() {
+					messagesToEmit = table.SetRoutes(key, routing_table.TCPRoutes{ExternalEndpoints: []routing_table.ExternalEndpointInfo{external1}, LogGuid: logGuid, ModificationTag: newerTag})
+					Expect(messagesToEmit).To(BeZero())
+				})
+
+				It("registers new external endpoints and unregisters old ones", func() {
+					messagesToEmit = table.SetRoutes(key, routing_table.TCPRoutes{ExternalEndpoints: []routing_table.ExternalEndpointInfo{external2}, LogGuid: logGuid, ModificationTag: newerTag})
+					Expect(messagesToEmit.RegistrationMessages).To(ConsistOf(routing_table.TCPRouteMappingFor(endpoint1, external2)))
+					Expect(messagesToEmit.UnregistrationMessages).To(ConsistOf(routing_table.TCPRouteMappingFor(endpoint1, external1)))
+				})
+
+				It("emits nothing when the tag is older", func() {
+					messagesToEmit = table.SetRoutes(key, routing_table.TCPRoutes{ExternalEndpoints: []routing_table.ExternalEndpointInfo{external2}, LogGuid: logGuid, ModificationTag: olderTag})
+					Expect(messagesToEmit).To(BeZero())
+				})
+			})
+
+			Describe("RemoveRoutes", func() {
+				It("emits unregistrations with a newer tag", func() {
+					messagesToEmit = table.RemoveRoutes(key, newerTag)
+					Expect(messagesToEmit.UnregistrationMessages).To(ConsistOf(routing_table.TCPRouteMappingFor(endpoint1, external1)))
+				})
+
+				It("removes the routes from the table", func() {
+					table.RemoveRoutes(key, newerTag)
+					Expect(table.RouteCount()).To(Equal(0))
+					Expect(table.MessagesToEmit()).To(BeZero())
+				})
+
+				It("emits nothing when the tag is older", func() {
+					messagesToEmit = table.RemoveRoutes(key, olderTag)
+					Expect(messagesToEmit).To(BeZero())
+				})
+
+				Context("when routes built from a newer desired LRP are set again", func() {
+					It("registers them", func() {
+						table.RemoveRoutes(key, newerTag)
+
+						schedulingInfo := &models.DesiredLRPSchedulingInfo{
+							DesiredLRPKey:   models.NewDesiredLRPKey(key.ProcessGuid, "tests", logGuid),
+							Routes:          tcp_routes.TCPRoutes{{RouterGroupGuid: "router-group-guid", ExternalPort: 61001, ContainerPort: 5222}}.RoutingInfo(),
+							ModificationTag: models.ModificationTag{Epoch: "def", Index: 1},
+						}
+						routes := routing_table.TCPRoutesByRoutingKeyFromSchedulingInfos([]*models.DesiredLRPSchedulingInfo{schedulingInfo})
+
+						messagesToEmit = table.SetRoutes(key, routes[key])
+						Expect(messagesToEmit.RegistrationMessages).To(ConsistOf(routing_table.TCPRouteMappingFor(endpoint1, external2)))
+					})
+				})
+			})
+
+			Describe("AddEndpoint", func() {
+				It("emits nothing when the endpoint is already present", func() {
+					messagesToEmit = table.AddEndpoint(key, endpoint1)
+					Expect(messagesToEmit).To(BeZero())
+				})
+
+				It("registers a new endpoint", func() {
+					messagesToEmit = table.AddEndpoint(key, endpoint2)
+					Expect(messagesToEmit.RegistrationMessages).To(ConsistOf(routing_table.TCPRouteMappingFor(endpoint2, external1)))
+				})
+			})
+
+			Describe("RemoveEndpoint", func() {
+				It("unregisters the endpoint", func() {
+					messagesToEmit = table.RemoveEndpoint(key, endpoint1)
+					Expect(messagesToEmit.UnregistrationMessages).To(ConsistOf(routing_table.TCPRouteMappingFor(endpoint1, external1)))
+				})
+
+				It("emits nothing when the tag is older", func() {
+					olderEndpoint := endpoint1
+					olderEndpoint.ModificationTag = olderTag
+
+					messagesToEmit = table.RemoveEndpoint(key, olderEndpoint)
+					Expect(messagesToEmit).To(BeZero())
+				})
+			})
+
+			Describe("MessagesToEmit", func() {
+				It("registers every mapping", func() {
+					Expect(table.MessagesToEmit().RegistrationMessages).To(ConsistOf(routing_table.TCPRouteMappingFor(endpoint1, external1)))
+				})
+			})
+		})
+	})
+})
@@ -0,0 +1,144 @@
+package syncer
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/pivotal-golang/lager"
+)
+
+// The reasons for which an emit interval is chosen.
+const (
+	ReasonPruneThreshold          = "prune-threshold"
+	ReasonMinimumRegisterInterval = "minimum-register-interval"
+	ReasonHalfPruneThreshold      = "half-prune-threshold"
+	ReasonMinEmitInterval         = "min-emit-interval"
+	ReasonMaxEmitInterval         = "max-emit-interval"
+	ReasonGreetingTimeout         = "greeting-timeout"
+)
+
+const (
+	defaultMinEmitInterval     = time.Second
+	defaultDefaultEmitInterval = 20 * time.Second
+)
+
+// Options bounds the emit interval that the syncer derives from the router
+// greetings and adds jitter to it, and configures how the syncer greets the
+// routers.
+type Options struct {
+	// MinEmitInterval and MaxEmitInterval bound the emit interval.
+	// MinEmitInterval defaults to one second; MaxEmitInterval is unbounded
+	// if 0.
+	MinEmitInterval time.Duration
+	MaxEmitInterval time.Duration
+
+	// EmitJitter shortens every emit interval by a random fraction of up to
+	// EmitJitter, so that emitters started together do not emit in lockstep.
+	// The interval is never lengthened, as that could let routers prune
+	// routes.
+	EmitJitter float64
+
+	// GreetingTimeout is how long the syncer waits for a router to answer its
+	// greetings before it starts syncing and emitting anyway, every
+	// DefaultEmitInterval, until a router greets it. If 0, the syncer waits
+	// for a router indefinitely. DefaultEmitInterval defaults to 20 seconds.
+	GreetingTimeout     time.Duration
+	DefaultEmitInterval time.Duration
+
+	// GreetingRetryInterval is how often the routers are greeted until one
+	// answers, and the delay before greeting them again after a failed
+	// greeting. The delay doubles with every further failure, up to
+	// MaxGreetingRetryInterval. They default to 1 and 30 seconds.
+	GreetingRetryInterval    time.Duration
+	MaxGreetingRetryInterval time.Duration
+}
+
+// emitSchedule is the emit interval the syncer settled on, with the router
+// and the reason that determined it.
+type emitSchedule struct {
+	interval time.Duration
+	reason   string
+
+	router                  string
+	pruneThreshold          time.Duration
+	minimumRegisterInterval time.Duration
+}
+
+// scheduleFor emits at the interval at which the router asks routes to be
+// registered, or, should it not ask for one, often enough for the router to
+// hear about every route three times before pruning it. The interval is
+// capped at half the prune threshold, so that a single late emit does not get
+// routes pruned.
+func scheduleFor(greeting routerGreeting) emitSchedule {
+	schedule := emitSchedule{
+		interval: greeting.minimumRegisterInterval,
+		reason:   ReasonMinimumRegisterInterval,
+
+		router:                  greeting.id,
+		pruneThreshold:          greeting.pruneThreshold,
+		minimumRegisterInterval: greeting.minimumRegisterInterval,
+	}
+
+	if schedule.interval == 0 {
+		schedule.interval = greeting.pruneThreshold / 3
+		schedule.reason = ReasonPruneThreshold
+	}
+
+	if schedule.interval > greeting.pruneThreshold/2 {
+		schedule.interval = greeting.pruneThreshold / 2
+		schedule.reason = ReasonHalfPruneThreshold
+	}
+
+	return schedule
+}
+
+func (options Options) bound(schedule emitSchedule) emitSchedule {
+	minInterval := options.MinEmitInterval
+	if minInterval == 0 {
+		minInterval = defaultMinEmitInterval
+	}
+
+	if options.MaxEmitInterval > 0 && schedule.interval > options.MaxEmitInterval {
+		schedule.interval = options.MaxEmitInterval
+		schedule.reason = ReasonMaxEmitInterval
+	}
+
+	if schedule.interval < minInterval {
+		schedule.interval = minInterval
+		schedule.reason = ReasonMinEmitInterval
+	}
+
+	return schedule
+}
+
+// fallbackSchedule is the schedule used when no router has answered the
+// greetings within the GreetingTimeout.
+func (options Options) fallbackSchedule() emitSchedule {
+	interval := options.DefaultEmitInterval
+	if interval == 0 {
+		interval = defaultDefaultEmitInterval
+	}
+
+	return options.bound(emitSchedule{
+		interval: interval,
+		reason:   ReasonGreetingTimeout,
+	})
+}
+
+func (options Options) jitter(interval time.Duration, random *rand.Rand) time.Duration {
+	if options.EmitJitter <= 0 {
+		return interval
+	}
+
+	return interval - time.Duration(random.Float64()*options.EmitJitter*float64(interval))
+}
+
+func (schedule emitSchedule) logData() lager.Data {
+	return lager.Data{
+		"interval":                  schedule.interval.String(),
+		"reason":                    schedule.reason,
+		"router":                    schedule.router,
+		"prune-threshold":           schedule.pruneThreshold.String(),
+		"minimum-register-interval": schedule.minimumRegisterInterval.String(),
+	}
+}
@@ -0,0 +1,98 @@
+package syncer
+
+import (
+	"time"
+
+	"github.com/apcera/nats"
+	"github.com/cloudfoundry-incubator/runtime-schema/metric"
+	"github.com/pivotal-golang/lager"
+)
+
+const (
+	routerGreetings        = metric.Counter("RouterGreetings")
+	routerGreetingFailures = metric.Counter("RouterGreetingFailures")
+)
+
+const (
+	defaultGreetingRetryInterval    = time.Second
+	defaultMaxGreetingRetryInterval = 30 * time.Second
+)
+
+// unrecoverableGreetingErrors are the NATS errors after which greeting the
+// routers again cannot succeed: the connection is closed for good, or NATS
+// will never accept the greeting.
+var unrecoverableGreetingErrors = []error{
+	nats.ErrConnectionClosed,
+	nats.ErrBadSubject,
+	nats.ErrAuthorization,
+}
+
+// UnrecoverableGreetingError is returned by Run when the routers cannot be
+// greeted, and retrying would not help. Any other failure to greet the routers
+// is retried.
+type UnrecoverableGreetingError struct {
+	Err error
+}
+
+func (e *UnrecoverableGreetingError) Error() string {
+	return "cannot greet the routers: " + e.Err.Error()
+}
+
+func isUnrecoverable(err error) bool {
+	_, ok := err.(*UnrecoverableGreetingError)
+	return ok
+}
+
+func (options Options) greetingRetryInterval() time.Duration {
+	if options.GreetingRetryInterval == 0 {
+		return defaultGreetingRetryInterval
+	}
+	return options.GreetingRetryInterval
+}
+
+func (options Options) maxGreetingRetryInterval() time.Duration {
+	if options.MaxGreetingRetryInterval == 0 {
+		return defaultMaxGreetingRetryInterval
+	}
+	return options.MaxGreetingRetryInterval
+}
+
+// greetRouters greets the routers, and returns how long to wait before
+// greeting them again if none answers. While greetings fail, that wait
+// doubles from the GreetingRetryInterval up to the MaxGreetingRetryInterval.
+// Failures are returned as they are, unless they are unrecoverable, in which
+// case an *UnrecoverableGreetingError is returned.
+func (s *Syncer) greetRouters(replyUUID string) (time.Duration, error) {
+	routerGreetings.Increment()
+
+	err := s.natsClient.PublishRequest("router.greet", replyUUID, []byte{})
+	if err == nil {
+		s.greetingFailures = 0
+		return s.options.greetingRetryInterval(), nil
+	}
+
+	routerGreetingFailures.Increment()
+	s.greetingFailures++
+
+	for _, unrecoverable := range unrecoverableGreetingErrors {
+		if err == unrecoverable {
+			s.logger.Error("failed-to-greet-router-unrecoverably", err, lager.Data{"attempt": s.greetingFailures})
+			return 0, &UnrecoverableGreetingError{Err: err}
+		}
+	}
+
+	retryIn := s.options.greetingRetryInterval()
+	for i := 1; i < s.greetingFailures && retryIn < s.options.maxGreetingRetryInterval(); i++ {
+		retryIn *= 2
+	}
+	if retryIn > s.options.maxGreetingRetryInterval() {
+		retryIn = s.options.maxGreetingRetryInterval()
+	}
+
+	s.logger.Error("failed-to-greet-router", err, lager.Data{
+		"attempt":  s.greetingFailures,
+		"retry-in": retryIn.String(),
+	})
+
+	return retryIn, err
+}
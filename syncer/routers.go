@@ -0,0 +1,127 @@
+package syncer
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+)
+
+// routerGreeting is a router.start message or a reply to router.greet.
+type routerGreeting struct {
+	id                      string
+	pruneThreshold          time.Duration
+	minimumRegisterInterval time.Duration
+
+	// started is set for router.start messages, which a router sends when it
+	// (re)starts without any routes.
+	started bool
+}
+
+func newRouterGreeting(message routing_table.RouterGreetingMessage, started bool) routerGreeting {
+	return routerGreeting{
+		id:                      routerId(message),
+		pruneThreshold:          time.Duration(message.PruneThresholdInSeconds) * time.Second,
+		minimumRegisterInterval: time.Duration(message.MinimumRegisterInterval) * time.Second,
+		started:                 started,
+	}
+}
+
+// routerId identifies the router that sent the greeting by its id, or failing
+// that by its hosts. Routers that send neither are treated as one router.
+func routerId(message routing_table.RouterGreetingMessage) string {
+	if message.Id != "" {
+		return message.Id
+	}
+
+	hosts := append([]string{}, message.Hosts...)
+	sort.Strings(hosts)
+	return strings.Join(hosts, ",")
+}
+
+type liveRouter struct {
+	routerGreeting
+	lastHeard time.Time
+}
+
+// routerRegistry keeps track of the routers that have greeted the syncer. A
+// router that has not been heard from for its prune threshold is greeted
+// again, and one that stays silent for twice its prune threshold is expired.
+type routerRegistry struct {
+	routers map[string]liveRouter
+}
+
+func newRouterRegistry() *routerRegistry {
+	return &routerRegistry{routers: map[string]liveRouter{}}
+}
+
+func (registry *routerRegistry) heard(greeting routerGreeting, now time.Time) {
+	registry.routers[greeting.id] = liveRouter{routerGreeting: greeting, lastHeard: now}
+}
+
+// expire removes the routers that have been silent for too long, and returns
+// their ids.
+func (registry *routerRegistry) expire(now time.Time) []string {
+	expired := []string{}
+	for id, router := range registry.routers {
+		if now.Sub(router.lastHeard) >= 2*router.pruneThreshold {
+			delete(registry.routers, id)
+			expired = append(expired, id)
+		}
+	}
+	sort.Strings(expired)
+	return expired
+}
+
+// needsGreeting reports whether there are no live routers, or some have not
+// been heard from for their prune threshold.
+func (registry *routerRegistry) needsGreeting(now time.Time) bool {
+	if len(registry.routers) == 0 {
+		return true
+	}
+
+	for _, router := range registry.routers {
+		if now.Sub(router.lastHeard) >= router.pruneThreshold {
+			return true
+		}
+	}
+	return false
+}
+
+// schedule is the bounded emit schedule of the strictest live router, which
+// keeps the routes of every router alive. It is false if there are no live
+// routers.
+func (registry *routerRegistry) schedule(options Options) (emitSchedule, bool) {
+	var strictest emitSchedule
+	found := false
+
+	ids := make([]string, 0, len(registry.routers))
+	for id := range registry.routers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		schedule := scheduleFor(registry.routers[id].routerGreeting)
+		if !found || schedule.interval < strictest.interval {
+			strictest = schedule
+			found = true
+		}
+	}
+
+	if !found {
+		return emitSchedule{}, false
+	}
+
+	return options.bound(strictest), true
+}
+
+func (registry *routerRegistry) known(id string) bool {
+	_, ok := registry.routers[id]
+	return ok
+}
+
+func (registry *routerRegistry) count() int {
+	return len(registry.routers)
+}
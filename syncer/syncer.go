@@ -2,23 +2,37 @@ package syncer
 
 import (
 	"encoding/json"
+	"math/rand"
 	"os"
 	"time"
 
 	"github.com/apcera/nats"
 	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/runtime-schema/metric"
 	"github.com/cloudfoundry/gunk/diegonats"
 	uuid "github.com/nu7hatch/gouuid"
 	"github.com/pivotal-golang/clock"
 	"github.com/pivotal-golang/lager"
 )
 
+const (
+	emitIntervalGauge                  = metric.Duration("RouteEmitInterval")
+	routerPruneThresholdGauge          = metric.Duration("RouterPruneThreshold")
+	routerMinimumRegisterIntervalGauge = metric.Duration("RouterMinimumRegisterInterval")
+	liveRoutersGauge                   = metric.Metric("LiveRouters")
+)
+
 type Syncer struct {
 	natsClient   diegonats.NATSClient
 	clock        clock.Clock
 	syncInterval time.Duration
+	options      Options
+	random       *rand.Rand
 	events       Events
-	routerGreet  chan time.Duration
+	routerGreet  chan routerGreeting
+	routers      *routerRegistry
+
+	greetingFailures int
 
 	logger lager.Logger
 }
@@ -28,18 +42,36 @@ func NewSyncer(
 	syncInterval time.Duration,
 	natsClient diegonats.NATSClient,
 	logger lager.Logger,
+) *Syncer {
+	return NewSyncerWithOptions(clock, syncInterval, natsClient, Options{}, logger)
+}
+
+// NewSyncerWithOptions returns a Syncer whose emit interval is bounded and
+// jittered according to the options. Every change of the emit interval is
+// logged with its reason, and sent as the RouteEmitInterval metric along with
+// the RouterPruneThreshold and RouterMinimumRegisterInterval of the router
+// that determined it.
+func NewSyncerWithOptions(
+	clock clock.Clock,
+	syncInterval time.Duration,
+	natsClient diegonats.NATSClient,
+	options Options,
+	logger lager.Logger,
 ) *Syncer {
 	return &Syncer{
 		natsClient: natsClient,
 
 		clock:        clock,
 		syncInterval: syncInterval,
+		options:      options,
+		random:       rand.New(rand.NewSource(clock.Now().UnixNano())),
 		events: Events{
 			Sync: make(chan struct{}, 1),
 			Emit: make(chan struct{}, 1),
 		},
 
-		routerGreet: make(chan time.Duration),
+		routerGreet: make(chan routerGreeting),
+		routers:     newRouterRegistry(),
 
 		logger: logger.Session("syncer"),
 	}
@@ -60,106 +92,228 @@ func (s *Syncer) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 	close(ready)
 	s.logger.Info("started")
 
-	var routerPruneInterval time.Duration
-	retryGreetingTicker := s.clock.NewTicker(time.Second)
+	var schedule emitSchedule
+	retryGreetingTimer := s.clock.NewTimer(s.options.greetingRetryInterval())
+	defer retryGreetingTimer.Stop()
 
-	//keep trying to greet until we hear from the router
+	var greetingTimeout <-chan time.Time
+	if s.options.GreetingTimeout > 0 {
+		greetingTimer := s.clock.NewTimer(s.options.GreetingTimeout)
+		defer greetingTimer.Stop()
+		greetingTimeout = greetingTimer.C()
+	}
+
+	//keep trying to greet until we hear from the router, or give up waiting
 GREET_LOOP:
 	for {
 		s.logger.Info("greeting-router")
-		err := s.greetRouter(replyUuid.String())
-		if err != nil {
-			s.logger.Error("failed-to-greet-router", err)
+		retryIn, err := s.greetRouters(replyUuid.String())
+		if isUnrecoverable(err) {
 			return err
 		}
+		retryGreetingTimer.Reset(retryIn)
 
 		select {
-		case routerPruneInterval = <-s.routerGreet:
-			s.logger.Info("received-router-prune-interval", lager.Data{"interval": routerPruneInterval.String()})
+		case greeting := <-s.routerGreet:
+			s.heardFromRouter(greeting)
+			schedule, _ = s.routers.schedule(s.options)
+			s.logger.Info("received-router-prune-interval", schedule.logData())
+			recordSchedule(schedule)
+			break GREET_LOOP
+		case <-greetingTimeout:
+			schedule = s.options.fallbackSchedule()
+			s.logger.Info("greeting-timed-out", schedule.logData())
+			recordSchedule(schedule)
 			break GREET_LOOP
-		case <-retryGreetingTicker.C():
+		case <-retryGreetingTimer.C():
 		case <-signals:
 			s.logger.Info("stopping")
 			return nil
 		}
 	}
-	retryGreetingTicker.Stop()
+
+	//if we gave up waiting, keep greeting until a router answers
+	retryGreetings := retryGreetingTimer.C()
+	if s.routers.count() > 0 {
+		retryGreetingTimer.Stop()
+		retryGreetings = nil
+	}
 
 	s.sync()
 
 	//now keep emitting at the desired interval, syncing with etcd every syncInterval
 	syncTicker := s.clock.NewTicker(s.syncInterval)
-	routerTicker := s.clock.NewTicker(routerPruneInterval)
+	defer syncTicker.Stop()
+	emitTimer := s.clock.NewTimer(s.nextEmitInterval(schedule))
+	defer emitTimer.Stop()
 
 	for {
 		select {
-		case routerPruneInterval = <-s.routerGreet:
-			s.logger.Info("received-new-router-prune-interval", lager.Data{"interval": routerPruneInterval.String()})
-			routerTicker.Stop()
-			routerTicker = s.clock.NewTicker(routerPruneInterval)
-			s.emit()
-		case <-routerTicker.C():
+		case greeting := <-s.routerGreet:
+			needsRoutes := s.heardFromRouter(greeting)
+			if retryGreetings != nil {
+				retryGreetingTimer.Stop()
+				retryGreetings = nil
+			}
+
+			newSchedule, _ := s.routers.schedule(s.options)
+			if newSchedule != schedule {
+				s.logger.Info("received-new-router-prune-interval", newSchedule.logData())
+				recordSchedule(newSchedule)
+				if newSchedule.interval != schedule.interval {
+					emitTimer.Reset(s.nextEmitInterval(newSchedule))
+					needsRoutes = true
+				}
+				schedule = newSchedule
+			}
+
+			if needsRoutes {
+				s.emit()
+			}
+		case <-emitTimer.C():
 			s.logger.Info("emitting-routes")
 			s.emit()
+
+			newSchedule := s.checkRouters(schedule)
+			if newSchedule != schedule {
+				s.logger.Info("emit-schedule-changed", newSchedule.logData())
+				recordSchedule(newSchedule)
+				schedule = newSchedule
+			}
+			emitTimer.Reset(s.nextEmitInterval(schedule))
+
+			if retryGreetings == nil && s.routers.needsGreeting(s.clock.Now()) {
+				s.logger.Debug("greeting-routers")
+				retryIn, err := s.greetRouters(replyUuid.String())
+				if isUnrecoverable(err) {
+					return err
+				}
+				if err != nil {
+					retryGreetingTimer.Reset(retryIn)
+					retryGreetings = retryGreetingTimer.C()
+				}
+			}
+		case <-retryGreetings:
+			s.logger.Info("greeting-router")
+			retryIn, err := s.greetRouters(replyUuid.String())
+			if isUnrecoverable(err) {
+				return err
+			}
+			if err == nil && s.routers.count() > 0 {
+				retryGreetings = nil
+				continue
+			}
+			retryGreetingTimer.Reset(retryIn)
 		case <-syncTicker.C():
 			s.logger.Info("syncing")
 			s.sync()
 		case <-signals:
 			s.logger.Info("stopping")
-			syncTicker.Stop()
-			routerTicker.Stop()
 			return nil
 		}
 	}
-
-	return nil
 }
 
 func (s *Syncer) Events() Events {
 	return s.events
 }
 
-func (s *Syncer) emit() {
+func (s *Syncer) emit() bool {
 	select {
 	case s.events.Emit <- struct{}{}:
+		return true
 	default:
 		s.logger.Debug("emit-already-in-progress")
+		return false
 	}
 }
 
-func (s *Syncer) sync() {
+func (s *Syncer) sync() bool {
 	select {
 	case s.events.Sync <- struct{}{}:
+		return true
 	default:
 		s.logger.Debug("sync-already-in-progress")
+		return false
 	}
 }
 
+// listenForRouter subscribes to the router.start messages and the replies to
+// our greetings, from every router.
 func (s *Syncer) listenForRouter(replyUUID string) error {
-	_, err := s.natsClient.Subscribe("router.start", s.handleRouterGreet)
+	_, err := s.natsClient.Subscribe("router.start", func(msg *nats.Msg) {
+		s.handleRouterGreet(msg, true)
+	})
 	if err != nil {
 		return err
 	}
 
-	sub, err := s.natsClient.Subscribe(replyUUID, s.handleRouterGreet)
+	_, err = s.natsClient.Subscribe(replyUUID, func(msg *nats.Msg) {
+		s.handleRouterGreet(msg, false)
+	})
 	if err != nil {
 		return err
 	}
-	sub.AutoUnsubscribe(1)
 
 	return nil
 }
 
-func (s *Syncer) greetRouter(replyUUID string) error {
-	err := s.natsClient.PublishRequest("router.greet", replyUUID, []byte{})
-	if err != nil {
-		return err
+// heardFromRouter records the greeting, and returns whether the router needs
+// the routes right away: it is new, was expired, or has just (re)started.
+// Replies to our greetings from routers that are already known do not need
+// them before the next emit.
+func (s *Syncer) heardFromRouter(greeting routerGreeting) bool {
+	known := s.routers.known(greeting.id)
+	s.routers.heard(greeting, s.clock.Now())
+
+	if !known {
+		s.logger.Info("router-joined", lager.Data{
+			"router":                    greeting.id,
+			"prune-threshold":           greeting.pruneThreshold.String(),
+			"minimum-register-interval": greeting.minimumRegisterInterval.String(),
+			"router-count":              s.routers.count(),
+		})
+		liveRoutersGauge.Send(s.routers.count())
 	}
 
-	return nil
+	return !known || greeting.started
+}
+
+// checkRouters expires the routers that have gone silent, and returns the
+// emit schedule for the remaining ones. If none remain, the current schedule
+// is kept until a router greets us again.
+func (s *Syncer) checkRouters(current emitSchedule) emitSchedule {
+	expired := s.routers.expire(s.clock.Now())
+	for _, id := range expired {
+		s.logger.Info("router-expired", lager.Data{"router": id, "router-count": s.routers.count()})
+	}
+	if len(expired) > 0 {
+		liveRoutersGauge.Send(s.routers.count())
+	}
+
+	schedule, ok := s.routers.schedule(s.options)
+	if !ok {
+		return current
+	}
+	return schedule
+}
+
+// nextEmitInterval is the emit interval of the schedule, with jitter.
+func (s *Syncer) nextEmitInterval(schedule emitSchedule) time.Duration {
+	interval := s.options.jitter(schedule.interval, s.random)
+	if interval != schedule.interval {
+		s.logger.Debug("jittered-emit-interval", lager.Data{"interval": interval.String()})
+	}
+	return interval
+}
+
+func recordSchedule(schedule emitSchedule) {
+	emitIntervalGauge.Send(schedule.interval)
+	routerPruneThresholdGauge.Send(schedule.pruneThreshold)
+	routerMinimumRegisterIntervalGauge.Send(schedule.minimumRegisterInterval)
 }
 
-func (s *Syncer) handleRouterGreet(msg *nats.Msg) {
+func (s *Syncer) handleRouterGreet(msg *nats.Msg, started bool) {
 	var response routing_table.RouterGreetingMessage
 
 	err := json.Unmarshal(msg.Data, &response)
@@ -170,6 +324,5 @@ func (s *Syncer) handleRouterGreet(msg *nats.Msg) {
 		return
 	}
 
-	greetInterval := response.PruneThresholdInSeconds / 3
-	s.routerGreet <- time.Duration(greetInterval) * time.Second
+	s.routerGreet <- newRouterGreeting(response, started)
 }
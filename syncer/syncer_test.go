@@ -1,7 +1,9 @@
 package syncer_test
 
 import (
+	"errors"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/apcera/nats"
@@ -14,11 +16,13 @@ import (
 	"github.com/cloudfoundry/dropsonde/metrics"
 	"github.com/cloudfoundry/gunk/diegonats"
 	"github.com/pivotal-golang/clock/fakeclock"
+	"github.com/pivotal-golang/lager"
 	"github.com/pivotal-golang/lager/lagertest"
 	"github.com/tedsuo/ifrit"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/types"
 )
 
 const logGuid = "some-log-guid"
@@ -41,6 +45,7 @@ var _ = Describe("Syncer", func() {
 		clock          *fakeclock.FakeClock
 		clockStep      time.Duration
 		syncInterval   time.Duration
+		syncerOptions  syncer.Options
 
 		shutdown chan struct{}
 
@@ -49,6 +54,9 @@ var _ = Describe("Syncer", func() {
 
 		routerStartMessages chan<- *nats.Msg
 		fakeMetricSender    *fake_metrics_sender.FakeMetricSender
+		logger              *lagertest.TestLogger
+
+		exitMatcher types.GomegaMatcher
 	)
 
 	BeforeEach(func() {
@@ -58,6 +66,8 @@ var _ = Describe("Syncer", func() {
 		clock = fakeclock.NewFakeClock(time.Now())
 		clockStep = 1 * time.Second
 		syncInterval = 10 * time.Second
+		syncerOptions = syncer.Options{}
+		exitMatcher = BeNil()
 
 		startMessages := make(chan *nats.Msg)
 		routerStartMessages = startMessages
@@ -116,8 +126,8 @@ var _ = Describe("Syncer", func() {
 	})
 
 	JustBeforeEach(func() {
-		logger := lagertest.NewTestLogger("test")
-		syncerRunner = syncer.NewSyncer(clock, syncInterval, natsClient, logger)
+		logger = lagertest.NewTestLogger("test")
+		syncerRunner = syncer.NewSyncerWithOptions(clock, syncInterval, natsClient, syncerOptions, logger)
 
 		shutdown = make(chan struct{})
 
@@ -137,7 +147,7 @@ var _ = Describe("Syncer", func() {
 
 	AfterEach(func() {
 		process.Signal(os.Interrupt)
-		Eventually(process.Wait()).Should(Receive(BeNil()))
+		Eventually(process.Wait()).Should(Receive(exitMatcher))
 		close(shutdown)
 		close(routerStartMessages)
 	})
@@ -173,13 +183,148 @@ var _ = Describe("Syncer", func() {
 					Expect(t2.Sub(t1)).To(BeNumerically("~", 1*time.Second, 200*time.Millisecond))
 				})
 
-				It("should only greet the router once", func() {
+				It("should not greet the router again until it has been silent for its prune threshold", func() {
 					Eventually(greetings).Should(Receive())
-					Consistently(greetings, 1).ShouldNot(Receive())
+					t1 := clock.Now()
+
+					Eventually(greetings, 2).Should(Receive())
+					t2 := clock.Now()
+
+					Expect(t2.Sub(t1)).To(BeNumerically("~", 3*time.Second, 1500*time.Millisecond))
 				})
 			})
 		})
 
+		Context("when the router asks for registrations less often than a third of its prune threshold", func() {
+			JustBeforeEach(func() {
+				routerStartMessages <- &nats.Msg{
+					Data: []byte(`{"minimumRegisterIntervalInSeconds":3, "pruneThresholdInSeconds": 6}`),
+				}
+			})
+
+			It("should emit routes at the minimum register interval", func() {
+				Eventually(syncerRunner.Events().Emit, 4).Should(Receive())
+				t1 := clock.Now()
+
+				Eventually(syncerRunner.Events().Emit, 4).Should(Receive())
+				t2 := clock.Now()
+
+				Expect(t2.Sub(t1)).To(BeNumerically("~", 3*time.Second, 200*time.Millisecond))
+			})
+
+			It("logs the reason for the emit interval", func() {
+				Eventually(logger.LogMessages).Should(ContainElement("test.syncer.received-router-prune-interval"))
+				data := logData(logger, "test.syncer.received-router-prune-interval")
+				Expect(data).To(HaveKeyWithValue("interval", "3s"))
+				Expect(data).To(HaveKeyWithValue("reason", syncer.ReasonMinimumRegisterInterval))
+			})
+
+			It("sends the emit interval and the router's settings as metrics", func() {
+				Eventually(func() float64 {
+					return fakeMetricSender.GetValue("RouteEmitInterval").Value
+				}).Should(BeEquivalentTo(3 * time.Second))
+				Expect(fakeMetricSender.GetValue("RouterPruneThreshold").Value).To(BeEquivalentTo(6 * time.Second))
+				Expect(fakeMetricSender.GetValue("RouterMinimumRegisterInterval").Value).To(BeEquivalentTo(3 * time.Second))
+				Expect(fakeMetricSender.GetValue("LiveRouters").Value).To(BeEquivalentTo(1))
+			})
+		})
+
+		Context("when the router asks for registrations more often than a third of its prune threshold", func() {
+			JustBeforeEach(func() {
+				routerStartMessages <- &nats.Msg{
+					Data: []byte(`{"minimumRegisterIntervalInSeconds":1, "pruneThresholdInSeconds": 6}`),
+				}
+			})
+
+			It("should emit routes at the minimum register interval", func() {
+				Eventually(syncerRunner.Events().Emit, 2).Should(Receive())
+				t1 := clock.Now()
+
+				Eventually(syncerRunner.Events().Emit, 2).Should(Receive())
+				t2 := clock.Now()
+
+				Expect(t2.Sub(t1)).To(BeNumerically("~", 1*time.Second, 200*time.Millisecond))
+			})
+
+			It("logs the reason for the emit interval", func() {
+				Eventually(logger.LogMessages).Should(ContainElement("test.syncer.received-router-prune-interval"))
+				data := logData(logger, "test.syncer.received-router-prune-interval")
+				Expect(data).To(HaveKeyWithValue("interval", "1s"))
+				Expect(data).To(HaveKeyWithValue("reason", syncer.ReasonMinimumRegisterInterval))
+			})
+		})
+
+		Context("when the router does not ask for a register interval", func() {
+			JustBeforeEach(func() {
+				routerStartMessages <- &nats.Msg{
+					Data: []byte(`{"pruneThresholdInSeconds": 6}`),
+				}
+			})
+
+			It("should emit routes at a third of the prune threshold", func() {
+				Eventually(syncerRunner.Events().Emit, 3).Should(Receive())
+				t1 := clock.Now()
+
+				Eventually(syncerRunner.Events().Emit, 3).Should(Receive())
+				t2 := clock.Now()
+
+				Expect(t2.Sub(t1)).To(BeNumerically("~", 2*time.Second, 200*time.Millisecond))
+
+				data := logData(logger, "test.syncer.received-router-prune-interval")
+				Expect(data).To(HaveKeyWithValue("reason", syncer.ReasonPruneThreshold))
+			})
+		})
+
+		Context("when the router asks for registrations less often than half its prune threshold", func() {
+			JustBeforeEach(func() {
+				routerStartMessages <- &nats.Msg{
+					Data: []byte(`{"minimumRegisterIntervalInSeconds":3, "pruneThresholdInSeconds": 3}`),
+				}
+			})
+
+			It("should emit routes at half the prune threshold", func() {
+				Eventually(syncerRunner.Events().Emit, 4).Should(Receive())
+				t1 := clock.Now()
+
+				Eventually(syncerRunner.Events().Emit, 4).Should(Receive())
+				t2 := clock.Now()
+
+				Expect(t2.Sub(t1)).To(BeNumerically("~", 1500*time.Millisecond, 200*time.Millisecond))
+			})
+
+			It("logs the reason for the emit interval", func() {
+				Eventually(logger.LogMessages).Should(ContainElement("test.syncer.received-router-prune-interval"))
+				data := logData(logger, "test.syncer.received-router-prune-interval")
+				Expect(data).To(HaveKeyWithValue("interval", "1.5s"))
+				Expect(data).To(HaveKeyWithValue("reason", syncer.ReasonHalfPruneThreshold))
+			})
+		})
+
+		Context("when the emit interval is bounded", func() {
+			BeforeEach(func() {
+				syncerOptions = syncer.Options{MaxEmitInterval: time.Second}
+			})
+
+			JustBeforeEach(func() {
+				routerStartMessages <- &nats.Msg{
+					Data: []byte(`{"minimumRegisterIntervalInSeconds":2, "pruneThresholdInSeconds": 6}`),
+				}
+			})
+
+			It("should not emit less often than the maximum emit interval", func() {
+				Eventually(syncerRunner.Events().Emit, 2).Should(Receive())
+				t1 := clock.Now()
+
+				Eventually(syncerRunner.Events().Emit, 2).Should(Receive())
+				t2 := clock.Now()
+
+				Expect(t2.Sub(t1)).To(BeNumerically("~", 1*time.Second, 200*time.Millisecond))
+
+				data := logData(logger, "test.syncer.received-router-prune-interval")
+				Expect(data).To(HaveKeyWithValue("reason", syncer.ReasonMaxEmitInterval))
+			})
+		})
+
 		Context("when the router does not emit a router.start", func() {
 			It("should keep greeting the router until it gets an interval", func() {
 				//get the first greeting
@@ -219,6 +364,225 @@ var _ = Describe("Syncer", func() {
 			})
 		})
 
+		Context("when a router restarts with the same interval", func() {
+			var routerStart *nats.Msg
+
+			JustBeforeEach(func() {
+				routerStart = &nats.Msg{
+					Data: []byte(`{"id":"router-a", "minimumRegisterIntervalInSeconds":20, "pruneThresholdInSeconds": 60}`),
+				}
+				routerStartMessages <- routerStart
+				Eventually(logger.LogMessages).Should(ContainElement("test.syncer.router-joined"))
+			})
+
+			It("should emit routes right away instead of waiting for the interval", func() {
+				t1 := clock.Now()
+				routerStartMessages <- routerStart
+
+				Eventually(syncerRunner.Events().Emit).Should(Receive())
+				Expect(clock.Now().Sub(t1)).To(BeNumerically("<", 10*time.Second))
+			})
+		})
+
+		Context("when several routers greet", func() {
+			BeforeEach(func() {
+				natsClient.WhenPublishing("router.greet", func(msg *nats.Msg) error {
+					return nil
+				})
+			})
+
+			JustBeforeEach(func() {
+				routerStartMessages <- &nats.Msg{
+					Data: []byte(`{"id":"router-a", "minimumRegisterIntervalInSeconds":1, "pruneThresholdInSeconds": 6}`),
+				}
+				routerStartMessages <- &nats.Msg{
+					Data: []byte(`{"id":"router-b", "minimumRegisterIntervalInSeconds":1, "pruneThresholdInSeconds": 3}`),
+				}
+				routerStartMessages <- &nats.Msg{
+					Data: []byte(`{"id":"router-a", "minimumRegisterIntervalInSeconds":1, "pruneThresholdInSeconds": 6}`),
+				}
+			})
+
+			It("should emit at the interval of the strictest router", func() {
+				Eventually(logger.LogMessages).Should(ContainElement("test.syncer.router-joined"))
+
+				Eventually(syncerRunner.Events().Emit, 2).Should(Receive())
+				t1 := clock.Now()
+
+				Eventually(syncerRunner.Events().Emit, 2).Should(Receive())
+				t2 := clock.Now()
+
+				Expect(t2.Sub(t1)).To(BeNumerically("~", 1*time.Second, 200*time.Millisecond))
+			})
+		})
+
+		Context("when the strictest router goes silent", func() {
+			BeforeEach(func() {
+				// only router-a answers greetings
+				natsClient.WhenPublishing("router.greet", func(msg *nats.Msg) error {
+					go natsClient.Publish(msg.Reply, []byte(`{"id":"router-a", "minimumRegisterIntervalInSeconds":2, "pruneThresholdInSeconds": 6}`))
+					return nil
+				})
+			})
+
+			JustBeforeEach(func() {
+				routerStartMessages <- &nats.Msg{
+					Data: []byte(`{"id":"router-b", "minimumRegisterIntervalInSeconds":1, "pruneThresholdInSeconds": 3}`),
+				}
+			})
+
+			It("should expire it and relax to the interval of the remaining routers", func() {
+				Eventually(logger.LogMessages, 2).Should(ContainElement("test.syncer.router-expired"))
+
+				Eventually(syncerRunner.Events().Emit, 2).Should(Receive())
+				t1 := clock.Now()
+
+				Eventually(syncerRunner.Events().Emit, 2).Should(Receive())
+				t2 := clock.Now()
+
+				Expect(t2.Sub(t1)).To(BeNumerically("~", 2*time.Second, 200*time.Millisecond))
+			})
+		})
+
+		Context("when no router answers the greetings within the greeting timeout", func() {
+			var greetingCount chan struct{}
+
+			BeforeEach(func() {
+				syncerOptions = syncer.Options{
+					GreetingTimeout:     3 * time.Second,
+					DefaultEmitInterval: 2 * time.Second,
+				}
+
+				greetingCount = make(chan struct{}, 100)
+				natsClient.WhenPublishing("router.greet", func(msg *nats.Msg) error {
+					select {
+					case greetingCount <- struct{}{}:
+					default:
+					}
+					return nil
+				})
+			})
+
+			It("should fall back to emitting at the default prune interval", func() {
+				Eventually(logger.LogMessages).Should(ContainElement("test.syncer.greeting-timed-out"))
+				Expect(logData(logger, "test.syncer.greeting-timed-out")).To(HaveKeyWithValue("reason", syncer.ReasonGreetingTimeout))
+
+				Eventually(syncerRunner.Events().Emit, 2).Should(Receive())
+				t1 := clock.Now()
+
+				Eventually(syncerRunner.Events().Emit, 2).Should(Receive())
+				t2 := clock.Now()
+
+				Expect(t2.Sub(t1)).To(BeNumerically("~", 2*time.Second, 200*time.Millisecond))
+			})
+
+			It("should sync", func() {
+				Eventually(syncerRunner.Events().Sync).Should(Receive())
+			})
+
+			It("should keep greeting the routers", func() {
+				Eventually(logger.LogMessages).Should(ContainElement("test.syncer.greeting-timed-out"))
+				for len(greetingCount) > 0 {
+					<-greetingCount
+				}
+
+				Eventually(greetingCount, 2).Should(Receive())
+			})
+
+			Context("when a router greets later", func() {
+				It("should switch to the router's interval", func() {
+					Eventually(logger.LogMessages).Should(ContainElement("test.syncer.greeting-timed-out"))
+
+					routerStartMessages <- &nats.Msg{
+						Data: []byte(`{"minimumRegisterIntervalInSeconds":1, "pruneThresholdInSeconds": 3}`),
+					}
+					Eventually(logger.LogMessages).Should(ContainElement("test.syncer.received-new-router-prune-interval"))
+
+					Eventually(syncerRunner.Events().Emit).Should(Receive())
+					t1 := clock.Now()
+
+					Eventually(syncerRunner.Events().Emit, 2).Should(Receive())
+					t2 := clock.Now()
+
+					Expect(t2.Sub(t1)).To(BeNumerically("~", 1*time.Second, 200*time.Millisecond))
+				})
+			})
+		})
+
+		Context("when greeting the routers fails", func() {
+			var (
+				lock       sync.Mutex
+				greetedAt  []time.Time
+				greetError error
+			)
+
+			BeforeEach(func() {
+				greetedAt = nil
+				greetError = errors.New("nats: disconnected")
+
+				natsClient.WhenPublishing("router.greet", func(msg *nats.Msg) error {
+					lock.Lock()
+					defer lock.Unlock()
+					greetedAt = append(greetedAt, clock.Now())
+					return greetError
+				})
+			})
+
+			greetings := func() []time.Time {
+				lock.Lock()
+				defer lock.Unlock()
+				return append([]time.Time{}, greetedAt...)
+			}
+
+			It("keeps greeting the routers, backing off between attempts", func() {
+				Eventually(greetings, 3).Should(HaveLen(4))
+				Consistently(process.Wait()).ShouldNot(Receive())
+
+				attempts := greetings()
+				Expect(attempts[1].Sub(attempts[0])).To(BeNumerically("~", 1*time.Second, 200*time.Millisecond))
+				Expect(attempts[2].Sub(attempts[1])).To(BeNumerically("~", 2*time.Second, 200*time.Millisecond))
+				Expect(attempts[3].Sub(attempts[2])).To(BeNumerically("~", 4*time.Second, 200*time.Millisecond))
+			})
+
+			It("counts the greetings and the failures", func() {
+				Eventually(greetings).Should(HaveLen(2))
+
+				Eventually(func() uint64 {
+					return fakeMetricSender.GetCounter("RouterGreetingFailures")
+				}).Should(BeEquivalentTo(2))
+				Expect(fakeMetricSender.GetCounter("RouterGreetings")).To(BeEquivalentTo(2))
+			})
+
+			Context("when the routers can be greeted again", func() {
+				It("starts emitting once a router answers", func() {
+					Eventually(greetings, 2).Should(HaveLen(2))
+
+					lock.Lock()
+					greetError = nil
+					lock.Unlock()
+
+					routerStartMessages <- &nats.Msg{
+						Data: []byte(`{"minimumRegisterIntervalInSeconds":1, "pruneThresholdInSeconds": 3}`),
+					}
+
+					Eventually(syncerRunner.Events().Emit, 2).Should(Receive())
+				})
+			})
+
+			Context("when the failure is unrecoverable", func() {
+				BeforeEach(func() {
+					greetError = nats.ErrConnectionClosed
+					exitMatcher = BeAssignableToTypeOf(&syncer.UnrecoverableGreetingError{})
+				})
+
+				It("exits with the error", func() {
+					var err error
+					Eventually(process.Wait()).Should(Receive(&err))
+					Expect(err).To(Equal(&syncer.UnrecoverableGreetingError{Err: nats.ErrConnectionClosed}))
+				})
+			})
+		})
+
 		Context("if it never hears anything from a router anywhere", func() {
 			It("should still be able to shutdown", func() {
 				process.Signal(os.Interrupt)
@@ -278,3 +642,12 @@ var _ = Describe("Syncer", func() {
 		})
 	})
 })
+
+func logData(logger *lagertest.TestLogger, message string) lager.Data {
+	for _, log := range logger.Logs() {
+		if log.Message == message {
+			return log.Data
+		}
+	}
+	return nil
+}
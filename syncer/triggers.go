@@ -0,0 +1,72 @@
+package syncer
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/pivotal-golang/lager"
+)
+
+// The signals on which a SignalTrigger requests a sync and an emit.
+const (
+	SyncSignal = syscall.SIGUSR1
+	EmitSignal = syscall.SIGUSR2
+)
+
+// TriggerSync requests an immediate sync on behalf of the source. Like the
+// syncs on the sync interval, it is dropped if a sync is already pending. It
+// returns whether the sync was queued: the watcher still drops a queued sync
+// if it receives it while a sync is running.
+func (s *Syncer) TriggerSync(source string) bool {
+	requested := s.sync()
+	s.logger.Info("sync-triggered", lager.Data{"source": source, "requested": requested})
+	return requested
+}
+
+// TriggerEmit requests an immediate emit of all routes on behalf of the
+// source. Like the emits on the emit interval, it is dropped if an emit is
+// already pending. It returns whether the emit was requested.
+func (s *Syncer) TriggerEmit(source string) bool {
+	requested := s.emit()
+	s.logger.Info("emit-triggered", lager.Data{"source": source, "requested": requested})
+	return requested
+}
+
+// SignalTrigger triggers a sync of the syncer when the process receives
+// SyncSignal, and an emit when it receives EmitSignal.
+type SignalTrigger struct {
+	syncer *Syncer
+	logger lager.Logger
+}
+
+func NewSignalTrigger(syncer *Syncer, logger lager.Logger) *SignalTrigger {
+	return &SignalTrigger{
+		syncer: syncer,
+		logger: logger.Session("signal-trigger"),
+	}
+}
+
+func (t *SignalTrigger) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	triggers := make(chan os.Signal, 1)
+	signal.Notify(triggers, SyncSignal, EmitSignal)
+	defer signal.Stop(triggers)
+
+	close(ready)
+	t.logger.Info("started")
+
+	for {
+		select {
+		case sig := <-triggers:
+			switch sig {
+			case SyncSignal:
+				t.syncer.TriggerSync(sig.String())
+			case EmitSignal:
+				t.syncer.TriggerEmit(sig.String())
+			}
+		case <-signals:
+			t.logger.Info("stopped")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package syncer_test
+
+import (
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/syncer"
+	"github.com/cloudfoundry/gunk/diegonats"
+	"github.com/pivotal-golang/clock/fakeclock"
+	"github.com/pivotal-golang/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Triggers", func() {
+	var (
+		syncerRunner *syncer.Syncer
+		logger       *lagertest.TestLogger
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		syncerRunner = syncer.NewSyncer(fakeclock.NewFakeClock(time.Now()), time.Minute, diegonats.NewFakeClient(), logger)
+	})
+
+	Describe("TriggerSync", func() {
+		It("requests a sync through the sync events", func() {
+			Expect(syncerRunner.TriggerSync("test")).To(BeTrue())
+			Expect(syncerRunner.Events().Sync).To(Receive())
+		})
+
+		It("logs the source of the trigger", func() {
+			syncerRunner.TriggerSync("test")
+			Expect(logger.LogMessages()).To(ContainElement("test.syncer.sync-triggered"))
+			Expect(logData(logger, "test.syncer.sync-triggered")).To(HaveKeyWithValue("source", "test"))
+		})
+
+		Context("when a sync is already pending", func() {
+			It("does not request another", func() {
+				Expect(syncerRunner.TriggerSync("test")).To(BeTrue())
+				Expect(syncerRunner.TriggerSync("test")).To(BeFalse())
+
+				Expect(syncerRunner.Events().Sync).To(Receive())
+				Expect(syncerRunner.Events().Sync).NotTo(Receive())
+			})
+		})
+	})
+
+	Describe("TriggerEmit", func() {
+		It("requests an emit through the emit events", func() {
+			Expect(syncerRunner.TriggerEmit("test")).To(BeTrue())
+			Expect(syncerRunner.Events().Emit).To(Receive())
+		})
+
+		It("logs the source of the trigger", func() {
+			syncerRunner.TriggerEmit("test")
+			Expect(logData(logger, "test.syncer.emit-triggered")).To(HaveKeyWithValue("source", "test"))
+		})
+
+		Context("when an emit is already pending", func() {
+			It("does not request another", func() {
+				Expect(syncerRunner.TriggerEmit("test")).To(BeTrue())
+				Expect(syncerRunner.TriggerEmit("test")).To(BeFalse())
+			})
+		})
+	})
+
+	Describe("SignalTrigger", func() {
+		var process ifrit.Process
+
+		BeforeEach(func() {
+			process = ifrit.Invoke(syncer.NewSignalTrigger(syncerRunner, logger))
+		})
+
+		AfterEach(func() {
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive(BeNil()))
+		})
+
+		It("triggers a sync on SIGUSR1", func() {
+			Expect(syscall.Kill(os.Getpid(), syscall.SIGUSR1)).To(Succeed())
+
+			Eventually(syncerRunner.Events().Sync).Should(Receive())
+			Expect(logData(logger, "test.syncer.sync-triggered")).To(HaveKeyWithValue("source", syscall.SIGUSR1.String()))
+		})
+
+		It("triggers an emit on SIGUSR2", func() {
+			Expect(syscall.Kill(os.Getpid(), syscall.SIGUSR2)).To(Succeed())
+
+			Eventually(syncerRunner.Events().Emit).Should(Receive())
+			Expect(logData(logger, "test.syncer.emit-triggered")).To(HaveKeyWithValue("source", syscall.SIGUSR2.String()))
+		})
+	})
+})
@@ -0,0 +1,54 @@
+// This file was generated by counterfeiter
+package fake_tcp_emitter
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/tcp_emitter"
+)
+
+type FakeTCPEmitter struct {
+	EmitStub        func(messagesToEmit routing_table.TCPMessagesToEmit) error
+	emitMutex       sync.RWMutex
+	emitArgsForCall []struct {
+		messagesToEmit routing_table.TCPMessagesToEmit
+	}
+	emitReturns struct {
+		result1 error
+	}
+}
+
+func (fake *FakeTCPEmitter) Emit(messagesToEmit routing_table.TCPMessagesToEmit) error {
+	fake.emitMutex.Lock()
+	fake.emitArgsForCall = append(fake.emitArgsForCall, struct {
+		messagesToEmit routing_table.TCPMessagesToEmit
+	}{messagesToEmit})
+	fake.emitMutex.Unlock()
+	if fake.EmitStub != nil {
+		return fake.EmitStub(messagesToEmit)
+	} else {
+		return fake.emitReturns.result1
+	}
+}
+
+func (fake *FakeTCPEmitter) EmitCallCount() int {
+	fake.emitMutex.RLock()
+	defer fake.emitMutex.RUnlock()
+	return len(fake.emitArgsForCall)
+}
+
+func (fake *FakeTCPEmitter) EmitArgsForCall(i int) routing_table.TCPMessagesToEmit {
+	fake.emitMutex.RLock()
+	defer fake.emitMutex.RUnlock()
+	return fake.emitArgsForCall[i].messagesToEmit
+}
+
+func (fake *FakeTCPEmitter) EmitReturns(result1 error) {
+	fake.EmitStub = nil
+	fake.emitReturns = struct {
+		result1 error
+	}{result1}
+}
+
+var _ tcp_emitter.TCPEmitter = new(FakeTCPEmitter)
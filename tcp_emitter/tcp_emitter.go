@@ -0,0 +1,136 @@
+package tcp_emitter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_api_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/pivotal-golang/lager"
+)
+
+const (
+	CreateTCPRouteMappingsPath = "/routing/v1/tcp_routes/create"
+	DeleteTCPRouteMappingsPath = "/routing/v1/tcp_routes/delete"
+)
+
+//go:generate counterfeiter -o fake_tcp_emitter/fake_tcp_emitter.go . TCPEmitter
+type TCPEmitter interface {
+	Emit(messagesToEmit routing_table.TCPMessagesToEmit) error
+}
+
+// tcpRouteMapping is the routing API representation of a TCP route mapping.
+type tcpRouteMapping struct {
+	routing_table.TCPRouteMapping
+	TTL int `json:"ttl,omitempty"`
+}
+
+type tcpEmitter struct {
+	routingAPIURL string
+	httpClient    *http.Client
+	tokenFetcher  routing_api_emitter.TokenFetcher
+	ttl           int
+	logger        lager.Logger
+}
+
+// New returns a TCPEmitter that creates and deletes TCP route mappings with
+// the routing API. Mappings are created with a TTL derived from pruneInterval,
+// as the HTTP routes of the routing API emitter are. Requests carry a bearer
+// token from tokenFetcher, unless it is nil; a token rejected by the routing
+// API is refreshed once.
+func New(
+	routingAPIURL string,
+	httpClient *http.Client,
+	tokenFetcher routing_api_emitter.TokenFetcher,
+	pruneInterval time.Duration,
+	logger lager.Logger,
+) TCPEmitter {
+	return &tcpEmitter{
+		routingAPIURL: routingAPIURL,
+		httpClient:    httpClient,
+		tokenFetcher:  tokenFetcher,
+		ttl:           routing_api_emitter.TTLForPruneInterval(pruneInterval),
+		logger:        logger.Session("tcp-emitter"),
+	}
+}
+
+func (t *tcpEmitter) Emit(messagesToEmit routing_table.TCPMessagesToEmit) error {
+	var finalError error
+
+	if len(messagesToEmit.RegistrationMessages) > 0 {
+		err := t.post(CreateTCPRouteMappingsPath, messagesToEmit.RegistrationMessages, t.ttl)
+		if err != nil {
+			finalError = err
+		}
+	}
+
+	if len(messagesToEmit.UnregistrationMessages) > 0 {
+		err := t.post(DeleteTCPRouteMappingsPath, messagesToEmit.UnregistrationMessages, 0)
+		if err != nil {
+			finalError = err
+		}
+	}
+
+	return finalError
+}
+
+func (t *tcpEmitter) post(path string, mappings []routing_table.TCPRouteMapping, ttl int) error {
+	logger := t.logger.Session("post", lager.Data{"path": path, "num-mappings": len(mappings)})
+	logger.Debug("emit", lager.Data{"mappings": mappings})
+
+	apiMappings := make([]tcpRouteMapping, 0, len(mappings))
+	for _, mapping := range mappings {
+		apiMappings = append(apiMappings, tcpRouteMapping{TCPRouteMapping: mapping, TTL: ttl})
+	}
+
+	payload, err := json.Marshal(apiMappings)
+	if err != nil {
+		logger.Error("failed-to-marshal", err)
+		return err
+	}
+
+	status, err := t.do(path, payload, false)
+	if err == nil && status == http.StatusUnauthorized && t.tokenFetcher != nil {
+		logger.Info("refreshing-token")
+		status, err = t.do(path, payload, true)
+	}
+	if err != nil {
+		logger.Error("failed-to-post", err)
+		return err
+	}
+
+	if status < 200 || status >= 300 {
+		err = fmt.Errorf("routing api responded with status %d", status)
+		logger.Error("unexpected-response", err)
+		return err
+	}
+
+	return nil
+}
+
+func (t *tcpEmitter) do(path string, payload []byte, forceTokenUpdate bool) (int, error) {
+	request, err := http.NewRequest("POST", t.routingAPIURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	if t.tokenFetcher != nil {
+		token, err := t.tokenFetcher.FetchToken(forceTokenUpdate)
+		if err != nil {
+			return 0, err
+		}
+		request.Header.Set("Authorization", "bearer "+token)
+	}
+
+	resp, err := t.httpClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
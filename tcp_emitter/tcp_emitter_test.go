@@ -0,0 +1,183 @@
+package tcp_emitter_test
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_api_emitter/fake_routing_api_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/tcp_emitter"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TCPEmitter", func() {
+	var (
+		emitter          tcp_emitter.TCPEmitter
+		routingAPIServer *ghttp.Server
+		tokenFetcher     *fake_routing_api_emitter.FakeTokenFetcher
+	)
+
+	messagesToEmit := routing_table.TCPMessagesToEmit{
+		RegistrationMessages: []routing_table.TCPRouteMapping{
+			{RouterGroupGuid: "router-group-guid", ExternalPort: 61000, HostIP: "1.1.1.1", HostPort: 11},
+			{RouterGroupGuid: "router-group-guid", ExternalPort: 61000, HostIP: "2.2.2.2", HostPort: 22},
+		},
+		UnregistrationMessages: []routing_table.TCPRouteMapping{
+			{RouterGroupGuid: "router-group-guid", ExternalPort: 61001, HostIP: "3.3.3.3", HostPort: 33},
+		},
+	}
+
+	BeforeEach(func() {
+		routingAPIServer = ghttp.NewServer()
+		tokenFetcher = new(fake_routing_api_emitter.FakeTokenFetcher)
+		tokenFetcher.FetchTokenReturns("some-token", nil)
+	})
+
+	JustBeforeEach(func() {
+		logger := lagertest.NewTestLogger("test")
+		emitter = tcp_emitter.New(routingAPIServer.URL(), &http.Client{}, tokenFetcher, 20*time.Second, logger)
+	})
+
+	AfterEach(func() {
+		routingAPIServer.Close()
+	})
+
+	Describe("Emitting", func() {
+		Context("when the routing api accepts the mappings", func() {
+			BeforeEach(func() {
+				routingAPIServer.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", tcp_emitter.CreateTCPRouteMappingsPath),
+						ghttp.VerifyContentType("application/json"),
+						ghttp.VerifyHeaderKV("Authorization", "bearer some-token"),
+						ghttp.VerifyJSON(`[
+							{"router_group_guid": "router-group-guid", "port": 61000, "backend_ip": "1.1.1.1", "backend_port": 11, "ttl": 60},
+							{"router_group_guid": "router-group-guid", "port": 61000, "backend_ip": "2.2.2.2", "backend_port": 22, "ttl": 60}
+						]`),
+						ghttp.RespondWith(http.StatusCreated, nil),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", tcp_emitter.DeleteTCPRouteMappingsPath),
+						ghttp.VerifyHeaderKV("Authorization", "bearer some-token"),
+						ghttp.VerifyJSON(`[
+							{"router_group_guid": "router-group-guid", "port": 61001, "backend_ip": "3.3.3.3", "backend_port": 33}
+						]`),
+						ghttp.RespondWith(http.StatusNoContent, nil),
+					),
+				)
+			})
+
+			It("creates the mappings with a TTL of three prune intervals, and deletes the mappings", func() {
+				err := emitter.Emit(messagesToEmit)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(routingAPIServer.ReceivedRequests()).To(HaveLen(2))
+			})
+		})
+
+		Context("when there is nothing to emit", func() {
+			It("does not call the routing api", func() {
+				err := emitter.Emit(routing_table.TCPMessagesToEmit{})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(routingAPIServer.ReceivedRequests()).To(BeEmpty())
+			})
+		})
+
+		Context("when the routing api rejects the token", func() {
+			BeforeEach(func() {
+				tokenFetcher.FetchTokenStub = func(forceUpdate bool) (string, error) {
+					if forceUpdate {
+						return "new-token", nil
+					}
+					return "old-token", nil
+				}
+
+				routingAPIServer.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyHeaderKV("Authorization", "bearer old-token"),
+						ghttp.RespondWith(http.StatusUnauthorized, nil),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", tcp_emitter.CreateTCPRouteMappingsPath),
+						ghttp.VerifyHeaderKV("Authorization", "bearer new-token"),
+						ghttp.RespondWith(http.StatusCreated, nil),
+					),
+				)
+			})
+
+			It("refreshes the token and retries", func() {
+				err := emitter.Emit(routing_table.TCPMessagesToEmit{
+					RegistrationMessages: messagesToEmit.RegistrationMessages,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(routingAPIServer.ReceivedRequests()).To(HaveLen(2))
+				Expect(tokenFetcher.FetchTokenArgsForCall(1)).To(BeTrue())
+			})
+		})
+
+		Context("when a token cannot be fetched", func() {
+			BeforeEach(func() {
+				tokenFetcher.FetchTokenReturns("", errors.New("no token"))
+			})
+
+			It("returns an error without calling the routing api", func() {
+				err := emitter.Emit(messagesToEmit)
+				Expect(err).To(MatchError("no token"))
+				Expect(routingAPIServer.ReceivedRequests()).To(BeEmpty())
+			})
+		})
+
+		Context("when there is no token fetcher", func() {
+			JustBeforeEach(func() {
+				emitter = tcp_emitter.New(routingAPIServer.URL(), &http.Client{}, nil, 20*time.Second, lagertest.NewTestLogger("test"))
+
+				routingAPIServer.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", tcp_emitter.CreateTCPRouteMappingsPath),
+						func(w http.ResponseWriter, req *http.Request) {
+							Expect(req.Header.Get("Authorization")).To(BeEmpty())
+						},
+						ghttp.RespondWith(http.StatusCreated, nil),
+					),
+				)
+			})
+
+			It("sends unauthenticated requests", func() {
+				err := emitter.Emit(routing_table.TCPMessagesToEmit{
+					RegistrationMessages: messagesToEmit.RegistrationMessages,
+				})
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when the routing api responds with an error", func() {
+			BeforeEach(func() {
+				routingAPIServer.AppendHandlers(
+					ghttp.RespondWith(http.StatusInternalServerError, nil),
+					ghttp.RespondWith(http.StatusNoContent, nil),
+				)
+			})
+
+			It("still attempts the unregistrations and returns an error", func() {
+				err := emitter.Emit(messagesToEmit)
+				Expect(err).To(HaveOccurred())
+				Expect(routingAPIServer.ReceivedRequests()).To(HaveLen(2))
+			})
+		})
+
+		Context("when the routing api cannot be reached", func() {
+			BeforeEach(func() {
+				routingAPIServer.Close()
+			})
+
+			It("returns an error", func() {
+				err := emitter.Emit(messagesToEmit)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+})
@@ -0,0 +1,144 @@
+package tcp_routes_test
+
+import (
+	"encoding/json"
+
+	"github.com/cloudfoundry-incubator/bbs/models"
+	"github.com/cloudfoundry-incubator/routing-info/cfroutes"
+	"github.com/cloudfoundry-incubator/routing-info/tcp_routes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RoutingInfoHelpers", func() {
+	var (
+		route1 tcp_routes.TCPRoute
+		route2 tcp_routes.TCPRoute
+
+		routes tcp_routes.TCPRoutes
+	)
+
+	BeforeEach(func() {
+		route1 = tcp_routes.TCPRoute{
+			RouterGroupGuid: "router-group-guid",
+			ExternalPort:    61000,
+			ContainerPort:   5222,
+		}
+		route2 = tcp_routes.TCPRoute{
+			RouterGroupGuid: "router-group-guid",
+			ExternalPort:    61001,
+			ContainerPort:   5223,
+		}
+
+		routes = tcp_routes.TCPRoutes{route1, route2}
+	})
+
+	Describe("RoutingInfo", func() {
+		var routingInfo models.Routes
+
+		JustBeforeEach(func() {
+			routingInfo = routes.RoutingInfo()
+		})
+
+		It("wraps the serialized routes with the correct key", func() {
+			expectedBytes, err := json.Marshal(routes)
+			Expect(err).NotTo(HaveOccurred())
+
+			payload, err := routingInfo[tcp_routes.TCP_ROUTER].MarshalJSON()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(payload).To(MatchJSON(expectedBytes))
+		})
+
+		It("serializes the router group guid and ports", func() {
+			payload, err := routingInfo[tcp_routes.TCP_ROUTER].MarshalJSON()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(payload).To(MatchJSON(`[
+				{"router_group_guid": "router-group-guid", "external_port": 61000, "container_port": 5222},
+				{"router_group_guid": "router-group-guid", "external_port": 61001, "container_port": 5223}
+			]`))
+		})
+
+		Context("when TCPRoutes is empty", func() {
+			BeforeEach(func() {
+				routes = tcp_routes.TCPRoutes{}
+			})
+
+			It("marshals an empty list", func() {
+				payload, err := routingInfo[tcp_routes.TCP_ROUTER].MarshalJSON()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(payload).To(MatchJSON(`[]`))
+			})
+		})
+	})
+
+	Describe("TCPRoutesFromRoutingInfo", func() {
+		var (
+			routesResult    tcp_routes.TCPRoutes
+			conversionError error
+
+			routingInfo models.Routes
+		)
+
+		JustBeforeEach(func() {
+			routesResult, conversionError = tcp_routes.TCPRoutesFromRoutingInfo(routingInfo)
+		})
+
+		Context("when TCP routes are present in the routing info", func() {
+			BeforeEach(func() {
+				routingInfo = routes.RoutingInfo()
+			})
+
+			It("returns the routes", func() {
+				Expect(routes).To(Equal(routesResult))
+			})
+
+			Context("when the TCP routes are nil", func() {
+				BeforeEach(func() {
+					routingInfo = models.Routes{tcp_routes.TCP_ROUTER: nil}
+				})
+
+				It("returns nil routes", func() {
+					Expect(conversionError).NotTo(HaveOccurred())
+					Expect(routesResult).To(BeNil())
+				})
+			})
+		})
+
+		Context("when only CF routes are present in the routing info", func() {
+			BeforeEach(func() {
+				routingInfo = cfroutes.CFRoutes{{Hostnames: []string{"foo.example.com"}, Port: 8080}}.RoutingInfo()
+			})
+
+			It("returns nil routes", func() {
+				Expect(conversionError).NotTo(HaveOccurred())
+				Expect(routesResult).To(BeNil())
+			})
+		})
+
+		Context("when the TCP routes are malformed", func() {
+			BeforeEach(func() {
+				data := json.RawMessage(`{"not":"a list"}`)
+				routingInfo = models.Routes{tcp_routes.TCP_ROUTER: &data}
+			})
+
+			It("returns an error", func() {
+				Expect(conversionError).To(HaveOccurred())
+			})
+		})
+
+		Context("when the routing info is nil", func() {
+			BeforeEach(func() {
+				routingInfo = nil
+			})
+
+			It("returns nil routes", func() {
+				Expect(conversionError).NotTo(HaveOccurred())
+				Expect(routesResult).To(BeNil())
+			})
+		})
+	})
+})
@@ -0,0 +1,296 @@
+package template_emitter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"text/template"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/route_sink"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/runtime-schema/metric"
+	"github.com/pivotal-golang/clock"
+	"github.com/pivotal-golang/lager"
+)
+
+const (
+	configRenders       = metric.Counter("TemplateEmitterConfigRenders")
+	configRenderFailure = metric.Counter("TemplateEmitterConfigRenderFailures")
+	configReloadFailure = metric.Counter("TemplateEmitterConfigReloadFailures")
+)
+
+// Config is the data the template is executed with. Routes are sorted by URI
+// and their backends by host and port.
+type Config struct {
+	Routes []Route
+}
+
+type Route struct {
+	URI      string
+	Hostname string
+	Path     string
+	Backends []Backend
+}
+
+type Backend struct {
+	Host              string
+	Port              uint32
+	App               string
+	PrivateInstanceId string
+	RouteServiceUrl   string
+}
+
+type backendKey struct {
+	host string
+	port uint32
+}
+
+// TemplateEmitter renders the routes of the routing table through a
+// text/template into a config file for a proxy such as nginx or HAProxy
+// whenever routes are emitted to it. It is a route_sink.RouteSink, and an
+// ifrit.Runner that does the rendering.
+type TemplateEmitter struct {
+	table         routing_table.RoutingTable
+	template      *template.Template
+	configPath    string
+	reloadCommand string
+	debounce      time.Duration
+	clock         clock.Clock
+	logger        lager.Logger
+
+	changed chan struct{}
+
+	// only accessed from Run; content is what the config file holds if
+	// contentKnown is set
+	content       []byte
+	contentKnown  bool
+	reloadPending bool
+	routesSeen    bool
+}
+
+// New returns a TemplateEmitter that renders the template at templatePath into
+// the file at configPath. Changes are coalesced over the debounce interval.
+// The config file is written atomically, and only when its content changes,
+// after which reloadCommand is run by /bin/sh if it is not empty.
+//
+// The whole table is rendered rather than the emitted messages, so that the
+// config never holds only the routes that changed since the emitter started.
+// Until the table has held any route, an existing config file is not
+// replaced by an empty one, so that the routes emitted before the first sync
+// do not wipe out the config of the previous run.
+func New(table routing_table.RoutingTable, templatePath, configPath, reloadCommand string, debounce time.Duration, clock clock.Clock, logger lager.Logger) (*TemplateEmitter, error) {
+	tmpl, err := template.ParseFiles(templatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TemplateEmitter{
+		table:         table,
+		template:      tmpl,
+		configPath:    configPath,
+		reloadCommand: reloadCommand,
+		debounce:      debounce,
+		clock:         clock,
+		logger:        logger.Session("template-emitter", lager.Data{"config-path": configPath}),
+		changed:       make(chan struct{}, 1),
+	}, nil
+}
+
+// Emit schedules the table to be rendered. It never fails; failures to render
+// the config file are logged and counted by Run.
+func (e *TemplateEmitter) Emit(messagesToEmit routing_table.MessagesToEmit) (route_sink.EmitResult, error) {
+	select {
+	case e.changed <- struct{}{}:
+	default:
+	}
+
+	return route_sink.BatchResult(messagesToEmit, nil), nil
+}
+
+func (e *TemplateEmitter) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	close(ready)
+
+	var renderTimer clock.Timer
+	var render <-chan time.Time
+
+	for {
+		select {
+		case <-e.changed:
+			if renderTimer == nil {
+				renderTimer = e.clock.NewTimer(e.debounce)
+				render = renderTimer.C()
+			}
+		case <-render:
+			renderTimer = nil
+			render = nil
+			e.render()
+		case <-signals:
+			if renderTimer != nil {
+				renderTimer.Stop()
+			}
+			return nil
+		}
+	}
+}
+
+func (e *TemplateEmitter) config() Config {
+	routes := map[string]map[backendKey]Backend{}
+	for _, message := range e.table.MessagesToEmit().RegistrationMessages {
+		key := backendKey{host: message.Host, port: message.Port}
+		for _, uri := range message.URIs {
+			if routes[uri] == nil {
+				routes[uri] = map[backendKey]Backend{}
+			}
+			routes[uri][key] = Backend{
+				Host:              message.Host,
+				Port:              message.Port,
+				App:               message.App,
+				PrivateInstanceId: message.PrivateInstanceId,
+				RouteServiceUrl:   message.RouteServiceUrl,
+			}
+		}
+	}
+
+	config := Config{Routes: make([]Route, 0, len(routes))}
+	for uri, backends := range routes {
+		route := routing_table.NewRoute(uri)
+		r := Route{
+			URI:      uri,
+			Hostname: route.Hostname,
+			Path:     route.Path,
+			Backends: make([]Backend, 0, len(backends)),
+		}
+		for _, backend := range backends {
+			r.Backends = append(r.Backends, backend)
+		}
+		sort.Sort(byAddress(r.Backends))
+		config.Routes = append(config.Routes, r)
+	}
+	sort.Sort(byURI(config.Routes))
+
+	return config
+}
+
+func (e *TemplateEmitter) render() {
+	logger := e.logger.Session("render")
+
+	config := e.config()
+	if len(config.Routes) > 0 {
+		e.routesSeen = true
+	} else if !e.routesSeen {
+		if _, err := os.Stat(e.configPath); err == nil {
+			logger.Info("keeping-existing-config-until-routes-are-known")
+			return
+		}
+	}
+
+	buffer := &bytes.Buffer{}
+	err := e.template.Execute(buffer, config)
+	if err != nil {
+		logger.Error("failed-to-execute-template", err)
+		configRenderFailure.Increment()
+		return
+	}
+	content := buffer.Bytes()
+
+	if !e.contentKnown {
+		// pick up the file left behind by a previous run, to avoid an
+		// unnecessary reload on start
+		existing, err := ioutil.ReadFile(e.configPath)
+		if err == nil {
+			e.content = existing
+			e.contentKnown = true
+		}
+	}
+
+	unchanged := e.contentKnown && bytes.Equal(content, e.content)
+	if unchanged && !e.reloadPending {
+		logger.Debug("config-unchanged")
+		return
+	}
+
+	if !unchanged {
+		err = writeAtomically(e.configPath, content)
+		if err != nil {
+			logger.Error("failed-to-write-config", err)
+			configRenderFailure.Increment()
+			return
+		}
+		e.content = content
+		e.contentKnown = true
+		configRenders.Increment()
+		logger.Info("wrote-config", lager.Data{"route-count": len(config.Routes)})
+	}
+
+	e.reloadPending = !e.reload(logger)
+}
+
+// reload runs the reload command and reports whether it succeeded. A failed
+// reload is retried on the next render, even if the config is unchanged.
+func (e *TemplateEmitter) reload(logger lager.Logger) bool {
+	if e.reloadCommand == "" {
+		return true
+	}
+
+	output, err := exec.Command("/bin/sh", "-c", e.reloadCommand).CombinedOutput()
+	if err != nil {
+		logger.Error("failed-to-reload", err, lager.Data{"output": string(output)})
+		configReloadFailure.Increment()
+		return false
+	}
+
+	logger.Info("reloaded")
+	return true
+}
+
+// writeAtomically writes the content to a temporary file in the same
+// directory and renames it into place, so the proxy never reads a partially
+// written config.
+func writeAtomically(path string, content []byte) error {
+	tmpFile, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmpFile.Write(content)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	closeErr := tmpFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpFile.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return err
+	}
+
+	return nil
+}
+
+type byURI []Route
+
+func (routes byURI) Len() int           { return len(routes) }
+func (routes byURI) Swap(i, j int)      { routes[i], routes[j] = routes[j], routes[i] }
+func (routes byURI) Less(i, j int) bool { return routes[i].URI < routes[j].URI }
+
+type byAddress []Backend
+
+func (backends byAddress) Len() int      { return len(backends) }
+func (backends byAddress) Swap(i, j int) { backends[i], backends[j] = backends[j], backends[i] }
+func (backends byAddress) Less(i, j int) bool {
+	if backends[i].Host != backends[j].Host {
+		return backends[i].Host < backends[j].Host
+	}
+	return backends[i].Port < backends[j].Port
+}
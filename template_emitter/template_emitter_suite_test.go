@@ -0,0 +1,13 @@
+package template_emitter_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestTemplateEmitter(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "TemplateEmitter Suite")
+}
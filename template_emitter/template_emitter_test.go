@@ -0,0 +1,267 @@
+package template_emitter_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table/fake_routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/template_emitter"
+	fake_metrics_sender "github.com/cloudfoundry/dropsonde/metric_sender/fake"
+	"github.com/cloudfoundry/dropsonde/metrics"
+	"github.com/pivotal-golang/clock/fakeclock"
+	"github.com/pivotal-golang/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const configTemplate = `{{range .Routes}}{{.Hostname}} {{.Path}}:{{range .Backends}} {{.Host}}:{{.Port}}{{end}}
+{{end}}`
+
+var _ = Describe("TemplateEmitter", func() {
+	var (
+		table            *fake_routing_table.FakeRoutingTable
+		tmpDir           string
+		configPath       string
+		reloadsPath      string
+		reloadCommand    string
+		clock            *fakeclock.FakeClock
+		logger           *lagertest.TestLogger
+		fakeMetricSender *fake_metrics_sender.FakeMetricSender
+
+		emitter *template_emitter.TemplateEmitter
+		process ifrit.Process
+	)
+
+	registration := func(host string, port uint32, uris ...string) routing_table.RegistryMessage {
+		return routing_table.RegistryMessage{Host: host, Port: port, URIs: uris}
+	}
+
+	setRoutes := func(registrations ...routing_table.RegistryMessage) {
+		table.MessagesToEmitReturns(routing_table.MessagesToEmit{RegistrationMessages: registrations})
+	}
+
+	emit := func() {
+		_, err := emitter.Emit(routing_table.MessagesToEmit{})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	render := func() {
+		Eventually(clock.WatcherCount).Should(Equal(1))
+		clock.Increment(time.Second)
+	}
+
+	config := func() string {
+		content, _ := ioutil.ReadFile(configPath)
+		return string(content)
+	}
+
+	reloads := func() int {
+		content, _ := ioutil.ReadFile(reloadsPath)
+		return strings.Count(string(content), "reload")
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "template-emitter")
+		Expect(err).NotTo(HaveOccurred())
+
+		templatePath := filepath.Join(tmpDir, "routes.tmpl")
+		err = ioutil.WriteFile(templatePath, []byte(configTemplate), 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		configPath = filepath.Join(tmpDir, "routes.conf")
+		reloadsPath = filepath.Join(tmpDir, "reloads")
+		reloadCommand = "echo reload >> " + reloadsPath
+
+		table = &fake_routing_table.FakeRoutingTable{}
+		clock = fakeclock.NewFakeClock(time.Now())
+		logger = lagertest.NewTestLogger("test")
+
+		fakeMetricSender = fake_metrics_sender.NewFakeMetricSender()
+		metrics.Initialize(fakeMetricSender, nil)
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		emitter, err = template_emitter.New(table, filepath.Join(tmpDir, "routes.tmpl"), configPath, reloadCommand, time.Second, clock, logger)
+		Expect(err).NotTo(HaveOccurred())
+
+		process = ifrit.Invoke(emitter)
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive(BeNil()))
+		os.RemoveAll(tmpDir)
+	})
+
+	It("fails to construct with an invalid template", func() {
+		templatePath := filepath.Join(tmpDir, "invalid.tmpl")
+		Expect(ioutil.WriteFile(templatePath, []byte("{{range}"), 0644)).To(Succeed())
+
+		_, err := template_emitter.New(table, templatePath, configPath, "", time.Second, clock, logger)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("renders the routes of the table and reloads", func() {
+		setRoutes(
+			registration("2.2.2.2", 22, "foo.com"),
+			registration("1.1.1.1", 11, "foo.com", "bar.com/api"),
+		)
+		emit()
+		render()
+
+		Eventually(config).Should(Equal("bar.com /api: 1.1.1.1:11\nfoo.com : 1.1.1.1:11 2.2.2.2:22\n"))
+		Eventually(reloads).Should(Equal(1))
+		Expect(fakeMetricSender.GetCounter("TemplateEmitterConfigRenders")).To(BeEquivalentTo(1))
+	})
+
+	It("renders the whole table, not only the emitted messages", func() {
+		setRoutes(
+			registration("1.1.1.1", 11, "foo.com"),
+			registration("2.2.2.2", 22, "bar.com"),
+		)
+		_, err := emitter.Emit(routing_table.MessagesToEmit{
+			RegistrationMessages: []routing_table.RegistryMessage{registration("2.2.2.2", 22, "bar.com")},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		render()
+
+		Eventually(config).Should(Equal("bar.com : 2.2.2.2:22\nfoo.com : 1.1.1.1:11\n"))
+	})
+
+	It("drops the routes removed from the table", func() {
+		setRoutes(
+			registration("1.1.1.1", 11, "foo.com", "bar.com"),
+			registration("2.2.2.2", 22, "foo.com"),
+		)
+		emit()
+		render()
+		Eventually(reloads).Should(Equal(1))
+
+		setRoutes(registration("2.2.2.2", 22, "foo.com"))
+		emit()
+		render()
+
+		Eventually(config).Should(Equal("foo.com : 2.2.2.2:22\n"))
+	})
+
+	It("coalesces changes over the debounce interval", func() {
+		setRoutes(registration("1.1.1.1", 11, "foo.com"))
+		emit()
+		Eventually(clock.WatcherCount).Should(Equal(1))
+
+		setRoutes(registration("1.1.1.1", 11, "foo.com"), registration("2.2.2.2", 22, "foo.com"))
+		emit()
+		Consistently(config).Should(BeEmpty())
+
+		render()
+		Eventually(config).Should(Equal("foo.com : 1.1.1.1:11 2.2.2.2:22\n"))
+		Consistently(reloads).Should(Equal(1))
+	})
+
+	It("does not rewrite the config or reload when the content is unchanged", func() {
+		setRoutes(registration("1.1.1.1", 11, "foo.com"))
+		emit()
+		render()
+		Eventually(reloads).Should(Equal(1))
+
+		emit()
+		render()
+
+		Eventually(logger.LogMessages).Should(ContainElement("test.template-emitter.render.config-unchanged"))
+		Expect(reloads()).To(Equal(1))
+		Expect(fakeMetricSender.GetCounter("TemplateEmitterConfigRenders")).To(BeEquivalentTo(1))
+	})
+
+	Context("when the config file was left behind with the same content", func() {
+		BeforeEach(func() {
+			Expect(ioutil.WriteFile(configPath, []byte("foo.com : 1.1.1.1:11\n"), 0644)).To(Succeed())
+		})
+
+		It("does not reload", func() {
+			setRoutes(registration("1.1.1.1", 11, "foo.com"))
+			emit()
+			render()
+
+			Eventually(logger.LogMessages).Should(ContainElement("test.template-emitter.render.config-unchanged"))
+			Expect(reloads()).To(Equal(0))
+		})
+	})
+
+	Context("when the config file was left behind and the table has no routes yet", func() {
+		BeforeEach(func() {
+			Expect(ioutil.WriteFile(configPath, []byte("foo.com : 1.1.1.1:11\n"), 0644)).To(Succeed())
+		})
+
+		It("keeps the config file until the table has routes", func() {
+			emit()
+			render()
+
+			Eventually(logger.LogMessages).Should(ContainElement("test.template-emitter.render.keeping-existing-config-until-routes-are-known"))
+			Expect(config()).To(Equal("foo.com : 1.1.1.1:11\n"))
+			Expect(reloads()).To(Equal(0))
+
+			setRoutes(registration("2.2.2.2", 22, "bar.com"))
+			emit()
+			render()
+
+			Eventually(config).Should(Equal("bar.com : 2.2.2.2:22\n"))
+		})
+
+		It("renders an empty table once the table has held routes", func() {
+			setRoutes(registration("2.2.2.2", 22, "bar.com"))
+			emit()
+			render()
+			Eventually(config).Should(Equal("bar.com : 2.2.2.2:22\n"))
+
+			setRoutes()
+			emit()
+			render()
+
+			Eventually(config).Should(BeEmpty())
+		})
+	})
+
+	Context("when the reload command fails", func() {
+		BeforeEach(func() {
+			reloadCommand = "echo reload >> " + reloadsPath + "; exit 1"
+		})
+
+		It("logs, counts and retries the reload on the next render", func() {
+			setRoutes(registration("1.1.1.1", 11, "foo.com"))
+			emit()
+			render()
+
+			Eventually(logger.LogMessages).Should(ContainElement("test.template-emitter.render.failed-to-reload"))
+			Expect(fakeMetricSender.GetCounter("TemplateEmitterConfigReloadFailures")).To(BeEquivalentTo(1))
+
+			emit()
+			render()
+
+			Eventually(reloads).Should(Equal(2))
+		})
+	})
+
+	Context("when the config file cannot be written", func() {
+		BeforeEach(func() {
+			configPath = filepath.Join(tmpDir, "missing", "routes.conf")
+		})
+
+		It("logs and counts the failure, and does not reload", func() {
+			setRoutes(registration("1.1.1.1", 11, "foo.com"))
+			emit()
+			render()
+
+			Eventually(logger.LogMessages).Should(ContainElement("test.template-emitter.render.failed-to-write-config"))
+			Expect(fakeMetricSender.GetCounter("TemplateEmitterConfigRenderFailures")).To(BeEquivalentTo(1))
+			Expect(reloads()).To(Equal(0))
+		})
+	})
+})
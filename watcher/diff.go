@@ -0,0 +1,88 @@
+package watcher
+
+import (
+	"errors"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/pivotal-golang/lager"
+)
+
+var ErrWatcherStopped = errors.New("watcher has stopped")
+
+// DiffReport describes how the live routing tables differ from the state in
+// the BBS: the routes the next sync would register because they are missing
+// from the tables, and the routes it would unregister because they are stale.
+type DiffReport struct {
+	Registrations      []routing_table.RegistryMessage `json:"registrations"`
+	Unregistrations    []routing_table.RegistryMessage `json:"unregistrations"`
+	TCPRegistrations   []routing_table.TCPRouteMapping `json:"tcp_registrations"`
+	TCPUnregistrations []routing_table.TCPRouteMapping `json:"tcp_unregistrations"`
+}
+
+func (report DiffReport) InSync() bool {
+	return len(report.Registrations) == 0 &&
+		len(report.Unregistrations) == 0 &&
+		len(report.TCPRegistrations) == 0 &&
+		len(report.TCPUnregistrations) == 0
+}
+
+type diffRequest struct {
+	table    routing_table.RoutingTable
+	tcpTable routing_table.TCPRoutingTable
+	result   chan DiffReport
+}
+
+// Diff fetches the current state from the BBS and compares it with the live
+// routing tables, without modifying them or emitting anything.
+func (watcher *Watcher) Diff(logger lager.Logger) (DiffReport, error) {
+	logger = logger.Session("diff")
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	table, tcpTable, _, err := watcher.fetchTables(logger)
+	if err != nil {
+		return DiffReport{}, err
+	}
+
+	request := diffRequest{
+		table:    table,
+		tcpTable: tcpTable,
+		result:   make(chan DiffReport, 1),
+	}
+
+	// the live tables are only safe to read from the watcher's event loop
+	select {
+	case watcher.diffRequests <- request:
+	case <-watcher.stopped:
+		return DiffReport{}, ErrWatcherStopped
+	}
+
+	return <-request.result, nil
+}
+
+func (watcher *Watcher) diff(request diffRequest) DiffReport {
+	messages := watcher.table.Diff(request.table)
+	tcpMessages := watcher.tcpTable.Diff(request.tcpTable)
+
+	report := DiffReport{
+		Registrations:      messages.RegistrationMessages,
+		Unregistrations:    messages.UnregistrationMessages,
+		TCPRegistrations:   tcpMessages.RegistrationMessages,
+		TCPUnregistrations: tcpMessages.UnregistrationMessages,
+	}
+
+	if report.Registrations == nil {
+		report.Registrations = []routing_table.RegistryMessage{}
+	}
+	if report.Unregistrations == nil {
+		report.Unregistrations = []routing_table.RegistryMessage{}
+	}
+	if report.TCPRegistrations == nil {
+		report.TCPRegistrations = []routing_table.TCPRouteMapping{}
+	}
+	if report.TCPUnregistrations == nil {
+		report.TCPUnregistrations = []routing_table.TCPRouteMapping{}
+	}
+
+	return report
+}
@@ -0,0 +1,131 @@
+package watcher
+
+import (
+	"sort"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+)
+
+// emitBuffer coalesces the messages emitted during a window. Messages are
+// tracked per endpoint and URI. An unregistration cancels a registration
+// buffered earlier in the window, so a route that comes and goes within the
+// window is not sent at all; should the routers have known the route from
+// before the window, they prune it once it is no longer registered. A
+// registration replaces an earlier unregistration, and repeated messages are
+// sent once.
+type emitBuffer struct {
+	messages map[bufferKey]bufferedMessage
+	sequence int
+}
+
+type bufferKey struct {
+	host       string
+	port       uint32
+	instanceId string
+	uri        string
+}
+
+type bufferedMessage struct {
+	registration bool
+	uri          string
+	message      routing_table.RegistryMessage
+	sequence     int
+}
+
+// groupKey identifies the messages whose URIs are merged on flush.
+type groupKey struct {
+	registration    bool
+	host            string
+	port            uint32
+	instanceId      string
+	app             string
+	routeServiceUrl string
+}
+
+func newEmitBuffer() *emitBuffer {
+	return &emitBuffer{messages: map[bufferKey]bufferedMessage{}}
+}
+
+func (buffer *emitBuffer) empty() bool {
+	return len(buffer.messages) == 0
+}
+
+func (buffer *emitBuffer) add(messagesToEmit routing_table.MessagesToEmit) {
+	for _, message := range messagesToEmit.RegistrationMessages {
+		buffer.addMessage(true, message)
+	}
+	for _, message := range messagesToEmit.UnregistrationMessages {
+		buffer.addMessage(false, message)
+	}
+}
+
+func (buffer *emitBuffer) addMessage(registration bool, message routing_table.RegistryMessage) {
+	for _, uri := range message.URIs {
+		buffer.sequence++
+		key := bufferKey{host: message.Host, port: message.Port, instanceId: message.PrivateInstanceId, uri: uri}
+		if !registration && buffer.messages[key].registration {
+			delete(buffer.messages, key)
+			continue
+		}
+
+		buffer.messages[key] = bufferedMessage{
+			registration: registration,
+			uri:          uri,
+			message:      message,
+			sequence:     buffer.sequence,
+		}
+	}
+}
+
+// flush returns the coalesced messages in the order in which they were last
+// added, merging the URIs of messages for the same endpoint, and empties the
+// buffer.
+func (buffer *emitBuffer) flush() routing_table.MessagesToEmit {
+	buffered := make([]bufferedMessage, 0, len(buffer.messages))
+	for _, message := range buffer.messages {
+		buffered = append(buffered, message)
+	}
+	sort.Sort(bySequence(buffered))
+
+	keys := []groupKey{}
+	groups := map[groupKey]routing_table.RegistryMessage{}
+	for _, b := range buffered {
+		key := groupKey{
+			registration:    b.registration,
+			host:            b.message.Host,
+			port:            b.message.Port,
+			instanceId:      b.message.PrivateInstanceId,
+			app:             b.message.App,
+			routeServiceUrl: b.message.RouteServiceUrl,
+		}
+
+		message, ok := groups[key]
+		if !ok {
+			message = b.message
+			message.URIs = nil
+			keys = append(keys, key)
+		}
+		message.URIs = append(message.URIs, b.uri)
+		groups[key] = message
+	}
+
+	messagesToEmit := routing_table.MessagesToEmit{}
+	for _, key := range keys {
+		if key.registration {
+			messagesToEmit.RegistrationMessages = append(messagesToEmit.RegistrationMessages, groups[key])
+		} else {
+			messagesToEmit.UnregistrationMessages = append(messagesToEmit.UnregistrationMessages, groups[key])
+		}
+	}
+
+	buffer.messages = map[bufferKey]bufferedMessage{}
+	buffer.sequence = 0
+
+	return messagesToEmit
+}
+
+type bySequence []bufferedMessage
+
+func (messages bySequence) Len() int           { return len(messages) }
+func (messages bySequence) Swap(i, j int)      { messages[i], messages[j] = messages[j], messages[i] }
+func (messages bySequence) Less(i, j int) bool { return messages[i].sequence < messages[j].sequence }
@@ -10,9 +10,11 @@ import (
 	"github.com/cloudfoundry-incubator/bbs/events"
 	"github.com/cloudfoundry-incubator/bbs/models"
 	"github.com/cloudfoundry-incubator/routing-info/cfroutes"
-	"github.com/cloudfoundry-incubator/route-emitter/nats_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/journal"
+	"github.com/cloudfoundry-incubator/route-emitter/route_sink"
 	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
 	"github.com/cloudfoundry-incubator/route-emitter/syncer"
+	"github.com/cloudfoundry-incubator/route-emitter/tcp_emitter"
 	"github.com/cloudfoundry-incubator/runtime-schema/metric"
 	"github.com/pivotal-golang/clock"
 	"github.com/pivotal-golang/lager"
@@ -22,24 +24,62 @@ var (
 	routesTotal  = metric.Metric("RoutesTotal")
 	routesSynced = metric.Counter("RoutesSynced")
 
+	hostnameConflictsTotal = metric.Metric("HostnameConflictsTotal")
+	rejectedRoutesTotal    = metric.Metric("RejectedRoutesTotal")
+
 	routeSyncDuration = metric.Duration("RouteEmitterSyncDuration")
 
 	routesRegistered   = metric.Counter("RoutesRegistered")
 	routesUnregistered = metric.Counter("RoutesUnregistered")
+
+	emitMessagesFailed  = metric.Counter("RouteEmitMessagesFailed")
+	emitMessagesDropped = metric.Counter("RouteEmitMessagesDropped")
+	emitPartialFailures = metric.Counter("RouteEmitPartialFailures")
+
+	tcpRoutesTotal        = metric.Metric("TCPRoutesTotal")
+	tcpRoutesSynced       = metric.Counter("TCPRoutesSynced")
+	tcpRoutesRegistered   = metric.Counter("TCPRoutesRegistered")
+	tcpRoutesUnregistered = metric.Counter("TCPRoutesUnregistered")
 )
 
 type Watcher struct {
 	bbsClient  bbs.Client
 	clock      clock.Clock
 	table      routing_table.RoutingTable
-	emitter    nats_emitter.NATSEmitter
+	tcpTable   routing_table.TCPRoutingTable
+	emitter    route_sink.RouteSink
+	tcpEmitter tcp_emitter.TCPEmitter
 	syncEvents syncer.Events
+	journal    *journal.Journal
 	logger     lager.Logger
+
+	// emitWindow is how long route messages are coalesced before being
+	// emitted. Messages are emitted immediately when it is zero.
+	emitWindow time.Duration
+	emitBuffer *emitBuffer
+	flushTimer clock.Timer
+
+	// changeSource is the cause of the changes being made to the routing
+	// table: the type of the event being handled, or a sync.
+	changeSource string
+
+	// replaying is set while the events cached during a sync are replayed
+	// onto the tables of the sync.
+	replaying bool
+
+	// cellZones maps cell ids to availability zones. It is refreshed on
+	// every sync, so cells that appeared since the last sync have no zone.
+	cellZones map[string]string
+
+	diffRequests chan diffRequest
+	stopped      chan struct{}
 }
 
 type syncEndEvent struct {
-	table    routing_table.RoutingTable
-	callback func(routing_table.RoutingTable)
+	table     routing_table.RoutingTable
+	tcpTable  routing_table.TCPRoutingTable
+	cellZones map[string]string
+	callback  func(routing_table.RoutingTable)
 
 	logger lager.Logger
 }
@@ -59,22 +99,35 @@ func NewWatcher(
 	bbsClient bbs.Client,
 	clock clock.Clock,
 	table routing_table.RoutingTable,
-	emitter nats_emitter.NATSEmitter,
+	tcpTable routing_table.TCPRoutingTable,
+	emitter route_sink.RouteSink,
+	tcpEmitter tcp_emitter.TCPEmitter,
 	syncEvents syncer.Events,
+	changeJournal *journal.Journal,
+	emitWindow time.Duration,
 	logger lager.Logger,
 ) *Watcher {
 	return &Watcher{
 		bbsClient:  bbsClient,
 		clock:      clock,
 		table:      table,
+		tcpTable:   tcpTable,
 		emitter:    emitter,
+		tcpEmitter: tcpEmitter,
 		syncEvents: syncEvents,
+		journal:    changeJournal,
 		logger:     logger.Session("watcher"),
+		emitWindow: emitWindow,
+		emitBuffer: newEmitBuffer(),
+
+		diffRequests: make(chan diffRequest),
+		stopped:      make(chan struct{}),
 	}
 }
 
 func (watcher *Watcher) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 	watcher.logger.Info("starting")
+	defer close(watcher.stopped)
 
 	close(ready)
 	watcher.logger.Info("started")
@@ -128,6 +181,11 @@ func (watcher *Watcher) Run(signals <-chan os.Signal, ready chan<- struct{}) err
 
 	startedEventSource := false
 	for {
+		var flush <-chan time.Time
+		if watcher.flushTimer != nil {
+			flush = watcher.flushTimer.C()
+		}
+
 		select {
 		case <-watcher.syncEvents.Sync:
 			if syncing == false {
@@ -152,8 +210,15 @@ func (watcher *Watcher) Run(signals <-chan os.Signal, ready chan<- struct{}) err
 
 		case <-watcher.syncEvents.Emit:
 			logger := watcher.logger.Session("emit")
+			watcher.flushEmitBuffer(logger)
 			watcher.emit(logger)
 
+		case <-flush:
+			watcher.flushEmitBuffer(watcher.logger.Session("flush"))
+
+		case request := <-watcher.diffRequests:
+			request.result <- watcher.diff(request)
+
 		case event := <-eventChan:
 			if syncing {
 				watcher.logger.Info("caching-event", lager.Data{
@@ -167,6 +232,7 @@ func (watcher *Watcher) Run(signals <-chan os.Signal, ready chan<- struct{}) err
 
 		case <-signals:
 			watcher.logger.Info("stopping")
+			watcher.flushEmitBuffer(watcher.logger.Session("flush"))
 			atomic.StoreInt32(&stopEventSource, 1)
 			if es := eventSource.Load(); es != nil {
 				err := es.(events.EventSource).Close()
@@ -183,13 +249,25 @@ func (watcher *Watcher) emit(logger lager.Logger) {
 	messagesToEmit := watcher.table.MessagesToEmit()
 
 	logger.Debug("emitting-messages", lager.Data{"messages": messagesToEmit})
-	err := watcher.emitter.Emit(messagesToEmit)
-	if err != nil {
-		logger.Error("failed-to-emit-routes", err)
-	}
+	result, err := watcher.emitter.Emit(messagesToEmit)
+	recordEmitResult(logger, result, err)
 
 	routesSynced.Add(messagesToEmit.RouteRegistrationCount())
 	routesTotal.Send(watcher.table.RouteCount())
+	hostnameConflictsTotal.Send(len(watcher.table.Conflicts()))
+	rejectedRoutesTotal.Send(rejectedRouteCount(watcher.table.RejectedRoutes()))
+
+	tcpMessagesToEmit := watcher.tcpTable.MessagesToEmit()
+	if watcher.tcpEmitter != nil {
+		logger.Debug("emitting-tcp-messages", lager.Data{"messages": tcpMessagesToEmit})
+		err = watcher.tcpEmitter.Emit(tcpMessagesToEmit)
+		if err != nil {
+			logger.Error("failed-to-emit-tcp-routes", err)
+		}
+	}
+
+	tcpRoutesSynced.Add(tcpMessagesToEmit.RouteRegistrationCount())
+	tcpRoutesTotal.Send(watcher.tcpTable.RouteCount())
 }
 
 func (watcher *Watcher) sync(logger lager.Logger, syncEndChan chan syncEndEvent) {
@@ -200,10 +278,29 @@ func (watcher *Watcher) sync(logger lager.Logger, syncEndChan chan syncEndEvent)
 
 	before := watcher.clock.Now()
 
+	newTable, newTCPTable, cellZones, err := watcher.fetchTables(logger)
+	endEvent.cellZones = cellZones
+	if err != nil {
+		return
+	}
+
+	endEvent.table = newTable
+	endEvent.tcpTable = newTCPTable
+	endEvent.callback = func(table routing_table.RoutingTable) {
+		after := watcher.clock.Now()
+		routeSyncDuration.Send(after.Sub(before))
+	}
+}
+
+// fetchTables builds temporary routing tables from the current BBS state. The
+// cell zones are returned even if building the tables fails, and are nil if
+// the cells could not be fetched.
+func (watcher *Watcher) fetchTables(logger lager.Logger) (routing_table.RoutingTable, routing_table.TCPRoutingTable, map[string]string, error) {
 	var runningActualLRPs []*routing_table.ActualLRPRoutingInfo
 	var getActualLRPsErr error
 	var schedulingInfos []*models.DesiredLRPSchedulingInfo
 	var getSchedulingInfosErr error
+	var cellZones map[string]string
 
 	wg := sync.WaitGroup{}
 
@@ -247,27 +344,60 @@ func (watcher *Watcher) sync(logger lager.Logger, syncEndChan chan syncEndEvent)
 		logger.Debug("succeeded-getting-scheduling-infos", lager.Data{"num-desired-responses": len(schedulingInfos)})
 	}()
 
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		logger.Debug("getting-cells")
+		cells, err := watcher.bbsClient.Cells()
+		if err != nil {
+			// availability zones are best effort, so this does not fail the sync
+			logger.Error("failed-getting-cells", err)
+			return
+		}
+		logger.Debug("succeeded-getting-cells", lager.Data{"num-cells": len(cells)})
+
+		cellZones = make(map[string]string, len(cells))
+		for _, cell := range cells {
+			cellZones[cell.CellId] = cell.Zone
+		}
+	}()
+
 	wg.Wait()
 
-	if getActualLRPsErr != nil || getSchedulingInfosErr != nil {
-		return
+	if getActualLRPsErr != nil {
+		return nil, nil, cellZones, getActualLRPsErr
+	}
+	if getSchedulingInfosErr != nil {
+		return nil, nil, cellZones, getSchedulingInfosErr
 	}
 
+	for _, actualLRPInfo := range runningActualLRPs {
+		actualLRPInfo.AvailabilityZone = cellZones[actualLRPInfo.ActualLRP.CellId]
+	}
+
+	endpoints := routing_table.EndpointsByRoutingKeyFromActuals(runningActualLRPs)
+
 	newTable := routing_table.NewTempTable(
 		routing_table.RoutesByRoutingKeyFromSchedulingInfos(schedulingInfos),
-		routing_table.EndpointsByRoutingKeyFromActuals(runningActualLRPs),
+		endpoints,
 	)
 
-	endEvent.table = newTable
-	endEvent.callback = func(table routing_table.RoutingTable) {
-		after := watcher.clock.Now()
-		routeSyncDuration.Send(after.Sub(before))
-	}
+	newTCPTable := routing_table.NewTempTCPTable(
+		routing_table.TCPRoutesByRoutingKeyFromSchedulingInfos(schedulingInfos),
+		endpoints,
+	)
+
+	return newTable, newTCPTable, cellZones, nil
 }
 
 func (watcher *Watcher) completeSync(syncEnd syncEndEvent, cachedEvents map[string]models.Event) {
 	logger := syncEnd.logger
 
+	if syncEnd.cellZones != nil {
+		watcher.cellZones = syncEnd.cellZones
+	}
+
 	if syncEnd.table == nil {
 		// sync failed, process the events on the current table
 		logger.Debug("handling-events-from-failed-sync")
@@ -280,21 +410,40 @@ func (watcher *Watcher) completeSync(syncEnd syncEndEvent, cachedEvents map[stri
 	}
 
 	emitter := watcher.emitter
+	tcpEmitter := watcher.tcpEmitter
 	watcher.emitter = nil
+	watcher.tcpEmitter = nil
 
 	table := watcher.table
+	tcpTable := watcher.tcpTable
 	watcher.table = syncEnd.table
+	watcher.tcpTable = syncEnd.tcpTable
 
 	logger.Debug("handling-cached-events")
+	watcher.replaying = true
 	for _, e := range cachedEvents {
 		watcher.handleEvent(logger, e)
 	}
+	watcher.replaying = false
 	logger.Debug("done-handling-cached-events")
 
 	watcher.table = table
+	watcher.tcpTable = tcpTable
 	watcher.emitter = emitter
+	watcher.tcpEmitter = tcpEmitter
+
+	// the swap re-registers the whole table, so only the routes that the sync
+	// changed are journaled
+	var delta routing_table.MessagesToEmit
+	var tcpDelta routing_table.TCPMessagesToEmit
+	if watcher.journal != nil {
+		delta = watcher.table.Diff(syncEnd.table)
+		tcpDelta = watcher.tcpTable.Diff(syncEnd.tcpTable)
+	}
 
 	messages := watcher.table.Swap(syncEnd.table)
+	watcher.changeSource = journal.SyncSource
+	watcher.recordChange(routing_table.RoutingKey{}, journal.Swap, delta)
 	logger.Debug("emitting-messages", lager.Data{
 		"num-registration-messages":   len(messages.RegistrationMessages),
 		"num-unregistration-messages": len(messages.UnregistrationMessages),
@@ -305,12 +454,22 @@ func (watcher *Watcher) completeSync(syncEnd syncEndEvent, cachedEvents map[stri
 		"num-unregistration-messages": len(messages.UnregistrationMessages),
 	})
 
+	tcpMessages := watcher.tcpTable.Swap(syncEnd.tcpTable)
+	watcher.recordTCPChange(routing_table.RoutingKey{}, journal.Swap, tcpDelta)
+	logger.Debug("emitting-tcp-messages", lager.Data{
+		"num-registration-messages":   len(tcpMessages.RegistrationMessages),
+		"num-unregistration-messages": len(tcpMessages.UnregistrationMessages),
+	})
+	watcher.emitTCPMessages(logger, tcpMessages)
+
 	if syncEnd.callback != nil {
 		syncEnd.callback(watcher.table)
 	}
 }
 
 func (watcher *Watcher) handleEvent(logger lager.Logger, event models.Event) {
+	watcher.changeSource = event.EventType()
+
 	switch event := event.(type) {
 	case *models.DesiredLRPCreatedEvent:
 		schedulingInfo := event.DesiredLrp.DesiredLRPSchedulingInfo()
@@ -323,25 +482,32 @@ func (watcher *Watcher) handleEvent(logger lager.Logger, event models.Event) {
 		schedulingInfo := event.DesiredLrp.DesiredLRPSchedulingInfo()
 		watcher.handleDesiredDelete(logger, &schedulingInfo)
 	case *models.ActualLRPCreatedEvent:
-		watcher.handleActualCreate(logger, routing_table.NewActualLRPRoutingInfo(event.ActualLrpGroup))
+		watcher.handleActualCreate(logger, watcher.actualLRPRoutingInfo(event.ActualLrpGroup))
 	case *models.ActualLRPChangedEvent:
 		watcher.handleActualUpdate(logger,
-			routing_table.NewActualLRPRoutingInfo(event.Before),
-			routing_table.NewActualLRPRoutingInfo(event.After),
+			watcher.actualLRPRoutingInfo(event.Before),
+			watcher.actualLRPRoutingInfo(event.After),
 		)
 	case *models.ActualLRPRemovedEvent:
-		watcher.handleActualDelete(logger, routing_table.NewActualLRPRoutingInfo(event.ActualLrpGroup))
+		watcher.handleActualDelete(logger, watcher.actualLRPRoutingInfo(event.ActualLrpGroup))
 	default:
 		logger.Info("did-not-handle-unrecognizable-event", lager.Data{"event-type": event.EventType()})
 	}
 }
 
+func (watcher *Watcher) actualLRPRoutingInfo(actualLRPGroup *models.ActualLRPGroup) *routing_table.ActualLRPRoutingInfo {
+	actualLRPInfo := routing_table.NewActualLRPRoutingInfo(actualLRPGroup)
+	actualLRPInfo.AvailabilityZone = watcher.cellZones[actualLRPInfo.ActualLRP.CellId]
+	return actualLRPInfo
+}
+
 func (watcher *Watcher) handleDesiredCreate(logger lager.Logger, schedulingInfo *models.DesiredLRPSchedulingInfo) {
 	logger = logger.Session("handle-desired-create", desiredLRPData(schedulingInfo))
 	logger.Info("starting")
 	defer logger.Info("complete")
 
 	watcher.setRoutesForDesired(logger, schedulingInfo)
+	watcher.setTCPRoutesForDesired(logger, schedulingInfo)
 }
 
 func (watcher *Watcher) handleDesiredUpdate(logger lager.Logger, before, after *models.DesiredLRPSchedulingInfo) {
@@ -365,9 +531,20 @@ func (watcher *Watcher) handleDesiredUpdate(logger lager.Logger, before, after *
 	for _, key := range beforeRoutingKeys {
 		if !afterKeysSet.contains(key) || !afterContainerPorts.contains(key.ContainerPort) {
 			messagesToEmit := watcher.table.RemoveRoutes(key, &after.ModificationTag)
+			watcher.recordChange(key, journal.RemoveRoutes, messagesToEmit)
 			watcher.emitMessages(logger, messagesToEmit)
 		}
 	}
+
+	afterTCPKeysSet := watcher.setTCPRoutesForDesired(logger, after)
+
+	for _, key := range routing_table.TCPRoutingKeysFromSchedulingInfo(before) {
+		if !afterTCPKeysSet.contains(key) {
+			messagesToEmit := watcher.tcpTable.RemoveRoutes(key, &after.ModificationTag)
+			watcher.recordTCPChange(key, journal.RemoveRoutes, messagesToEmit)
+			watcher.emitTCPMessages(logger, messagesToEmit)
+		}
+	}
 }
 
 func (watcher *Watcher) setRoutesForDesired(logger lager.Logger, schedulingInfo *models.DesiredLRPSchedulingInfo) set {
@@ -384,6 +561,7 @@ func (watcher *Watcher) setRoutesForDesired(logger lager.Logger, schedulingInfo
 					LogGuid:         schedulingInfo.LogGuid,
 					RouteServiceUrl: route.RouteServiceUrl,
 				})
+				watcher.recordChange(key, journal.SetRoutes, messagesToEmit)
 				watcher.emitMessages(logger, messagesToEmit)
 			}
 		}
@@ -392,6 +570,20 @@ func (watcher *Watcher) setRoutesForDesired(logger lager.Logger, schedulingInfo
 	return routingKeySet
 }
 
+func (watcher *Watcher) setTCPRoutesForDesired(logger lager.Logger, schedulingInfo *models.DesiredLRPSchedulingInfo) set {
+	routesByRoutingKey := routing_table.TCPRoutesByRoutingKeyFromSchedulingInfos([]*models.DesiredLRPSchedulingInfo{schedulingInfo})
+	routingKeySet := set{}
+
+	for key, routes := range routesByRoutingKey {
+		routingKeySet.add(key)
+		messagesToEmit := watcher.tcpTable.SetRoutes(key, routes)
+		watcher.recordTCPChange(key, journal.SetRoutes, messagesToEmit)
+		watcher.emitTCPMessages(logger, messagesToEmit)
+	}
+
+	return routingKeySet
+}
+
 func (watcher *Watcher) handleDesiredDelete(logger lager.Logger, schedulingInfo *models.DesiredLRPSchedulingInfo) {
 	logger = logger.Session("handling-desired-delete", desiredLRPData(schedulingInfo))
 	logger.Info("starting")
@@ -399,9 +591,17 @@ func (watcher *Watcher) handleDesiredDelete(logger lager.Logger, schedulingInfo
 
 	for _, key := range routing_table.RoutingKeysFromSchedulingInfo(schedulingInfo) {
 		messagesToEmit := watcher.table.RemoveRoutes(key, &schedulingInfo.ModificationTag)
+		watcher.recordChange(key, journal.RemoveRoutes, messagesToEmit)
 
 		watcher.emitMessages(logger, messagesToEmit)
 	}
+
+	for _, key := range routing_table.TCPRoutingKeysFromSchedulingInfo(schedulingInfo) {
+		messagesToEmit := watcher.tcpTable.RemoveRoutes(key, &schedulingInfo.ModificationTag)
+		watcher.recordTCPChange(key, journal.RemoveRoutes, messagesToEmit)
+
+		watcher.emitTCPMessages(logger, messagesToEmit)
+	}
 }
 
 func (watcher *Watcher) handleActualCreate(logger lager.Logger, actualLRPInfo *routing_table.ActualLRPRoutingInfo) {
@@ -451,7 +651,12 @@ func (watcher *Watcher) addAndEmit(logger lager.Logger, actualLRPInfo *routing_t
 		for _, endpoint := range endpoints {
 			if key.ContainerPort == endpoint.ContainerPort {
 				messagesToEmit := watcher.table.AddEndpoint(key, endpoint)
+				watcher.recordChange(key, journal.AddEndpoint, messagesToEmit)
 				watcher.emitMessages(logger, messagesToEmit)
+
+				tcpMessagesToEmit := watcher.tcpTable.AddEndpoint(key, endpoint)
+				watcher.recordTCPChange(key, journal.AddEndpoint, tcpMessagesToEmit)
+				watcher.emitTCPMessages(logger, tcpMessagesToEmit)
 			}
 		}
 	}
@@ -468,18 +673,121 @@ func (watcher *Watcher) removeAndEmit(logger lager.Logger, actualLRPInfo *routin
 		for _, endpoint := range endpoints {
 			if key.ContainerPort == endpoint.ContainerPort {
 				messagesToEmit := watcher.table.RemoveEndpoint(key, endpoint)
+				watcher.recordChange(key, journal.RemoveEndpoint, messagesToEmit)
 				watcher.emitMessages(logger, messagesToEmit)
+
+				tcpMessagesToEmit := watcher.tcpTable.RemoveEndpoint(key, endpoint)
+				watcher.recordTCPChange(key, journal.RemoveEndpoint, tcpMessagesToEmit)
+				watcher.emitTCPMessages(logger, tcpMessagesToEmit)
 			}
 		}
 	}
 }
 
+// recordChange appends a change to the live routing table to the journal.
+// Changes are not recorded while cached events are replayed onto the table of
+// a sync, as that table is recorded as a whole when it is swapped in.
+func (watcher *Watcher) recordChange(key routing_table.RoutingKey, operation journal.Operation, messagesToEmit routing_table.MessagesToEmit) {
+	if watcher.journal == nil || watcher.replaying {
+		return
+	}
+
+	watcher.journal.Append(journal.NewRecord(key, operation, watcher.changeSource, messagesToEmit))
+}
+
+// recordTCPChange appends a change to the live TCP routing table to the
+// journal, like recordChange.
+func (watcher *Watcher) recordTCPChange(key routing_table.RoutingKey, operation journal.Operation, messagesToEmit routing_table.TCPMessagesToEmit) {
+	if watcher.journal == nil || watcher.replaying {
+		return
+	}
+
+	watcher.journal.Append(journal.NewTCPRecord(key, operation, watcher.changeSource, messagesToEmit))
+}
+
 func (watcher *Watcher) emitMessages(logger lager.Logger, messagesToEmit routing_table.MessagesToEmit) {
-	if watcher.emitter != nil {
-		logger.Debug("emitting-messages", lager.Data{"messages": messagesToEmit})
-		watcher.emitter.Emit(messagesToEmit)
-		routesRegistered.Add(messagesToEmit.RouteRegistrationCount())
-		routesUnregistered.Add(messagesToEmit.RouteUnregistrationCount())
+	if watcher.emitter == nil {
+		return
+	}
+
+	if watcher.emitWindow == 0 {
+		watcher.sendMessages(logger, messagesToEmit)
+		return
+	}
+
+	watcher.emitBuffer.add(messagesToEmit)
+	if watcher.flushTimer == nil && !watcher.emitBuffer.empty() {
+		watcher.flushTimer = watcher.clock.NewTimer(watcher.emitWindow)
+	}
+}
+
+// flushEmitBuffer emits the messages coalesced since the window opened.
+func (watcher *Watcher) flushEmitBuffer(logger lager.Logger) {
+	if watcher.flushTimer != nil {
+		watcher.flushTimer.Stop()
+		watcher.flushTimer = nil
+	}
+
+	if watcher.emitBuffer.empty() {
+		return
+	}
+
+	messagesToEmit := watcher.emitBuffer.flush()
+	logger.Debug("flushing-coalesced-messages", lager.Data{
+		"registrations":   messagesToEmit.RouteRegistrationCount(),
+		"unregistrations": messagesToEmit.RouteUnregistrationCount(),
+	})
+	watcher.sendMessages(logger, messagesToEmit)
+}
+
+func (watcher *Watcher) sendMessages(logger lager.Logger, messagesToEmit routing_table.MessagesToEmit) {
+	logger.Debug("emitting-messages", lager.Data{"messages": messagesToEmit})
+	result, err := watcher.emitter.Emit(messagesToEmit)
+	recordEmitResult(logger, result, err)
+	routesRegistered.Add(messagesToEmit.RouteRegistrationCount())
+	routesUnregistered.Add(messagesToEmit.RouteUnregistrationCount())
+}
+
+// recordEmitResult logs the outcome of an emit and counts the messages that
+// failed, so that partial failures can be alerted on.
+func recordEmitResult(logger lager.Logger, result route_sink.EmitResult, err error) {
+	if failed := result.FailedCount(); failed > 0 {
+		emitMessagesFailed.Add(uint64(failed))
+	}
+	if dropped := result.DroppedCount(); dropped > 0 {
+		emitMessagesDropped.Add(uint64(dropped))
+	}
+	if result.PartialFailure() {
+		emitPartialFailures.Increment()
+	}
+
+	if err != nil {
+		logger.Error("failed-to-emit-routes", err, lager.Data{
+			"attempted": result.Attempted,
+			"succeeded": result.Succeeded,
+			"failed":    result.Failed,
+			"pending":   result.Pending,
+			"failures":  result.Failures,
+		})
+		return
+	}
+
+	logger.Debug("emitted-messages", lager.Data{
+		"attempted": result.Attempted,
+		"succeeded": result.Succeeded,
+		"pending":   result.Pending,
+	})
+}
+
+func (watcher *Watcher) emitTCPMessages(logger lager.Logger, messagesToEmit routing_table.TCPMessagesToEmit) {
+	if watcher.tcpEmitter != nil {
+		logger.Debug("emitting-tcp-messages", lager.Data{"messages": messagesToEmit})
+		err := watcher.tcpEmitter.Emit(messagesToEmit)
+		if err != nil {
+			logger.Error("failed-to-emit-tcp-routes", err)
+		}
+		tcpRoutesRegistered.Add(messagesToEmit.RouteRegistrationCount())
+		tcpRoutesUnregistered.Add(messagesToEmit.RouteUnregistrationCount())
 	}
 }
 
@@ -502,3 +810,11 @@ func actualLRPData(lrpRoutingInfo *routing_table.ActualLRPRoutingInfo) lager.Dat
 		"evacuating":    lrpRoutingInfo.Evacuating,
 	}
 }
+
+func rejectedRouteCount(rejectedRoutes []routing_table.RejectedRoutes) int {
+	count := 0
+	for _, rejected := range rejectedRoutes {
+		count += len(rejected.Hostnames)
+	}
+	return count
+}
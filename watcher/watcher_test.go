@@ -17,10 +17,14 @@ import (
 	"github.com/tedsuo/ifrit"
 
 	"github.com/cloudfoundry-incubator/routing-info/cfroutes"
-	"github.com/cloudfoundry-incubator/route-emitter/nats_emitter/fake_nats_emitter"
+	"github.com/cloudfoundry-incubator/routing-info/tcp_routes"
+	"github.com/cloudfoundry-incubator/route-emitter/journal"
+	"github.com/cloudfoundry-incubator/route-emitter/route_sink"
+	"github.com/cloudfoundry-incubator/route-emitter/route_sink/fake_route_sink"
 	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
 	"github.com/cloudfoundry-incubator/route-emitter/routing_table/fake_routing_table"
 	"github.com/cloudfoundry-incubator/route-emitter/syncer"
+	"github.com/cloudfoundry-incubator/route-emitter/tcp_emitter/fake_tcp_emitter"
 	"github.com/cloudfoundry-incubator/route-emitter/watcher"
 	fake_metrics_sender "github.com/cloudfoundry/dropsonde/metric_sender/fake"
 	"github.com/cloudfoundry/dropsonde/metrics"
@@ -50,9 +54,13 @@ var _ = Describe("Watcher", func() {
 		eventSource *eventfakes.FakeEventSource
 		bbsClient   *fake_bbs.FakeClient
 		table       *fake_routing_table.FakeRoutingTable
-		emitter     *fake_nats_emitter.FakeNATSEmitter
+		tcpTable    *fake_routing_table.FakeTCPRoutingTable
+		emitter     *fake_route_sink.FakeRouteSink
+		tcpEmitter  *fake_tcp_emitter.FakeTCPEmitter
 		syncEvents  syncer.Events
 
+		changeJournal *journal.Journal
+
 		clock          *fakeclock.FakeClock
 		watcherProcess *watcher.Watcher
 		process        ifrit.Process
@@ -65,8 +73,11 @@ var _ = Describe("Watcher", func() {
 		expectedAdditionalRoutingKey routing_table.RoutingKey
 		expectedAdditionalCFRoute    cfroutes.CFRoute
 
-		dummyMessagesToEmit routing_table.MessagesToEmit
-		fakeMetricSender    *fake_metrics_sender.FakeMetricSender
+		dummyMessagesToEmit    routing_table.MessagesToEmit
+		dummyTCPMessagesToEmit routing_table.TCPMessagesToEmit
+		fakeMetricSender       *fake_metrics_sender.FakeMetricSender
+
+		expectedTCPRoute tcp_routes.TCPRoute
 
 		logger *lagertest.TestLogger
 
@@ -80,7 +91,9 @@ var _ = Describe("Watcher", func() {
 		bbsClient.SubscribeToEventsReturns(eventSource, nil)
 
 		table = &fake_routing_table.FakeRoutingTable{}
-		emitter = &fake_nats_emitter.FakeNATSEmitter{}
+		tcpTable = &fake_routing_table.FakeTCPRoutingTable{}
+		emitter = &fake_route_sink.FakeRouteSink{}
+		tcpEmitter = &fake_tcp_emitter.FakeTCPEmitter{}
 		syncEvents = syncer.Events{
 			Sync: make(chan struct{}),
 			Emit: make(chan struct{}),
@@ -93,9 +106,16 @@ var _ = Describe("Watcher", func() {
 			RegistrationMessages: []routing_table.RegistryMessage{dummyMessage},
 		}
 
+		expectedTCPRoute = tcp_routes.TCPRoute{RouterGroupGuid: "router-group-guid", ExternalPort: 61000, ContainerPort: expectedContainerPort}
+		dummyTCPMapping := routing_table.TCPRouteMappingFor(dummyEndpoint, routing_table.ExternalEndpointInfo{RouterGroupGuid: "router-group-guid", Port: 61000})
+		dummyTCPMessagesToEmit = routing_table.TCPMessagesToEmit{
+			RegistrationMessages: []routing_table.TCPRouteMapping{dummyTCPMapping},
+		}
+
 		clock = fakeclock.NewFakeClock(time.Now())
+		changeJournal = journal.New(100, clock)
 
-		watcherProcess = watcher.NewWatcher(bbsClient, clock, table, emitter, syncEvents, logger)
+		watcherProcess = watcher.NewWatcher(bbsClient, clock, table, tcpTable, emitter, tcpEmitter, syncEvents, changeJournal, 0, logger)
 
 		expectedRoutes = []string{"route-1", "route-2"}
 		expectedCFRoute = cfroutes.CFRoute{Hostnames: expectedRoutes, Port: expectedContainerPort, RouteServiceUrl: expectedRouteServiceUrl}
@@ -242,6 +262,43 @@ var _ = Describe("Watcher", func() {
 					Expect(messagesToEmit).To(Equal(dummyMessagesToEmit))
 				})
 			})
+
+			Context("when there are TCP routes", func() {
+				BeforeEach(func() {
+					routes := cfroutes.CFRoutes{expectedCFRoute}.RoutingInfo()
+					routes[tcp_routes.TCP_ROUTER] = tcp_routes.TCPRoutes{expectedTCPRoute}.RoutingInfo()[tcp_routes.TCP_ROUTER]
+					desiredLRP.Routes = &routes
+
+					tcpTable.SetRoutesReturns(dummyTCPMessagesToEmit)
+				})
+
+				It("sets the tcp routes on the tcp table", func() {
+					Eventually(tcpTable.SetRoutesCallCount).Should(Equal(1))
+
+					key, routes := tcpTable.SetRoutesArgsForCall(0)
+					Expect(key).To(Equal(expectedRoutingKey))
+					Expect(routes).To(Equal(routing_table.TCPRoutes{
+						ExternalEndpoints: []routing_table.ExternalEndpointInfo{{RouterGroupGuid: "router-group-guid", Port: 61000}},
+						LogGuid:           logGuid,
+						ModificationTag:   &models.ModificationTag{},
+					}))
+				})
+
+				It("still sets the http routes on the table", func() {
+					Eventually(table.SetRoutesCallCount).Should(Equal(1))
+				})
+
+				It("emits whatever the tcp table tells it to emit", func() {
+					Eventually(tcpEmitter.EmitCallCount).Should(Equal(2))
+					Expect(tcpEmitter.EmitArgsForCall(1)).To(Equal(dummyTCPMessagesToEmit))
+				})
+
+				It("sends a 'tcp routes registered' metric", func() {
+					Eventually(func() uint64 {
+						return fakeMetricSender.GetCounter("TCPRoutesRegistered")
+					}).Should(BeEquivalentTo(1))
+				})
+			})
 		})
 
 		Context("when a change event occurs", func() {
@@ -381,6 +438,33 @@ var _ = Describe("Watcher", func() {
 					Expect(messagesToEmit).To(Equal(dummyMessagesToEmit))
 				})
 			})
+
+			Context("when TCP routes are removed", func() {
+				BeforeEach(func() {
+					originalRoutes := cfroutes.CFRoutes{{Hostnames: expectedRoutes, Port: expectedContainerPort}}.RoutingInfo()
+					originalRoutes[tcp_routes.TCP_ROUTER] = tcp_routes.TCPRoutes{expectedTCPRoute}.RoutingInfo()[tcp_routes.TCP_ROUTER]
+					originalDesiredLRP.Routes = &originalRoutes
+
+					tcpTable.RemoveRoutesReturns(dummyTCPMessagesToEmit)
+				})
+
+				It("deletes the tcp routes for the missing key", func() {
+					Eventually(tcpTable.RemoveRoutesCallCount).Should(Equal(1))
+
+					key, modTag := tcpTable.RemoveRoutesArgsForCall(0)
+					Expect(key).To(Equal(expectedRoutingKey))
+					Expect(modTag).To(Equal(changedDesiredLRP.ModificationTag))
+				})
+
+				It("leaves the http routes alone", func() {
+					Consistently(table.RemoveRoutesCallCount).Should(Equal(0))
+				})
+
+				It("emits whatever the tcp table tells it to emit", func() {
+					Eventually(tcpEmitter.EmitCallCount).Should(Equal(2))
+					Expect(tcpEmitter.EmitArgsForCall(1)).To(Equal(dummyTCPMessagesToEmit))
+				})
+			})
 		})
 
 		Context("when a delete event occurs", func() {
@@ -452,6 +536,29 @@ var _ = Describe("Watcher", func() {
 					Expect(messagesToEmit).To(Equal(dummyMessagesToEmit))
 				})
 			})
+
+			Context("when there are TCP routes", func() {
+				BeforeEach(func() {
+					routes := cfroutes.CFRoutes{expectedCFRoute}.RoutingInfo()
+					routes[tcp_routes.TCP_ROUTER] = tcp_routes.TCPRoutes{expectedTCPRoute}.RoutingInfo()[tcp_routes.TCP_ROUTER]
+					desiredLRP.Routes = &routes
+
+					tcpTable.RemoveRoutesReturns(dummyTCPMessagesToEmit)
+				})
+
+				It("should remove the tcp routes from the tcp table", func() {
+					Eventually(tcpTable.RemoveRoutesCallCount).Should(Equal(1))
+
+					key, modTag := tcpTable.RemoveRoutesArgsForCall(0)
+					Expect(key).To(Equal(expectedRoutingKey))
+					Expect(modTag).To(Equal(desiredLRP.ModificationTag))
+				})
+
+				It("emits whatever the tcp table tells it to emit", func() {
+					Eventually(tcpEmitter.EmitCallCount).Should(Equal(2))
+					Expect(tcpEmitter.EmitArgsForCall(1)).To(Equal(dummyTCPMessagesToEmit))
+				})
+			})
 		})
 	})
 
@@ -519,6 +626,19 @@ var _ = Describe("Watcher", func() {
 					Expect(messagesToEmit).To(Equal(dummyMessagesToEmit))
 				})
 
+				It("should add/update the endpoints on the tcp table", func() {
+					Eventually(tcpTable.AddEndpointCallCount).Should(Equal(2))
+
+					endpoints, err := routing_table.EndpointsFromActual(actualLRPRoutingInfo)
+					Expect(err).NotTo(HaveOccurred())
+
+					key, endpoint := tcpTable.AddEndpointArgsForCall(0)
+					Expect(endpoint).To(Equal(endpoints[key.ContainerPort]))
+
+					key, endpoint = tcpTable.AddEndpointArgsForCall(1)
+					Expect(endpoint).To(Equal(endpoints[key.ContainerPort]))
+				})
+
 				It("sends a 'routes registered' metric", func() {
 					Eventually(func() uint64 {
 						return fakeMetricSender.GetCounter("RoutesRegistered")
@@ -601,6 +721,10 @@ var _ = Describe("Watcher", func() {
 						Host:          expectedHost,
 						Port:          expectedExternalPort,
 						ContainerPort: expectedContainerPort,
+						ProcessGuid:   expectedProcessGuid,
+						Index:         1,
+						Domain:        "domain",
+						CellId:        "cell-id",
 					}))
 
 					key, endpoint = table.AddEndpointArgsForCall(1)
@@ -610,6 +734,10 @@ var _ = Describe("Watcher", func() {
 						Host:          expectedHost,
 						Port:          expectedAdditionalExternalPort,
 						ContainerPort: expectedAdditionalContainerPort,
+						ProcessGuid:   expectedProcessGuid,
+						Index:         1,
+						Domain:        "domain",
+						CellId:        "cell-id",
 					}))
 
 				})
@@ -635,6 +763,12 @@ var _ = Describe("Watcher", func() {
 			})
 
 			Context("when the resulting LRP transitions away from the RUNNING state", func() {
+				var beforeModificationTag models.ModificationTag
+
+				BeforeEach(func() {
+					beforeModificationTag = models.ModificationTag{}
+				})
+
 				JustBeforeEach(func() {
 					table.RemoveEndpointReturns(dummyMessagesToEmit)
 					beforeActualLRP := &models.ActualLRPGroup{
@@ -646,7 +780,8 @@ var _ = Describe("Watcher", func() {
 								models.NewPortMapping(expectedExternalPort, expectedContainerPort),
 								models.NewPortMapping(expectedAdditionalExternalPort, expectedAdditionalContainerPort),
 							),
-							State: models.ActualLRPStateRunning,
+							State:           models.ActualLRPStateRunning,
+							ModificationTag: beforeModificationTag,
 						},
 					}
 					afterActualLRP := &models.ActualLRPGroup{
@@ -659,6 +794,22 @@ var _ = Describe("Watcher", func() {
 					nextEvent.Store(EventHolder{models.NewActualLRPChangedEvent(beforeActualLRP, afterActualLRP)})
 				})
 
+				Context("when the actual LRP has a modification tag", func() {
+					BeforeEach(func() {
+						beforeModificationTag = models.ModificationTag{Epoch: "abcd", Index: 2}
+					})
+
+					It("removes the endpoint with the tag of the running LRP, so that the table can ignore stale removals", func() {
+						Eventually(table.RemoveEndpointCallCount).Should(Equal(2))
+
+						_, endpoint := table.RemoveEndpointArgsForCall(0)
+						Expect(endpoint.ModificationTag).To(Equal(&models.ModificationTag{Epoch: "abcd", Index: 2}))
+
+						_, endpoint = table.RemoveEndpointArgsForCall(1)
+						Expect(endpoint.ModificationTag).To(Equal(&models.ModificationTag{Epoch: "abcd", Index: 2}))
+					})
+				})
+
 				It("should remove the endpoint from the table", func() {
 					Eventually(table.RemoveEndpointCallCount).Should(Equal(2))
 
@@ -669,6 +820,10 @@ var _ = Describe("Watcher", func() {
 						Host:          expectedHost,
 						Port:          expectedExternalPort,
 						ContainerPort: expectedContainerPort,
+						ProcessGuid:   expectedProcessGuid,
+						Index:         1,
+						Domain:        "domain",
+						CellId:        "cell-id",
 					}))
 
 					key, endpoint = table.RemoveEndpointArgsForCall(1)
@@ -678,6 +833,10 @@ var _ = Describe("Watcher", func() {
 						Host:          expectedHost,
 						Port:          expectedAdditionalExternalPort,
 						ContainerPort: expectedAdditionalContainerPort,
+						ProcessGuid:   expectedProcessGuid,
+						Index:         1,
+						Domain:        "domain",
+						CellId:        "cell-id",
 					}))
 
 				})
@@ -755,6 +914,10 @@ var _ = Describe("Watcher", func() {
 						Host:          expectedHost,
 						Port:          expectedExternalPort,
 						ContainerPort: expectedContainerPort,
+						ProcessGuid:   expectedProcessGuid,
+						Index:         1,
+						Domain:        "domain",
+						CellId:        "cell-id",
 					}))
 
 					key, endpoint = table.RemoveEndpointArgsForCall(1)
@@ -764,6 +927,10 @@ var _ = Describe("Watcher", func() {
 						Host:          expectedHost,
 						Port:          expectedAdditionalExternalPort,
 						ContainerPort: expectedAdditionalContainerPort,
+						ProcessGuid:   expectedProcessGuid,
+						Index:         1,
+						Domain:        "domain",
+						CellId:        "cell-id",
 					}))
 
 				})
@@ -848,6 +1015,267 @@ var _ = Describe("Watcher", func() {
 		})
 	})
 
+	Describe("Diff", func() {
+		BeforeEach(func() {
+			table.DiffReturns(dummyMessagesToEmit)
+			tcpTable.DiffReturns(dummyTCPMessagesToEmit)
+		})
+
+		It("reports how the live tables differ from the BBS", func() {
+			report, err := watcherProcess.Diff(logger)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(table.DiffCallCount()).To(Equal(1))
+			Expect(tcpTable.DiffCallCount()).To(Equal(1))
+
+			Expect(report.Registrations).To(Equal(dummyMessagesToEmit.RegistrationMessages))
+			Expect(report.Unregistrations).To(BeEmpty())
+			Expect(report.TCPRegistrations).To(Equal(dummyTCPMessagesToEmit.RegistrationMessages))
+			Expect(report.TCPUnregistrations).To(BeEmpty())
+			Expect(report.InSync()).To(BeFalse())
+		})
+
+		It("neither swaps the tables nor emits", func() {
+			_, err := watcherProcess.Diff(logger)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(table.SwapCallCount()).To(Equal(0))
+			Expect(tcpTable.SwapCallCount()).To(Equal(0))
+			Expect(emitter.EmitCallCount()).To(Equal(0))
+			Expect(tcpEmitter.EmitCallCount()).To(Equal(0))
+		})
+
+		Context("when fetching from the BBS fails", func() {
+			BeforeEach(func() {
+				bbsClient.ActualLRPGroupsReturns(nil, errors.New("bam"))
+			})
+
+			It("returns the error", func() {
+				_, err := watcherProcess.Diff(logger)
+				Expect(err).To(MatchError("bam"))
+				Expect(table.DiffCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the watcher has stopped", func() {
+			It("returns ErrWatcherStopped", func() {
+				process.Signal(os.Interrupt)
+				Eventually(process.Wait()).Should(Receive())
+
+				_, err := watcherProcess.Diff(logger)
+				Expect(err).To(Equal(watcher.ErrWatcherStopped))
+			})
+		})
+	})
+
+	Describe("Change journal", func() {
+		JustBeforeEach(func() {
+			syncEvents.Sync <- struct{}{}
+			Eventually(emitter.EmitCallCount).ShouldNot(Equal(0))
+		})
+
+		It("records the swaps of a sync", func() {
+			Eventually(changeJournal.LastSequence).Should(BeEquivalentTo(2))
+
+			records, err := changeJournal.Since(0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records[0].Operation).To(Equal(journal.Swap))
+			Expect(records[0].Source).To(Equal(journal.SyncSource))
+			Expect(records[1].Operation).To(Equal(journal.Swap))
+			Expect(records[1].Source).To(Equal(journal.SyncSource))
+		})
+
+		Context("when the sync changes the routing tables", func() {
+			BeforeEach(func() {
+				table.SwapReturns(dummyMessagesToEmit)
+				table.DiffReturns(routing_table.MessagesToEmit{
+					UnregistrationMessages: dummyMessagesToEmit.RegistrationMessages,
+				})
+				tcpTable.DiffReturns(dummyTCPMessagesToEmit)
+			})
+
+			It("records only the changes of the swaps, not the re-registered tables", func() {
+				Eventually(changeJournal.LastSequence).Should(BeEquivalentTo(2))
+
+				records, err := changeJournal.Since(0)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(records[0].Registrations).To(BeEmpty())
+				Expect(records[0].Unregistrations).To(Equal(dummyMessagesToEmit.RegistrationMessages))
+				Expect(records[1].TCPRegistrations).To(Equal(dummyTCPMessagesToEmit.RegistrationMessages))
+			})
+		})
+
+		Context("when an event changes the table", func() {
+			JustBeforeEach(func() {
+				Eventually(changeJournal.LastSequence).Should(BeEquivalentTo(2))
+
+				table.SetRoutesReturns(dummyMessagesToEmit)
+
+				routes := cfroutes.CFRoutes{expectedCFRoute}.RoutingInfo()
+				desiredLRP := &models.DesiredLRP{
+					Action: models.WrapAction(&models.RunAction{
+						User: "me",
+						Path: "ls",
+					}),
+					Domain:      "tests",
+					ProcessGuid: expectedProcessGuid,
+					Ports:       []uint32{expectedContainerPort},
+					Routes:      &routes,
+					LogGuid:     logGuid,
+				}
+				nextEvent.Store(EventHolder{models.NewDesiredLRPCreatedEvent(desiredLRP)})
+			})
+
+			It("records the change with its source event and emitted messages", func() {
+				Eventually(changeJournal.LastSequence).Should(BeEquivalentTo(3))
+
+				records, err := changeJournal.Since(2)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(records).To(HaveLen(1))
+
+				record := records[0]
+				Expect(record.Sequence).To(BeEquivalentTo(3))
+				Expect(record.ProcessGuid).To(Equal(expectedProcessGuid))
+				Expect(record.ContainerPort).To(BeEquivalentTo(expectedContainerPort))
+				Expect(record.Operation).To(Equal(journal.SetRoutes))
+				Expect(record.Source).To(Equal(models.EventTypeDesiredLRPCreated))
+				Expect(record.Registrations).To(Equal(dummyMessagesToEmit.RegistrationMessages))
+				Expect(record.Unregistrations).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Coalescing emissions", func() {
+		var (
+			desiredLRP *models.DesiredLRP
+			endpoint   routing_table.Endpoint
+		)
+
+		BeforeEach(func() {
+			watcherProcess = watcher.NewWatcher(bbsClient, clock, table, tcpTable, emitter, tcpEmitter, syncEvents, changeJournal, 100*time.Millisecond, logger)
+
+			routes := cfroutes.CFRoutes{expectedCFRoute}.RoutingInfo()
+			desiredLRP = &models.DesiredLRP{
+				Action: models.WrapAction(&models.RunAction{
+					User: "me",
+					Path: "ls",
+				}),
+				Domain:      "tests",
+				ProcessGuid: expectedProcessGuid,
+				Ports:       []uint32{expectedContainerPort},
+				Routes:      &routes,
+				LogGuid:     logGuid,
+			}
+
+			endpoint = routing_table.Endpoint{InstanceGuid: expectedInstanceGuid, Host: expectedHost, Port: expectedContainerPort}
+		})
+
+		JustBeforeEach(func() {
+			syncEvents.Sync <- struct{}{}
+			Eventually(table.SwapCallCount).Should(Equal(1))
+		})
+
+		messageFor := func(hostnames ...string) routing_table.RegistryMessage {
+			return routing_table.RegistryMessageFor(endpoint, routing_table.Routes{Hostnames: hostnames, LogGuid: logGuid})
+		}
+
+		sendEvents := func() {
+			nextEvent.Store(EventHolder{models.NewDesiredLRPCreatedEvent(desiredLRP)})
+			Eventually(table.SetRoutesCallCount).Should(Equal(1))
+
+			nextEvent.Store(EventHolder{models.NewDesiredLRPRemovedEvent(desiredLRP)})
+			Eventually(table.RemoveRoutesCallCount).Should(Equal(1))
+		}
+
+		Context("when a route is registered and then unregistered within the window", func() {
+			BeforeEach(func() {
+				table.SetRoutesReturns(routing_table.MessagesToEmit{
+					RegistrationMessages: []routing_table.RegistryMessage{messageFor("foo.com", "bar.com")},
+				})
+				table.RemoveRoutesReturns(routing_table.MessagesToEmit{
+					UnregistrationMessages: []routing_table.RegistryMessage{messageFor("foo.com")},
+				})
+			})
+
+			It("emits a single batch from which the cancelled pair is dropped", func() {
+				sendEvents()
+				Eventually(clock.WatcherCount).Should(Equal(1))
+				Consistently(emitter.EmitCallCount).Should(Equal(0))
+
+				clock.Increment(100 * time.Millisecond)
+
+				Eventually(emitter.EmitCallCount).Should(Equal(1))
+				Expect(emitter.EmitArgsForCall(0)).To(Equal(routing_table.MessagesToEmit{
+					RegistrationMessages: []routing_table.RegistryMessage{messageFor("bar.com")},
+				}))
+			})
+		})
+
+		Context("when all the registered routes are unregistered within the window", func() {
+			BeforeEach(func() {
+				table.SetRoutesReturns(routing_table.MessagesToEmit{
+					RegistrationMessages: []routing_table.RegistryMessage{messageFor("foo.com", "bar.com")},
+				})
+				table.RemoveRoutesReturns(routing_table.MessagesToEmit{
+					UnregistrationMessages: []routing_table.RegistryMessage{messageFor("foo.com", "bar.com")},
+				})
+			})
+
+			It("emits nothing", func() {
+				sendEvents()
+				Eventually(clock.WatcherCount).Should(Equal(1))
+
+				clock.Increment(100 * time.Millisecond)
+
+				Consistently(emitter.EmitCallCount).Should(Equal(0))
+			})
+		})
+
+		Context("when a route is unregistered and then registered within the window", func() {
+			BeforeEach(func() {
+				table.SetRoutesReturns(routing_table.MessagesToEmit{
+					UnregistrationMessages: []routing_table.RegistryMessage{messageFor("foo.com")},
+				})
+				table.RemoveRoutesReturns(routing_table.MessagesToEmit{
+					RegistrationMessages: []routing_table.RegistryMessage{messageFor("foo.com")},
+				})
+			})
+
+			It("only emits the registration", func() {
+				sendEvents()
+				Eventually(clock.WatcherCount).Should(Equal(1))
+
+				clock.Increment(100 * time.Millisecond)
+
+				Eventually(emitter.EmitCallCount).Should(Equal(1))
+				Expect(emitter.EmitArgsForCall(0)).To(Equal(routing_table.MessagesToEmit{
+					RegistrationMessages: []routing_table.RegistryMessage{messageFor("foo.com")},
+				}))
+			})
+		})
+
+		Context("when the periodic emit happens before the window closes", func() {
+			BeforeEach(func() {
+				table.SetRoutesReturns(routing_table.MessagesToEmit{
+					RegistrationMessages: []routing_table.RegistryMessage{messageFor("foo.com")},
+				})
+			})
+
+			It("flushes the pending messages first", func() {
+				nextEvent.Store(EventHolder{models.NewDesiredLRPCreatedEvent(desiredLRP)})
+				Eventually(table.SetRoutesCallCount).Should(Equal(1))
+				Eventually(clock.WatcherCount).Should(Equal(1))
+
+				syncEvents.Emit <- struct{}{}
+
+				Eventually(emitter.EmitCallCount).Should(Equal(2))
+				Expect(emitter.EmitArgsForCall(0)).To(Equal(routing_table.MessagesToEmit{
+					RegistrationMessages: []routing_table.RegistryMessage{messageFor("foo.com")},
+				}))
+			})
+		})
+	})
+
 	Describe("interrupting the process", func() {
 		It("should be possible to SIGINT the route emitter", func() {
 			process.Signal(os.Interrupt)
@@ -901,6 +1329,56 @@ var _ = Describe("Watcher", func() {
 					return fakeMetricSender.GetCounter("RoutesSynced")
 				}, 2).Should(BeEquivalentTo(2))
 			})
+
+			Context("when some of the messages fail to emit", func() {
+				BeforeEach(func() {
+					result := route_sink.EmitResult{}
+					result.Succeed(route_sink.UnregisterSubject, 3)
+					result.Fail(route_sink.FailedMessage{
+						Sink:    "NATS",
+						Subject: route_sink.RegisterSubject,
+						Message: dummyMessagesToEmit.RegistrationMessages[0],
+						Err:     errors.New("bam"),
+						Dropped: true,
+					})
+					emitter.EmitReturns(result, errors.New("bam"))
+				})
+
+				It("logs the result", func() {
+					Eventually(logger.LogMessages).Should(ContainElement("test.watcher.emit.failed-to-emit-routes"))
+				})
+
+				It("counts the failed and dropped messages", func() {
+					Eventually(func() uint64 {
+						return fakeMetricSender.GetCounter("RouteEmitMessagesFailed")
+					}).Should(BeEquivalentTo(1))
+					Expect(fakeMetricSender.GetCounter("RouteEmitMessagesDropped")).To(BeEquivalentTo(1))
+				})
+
+				It("counts the partial failure", func() {
+					Eventually(func() uint64 {
+						return fakeMetricSender.GetCounter("RouteEmitPartialFailures")
+					}).Should(BeEquivalentTo(1))
+				})
+			})
+
+			Context("when there are tcp routes", func() {
+				BeforeEach(func() {
+					tcpTable.MessagesToEmitReturns(dummyTCPMessagesToEmit)
+					tcpTable.RouteCountReturns(7)
+				})
+
+				It("emits them to the tcp emitter", func() {
+					Eventually(tcpEmitter.EmitCallCount).Should(Equal(1))
+					Expect(tcpEmitter.EmitArgsForCall(0)).To(Equal(dummyTCPMessagesToEmit))
+				})
+
+				It("sends a 'tcp routes total' metric", func() {
+					Eventually(func() float64 {
+						return fakeMetricSender.GetValue("TCPRoutesTotal").Value
+					}, 2).Should(BeEquivalentTo(7))
+				})
+			})
 		})
 
 		Context("Begin & End events", func() {
@@ -1071,6 +1549,39 @@ var _ = Describe("Watcher", func() {
 
 				It("swaps the tables", func() {
 					Eventually(table.SwapCallCount).Should(Equal(1))
+					Eventually(tcpTable.SwapCallCount).Should(Equal(1))
+				})
+
+				It("emits the tcp messages from the swap", func() {
+					Eventually(tcpEmitter.EmitCallCount).Should(Equal(1))
+				})
+
+				Context("when the cells report availability zones", func() {
+					BeforeEach(func() {
+						bbsClient.CellsReturns([]*models.CellPresence{
+							{CellId: "cell-id", Zone: "z1"},
+						}, nil)
+					})
+
+					It("records the zone on endpoints handled after the sync", func() {
+						Eventually(table.SwapCallCount).Should(Equal(1))
+
+						sendEvent()
+
+						Eventually(table.RemoveEndpointCallCount).Should(Equal(1))
+						_, endpoint := table.RemoveEndpointArgsForCall(0)
+						Expect(endpoint.AvailabilityZone).To(Equal("z1"))
+					})
+				})
+
+				Context("when fetching the cells fails", func() {
+					BeforeEach(func() {
+						bbsClient.CellsReturns(nil, errors.New("bam"))
+					})
+
+					It("still swaps the tables", func() {
+						Eventually(table.SwapCallCount).Should(Equal(1))
+					})
 				})
 
 				Context("a table with a single routable endpoint", func() {
@@ -1100,7 +1611,7 @@ var _ = Describe("Watcher", func() {
 						table := routing_table.NewTable()
 						table.Swap(tempTable)
 
-						watcherProcess = watcher.NewWatcher(bbsClient, clock, table, emitter, syncEvents, logger)
+						watcherProcess = watcher.NewWatcher(bbsClient, clock, table, tcpTable, emitter, tcpEmitter, syncEvents, changeJournal, 0, logger)
 
 						bbsClient.DesiredLRPSchedulingInfosStub = func(f models.DesiredLRPFilter) ([]*models.DesiredLRPSchedulingInfo, error) {
 							defer GinkgoRecover()